@@ -0,0 +1,467 @@
+// Package assembler assembles 6502 source code, so that test programs can be written as readable assembly.
+//
+// The syntax is the standard one, close to that of ca65:
+//
+//	        .org $8000
+//	reset:  LDX #$FF        ; comments start with a semicolon
+//	        TXS
+//	@loop:  LDA table,X     ; labels starting with @ are local to the previous label
+//	        BNE @loop
+//	        JMP (vector)
+//	table:  .byte 1, 2, "text", <reset, >reset
+//	vector: .word reset, table+2
+//	count = 10              ; constants
+//	        .include "macros.s"
+//
+// Expressions are made of numbers (12, $0C, %1100, 'c'), symbols, * for the address of the current instruction,
+// the unary operators - ~ < (low byte) and > (high byte), the binary operators | ^ & << >> + - * / % by increasing
+// precedence, and parentheses. Operands that fit in the zero page use zero-page addressing, unless they are prefixed
+// with "a:", or depend on a symbol that is defined further down.
+package assembler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-nes/mos6502"
+)
+
+// Error is an error in the source, at the given line.
+type Error struct {
+	File string
+	Line int
+	Err  string
+}
+
+func (e *Error) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// Segment is a run of bytes assembled at the given address.
+type Segment struct {
+	Addr uint16
+	Data []uint8
+}
+
+// Program is the result of an assembly.
+type Program struct {
+	Segments []Segment
+	Labels   map[string]uint16 // all labels and constants, local labels are named <label>@<local>
+}
+
+// Entry returns the address of the first byte of the program, or 0 for an empty program.
+// Segments without any bytes, such as one left by a .org that is not followed by code, are skipped.
+func (p *Program) Entry() uint16 {
+	for _, segment := range p.Segments {
+		if len(segment.Data) > 0 {
+			return segment.Addr
+		}
+	}
+	return 0
+}
+
+// Assembler assembles source code for a variant of the 6502.
+type Assembler struct {
+	Variant mos6502.Variant
+
+	// ReadFile reads the files of .include directives, os.ReadFile by default
+	ReadFile func(path string) ([]byte, error)
+}
+
+func New(variant mos6502.Variant) *Assembler {
+	return &Assembler{
+		Variant:  variant,
+		ReadFile: os.ReadFile,
+	}
+}
+
+// Assemble assembles the given source for the NMOS 6502, see: Assembler.Assemble
+func Assemble(source string) (*Program, error) {
+	return New(mos6502.NMOS6502).Assemble("", source)
+}
+
+// AssembleFile assembles the source file at the given path.
+func (a *Assembler) AssembleFile(path string) (*Program, error) {
+	source, err := a.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return a.Assemble(path, string(source))
+}
+
+// Assemble assembles the given source, named after the file it was read from, if any.
+// Included files are looked up relative to the including file.
+func (a *Assembler) Assemble(name, source string) (*Program, error) {
+	as := &assembly{
+		statements: []statement{},
+		modes:      map[int]mos6502.AddressMode{},
+	}
+	if err := as.read(a, name, source, 0); err != nil {
+		return nil, err
+	}
+	// the first pass works out the size of the instructions and the address of the labels, the second one emits the code
+	for as.pass = 1; as.pass <= 2; as.pass++ {
+		as.start(a.Variant)
+		for n, s := range as.statements {
+			as.current = n
+			if err := as.assemble(s); err != nil {
+				return nil, &Error{File: s.file, Line: s.line, Err: err.Error()}
+			}
+		}
+	}
+	return &Program{Segments: as.segments, Labels: as.labels}, nil
+}
+
+// statement is a line of source, split into its parts.
+type statement struct {
+	file string
+	line int
+
+	label    string // defined at the address of the statement, or as the value of a constant
+	constant bool
+	op       string // mnemonic or directive, upper case
+	operand  string
+}
+
+// maxIncludeDepth stops files from including themselves endlessly.
+const maxIncludeDepth = 16
+
+// read splits the source into statements, replacing .include directives with the statements of the included files.
+func (as *assembly) read(a *Assembler, name, source string, depth int) error {
+	for n, text := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		s, err := parseStatement(text)
+		if err != nil {
+			return &Error{File: name, Line: n + 1, Err: err.Error()}
+		}
+		s.file, s.line = name, n+1
+		if s.op != ".INCLUDE" {
+			as.statements = append(as.statements, s)
+			continue
+		}
+
+		if s.label != "" {
+			as.statements = append(as.statements, statement{file: s.file, line: s.line, label: s.label})
+		}
+		path, ok := unquote(s.operand)
+		if !ok {
+			return &Error{File: name, Line: n + 1, Err: fmt.Sprintf("expected a quoted file name, got %q", s.operand)}
+		}
+		if depth == maxIncludeDepth {
+			return &Error{File: name, Line: n + 1, Err: "includes are nested too deeply"}
+		}
+		if name != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(name), path)
+		}
+		included, err := a.ReadFile(path)
+		if err != nil {
+			return &Error{File: name, Line: n + 1, Err: err.Error()}
+		}
+		if err := as.read(a, path, string(included), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseStatement splits a line into its label, mnemonic or directive, and operand.
+func parseStatement(text string) (statement, error) {
+	var s statement
+	text = strings.TrimSpace(stripComment(text))
+
+	if end := identifierEnd(text); end > 0 {
+		rest := strings.TrimSpace(text[end:])
+		switch {
+		case strings.HasPrefix(rest, ":"):
+			s.label = text[:end]
+			text = strings.TrimSpace(rest[1:])
+		case strings.HasPrefix(rest, "="):
+			s.label = text[:end]
+			s.constant = true
+			s.operand = strings.TrimSpace(rest[1:])
+			if s.operand == "" {
+				return s, fmt.Errorf("missing value of constant %s", s.label)
+			}
+			return s, nil
+		}
+	}
+
+	op, operand := text, ""
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		op, operand = text[:i], text[i+1:]
+	}
+	s.op = strings.ToUpper(op)
+	s.operand = strings.TrimSpace(operand)
+	return s, nil
+}
+
+// identifierEnd returns the length of the identifier the text starts with.
+func identifierEnd(text string) int {
+	if text == "" || !isIdentifierStart(text[0]) {
+		return 0
+	}
+	end := 1
+	for end < len(text) && isIdentifierChar(text[end]) {
+		end++
+	}
+	return end
+}
+
+// stripComment removes a comment, that is not within quotes, from the line.
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"':
+			quote = c
+		case c == '\'' && i+2 < len(text) && text[i+2] == '\'':
+			i += 2 // character literal, e.g. ';'
+		case c == ';':
+			return text[:i]
+		}
+	}
+	return text
+}
+
+func unquote(text string) (string, bool) {
+	if len(text) < 2 || text[0] != '"' || text[len(text)-1] != '"' {
+		return "", false
+	}
+	return text[1 : len(text)-1], true
+}
+
+// splitList splits the operands of a directive on the commas that are not within quotes or parentheses.
+func splitList(text string) []string {
+	var items []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"':
+			quote = c
+		case c == '\'' && i+2 < len(text) && text[i+2] == '\'':
+			i += 2
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, strings.TrimSpace(text[start:]))
+}
+
+// assembly is the state of an assembly in progress.
+type assembly struct {
+	statements []statement
+	pass       int
+	current    int                         // index of the statement being assembled
+	modes      map[int]mos6502.AddressMode // addressing mode of the instructions, chosen in the first pass
+
+	opcodes  map[string]map[mos6502.AddressMode]uint8
+	pc       uint16
+	wrapped  bool   // pc went past $FFFF
+	scope    string // last label that is not local
+	labels   map[string]uint16
+	defined  map[string]bool // labels defined during the current pass
+	segments []Segment
+}
+
+// start resets the state for the next pass.
+func (as *assembly) start(variant mos6502.Variant) {
+	as.opcodes = opcodeTable(variant)
+	as.pc = 0
+	as.wrapped = false
+	as.scope = ""
+	if as.labels == nil {
+		as.labels = map[string]uint16{}
+	}
+	as.defined = map[string]bool{}
+	as.segments = nil
+}
+
+// opcodeTable maps the mnemonics and addressing modes to the opcodes of the given variant.
+// Documented opcodes are preferred over their undocumented duplicates.
+func opcodeTable(variant mos6502.Variant) map[string]map[mos6502.AddressMode]uint8 {
+	cpu := mos6502.NewCPU(nil, variant)
+	table := map[string]map[mos6502.AddressMode]uint8{}
+	for _, unofficial := range []bool{false, true} {
+		for op := 0; op < 0x100; op++ {
+			info := cpu.Opcode(uint8(op))
+			if info.Unofficial != unofficial || info.Mode == mos6502.ModeNone {
+				continue
+			}
+			mnemonic := cpu.Mnemonic(uint8(op))
+			if table[mnemonic] == nil {
+				table[mnemonic] = map[mos6502.AddressMode]uint8{}
+			}
+			if _, ok := table[mnemonic][info.Mode]; !ok {
+				table[mnemonic][info.Mode] = uint8(op)
+			}
+		}
+	}
+	return table
+}
+
+// symbol returns the value of a label or constant. Undefined symbols are an error in the last pass only.
+func (as *assembly) symbol(name string) (int, bool, error) {
+	name = as.qualify(name)
+	if value, ok := as.labels[name]; ok {
+		return int(value), true, nil
+	}
+	if as.pass == 2 {
+		return 0, false, fmt.Errorf("undefined symbol %s", name)
+	}
+	return 0, false, nil
+}
+
+// qualify names a local label after the label it belongs to.
+func (as *assembly) qualify(name string) string {
+	if strings.HasPrefix(name, "@") {
+		return as.scope + name
+	}
+	return name
+}
+
+func (as *assembly) define(name string, value int) error {
+	if value < 0 || value > 0xFFFF {
+		return fmt.Errorf("value of %s out of range: %d", name, value)
+	}
+	name = as.qualify(name)
+	if as.defined[name] {
+		return fmt.Errorf("%s is already defined", name)
+	}
+	as.defined[name] = true
+	as.labels[name] = uint16(value)
+	return nil
+}
+
+// emit appends bytes at the current address.
+func (as *assembly) emit(data ...uint8) error {
+	if len(as.segments) == 0 {
+		as.segments = append(as.segments, Segment{Addr: as.pc})
+	}
+	for _, b := range data {
+		if as.wrapped {
+			return fmt.Errorf("the program goes past $FFFF")
+		}
+		segment := &as.segments[len(as.segments)-1]
+		segment.Data = append(segment.Data, b)
+		as.pc++
+		as.wrapped = as.pc == 0
+	}
+	return nil
+}
+
+func (as *assembly) assemble(s statement) error {
+	if s.constant {
+		value, known, err := as.eval(s.operand)
+		if err != nil || !known {
+			return err
+		}
+		return as.define(s.label, value)
+	}
+	if s.label != "" {
+		if !strings.HasPrefix(s.label, "@") {
+			as.scope = s.label
+		}
+		if err := as.define(s.label, int(as.pc)); err != nil {
+			return err
+		}
+	}
+
+	switch s.op {
+	case "":
+		return nil
+	case ".ORG":
+		value, known, err := as.eval(s.operand)
+		if err != nil {
+			return err
+		}
+		if !known {
+			return fmt.Errorf(".org must not depend on symbols defined further down")
+		}
+		if value < 0 || value > 0xFFFF {
+			return fmt.Errorf(".org out of range: %d", value)
+		}
+		as.pc = uint16(value)
+		as.wrapped = false
+		as.segments = append(as.segments, Segment{Addr: as.pc})
+		return nil
+	case ".BYTE":
+		return as.data(s.operand, 1)
+	case ".WORD":
+		return as.data(s.operand, 2)
+	case ".SETCPU":
+		return as.setCPU(s.operand)
+	}
+	if strings.HasPrefix(s.op, ".") {
+		return fmt.Errorf("unknown directive %s", s.op)
+	}
+	return as.instruction(s)
+}
+
+// data emits the values of a .byte or .word directive, strings are emitted as their characters.
+func (as *assembly) data(operand string, size int) error {
+	if operand == "" {
+		return fmt.Errorf("missing values")
+	}
+	for _, item := range splitList(operand) {
+		if text, ok := unquote(item); ok && size == 1 {
+			if err := as.emit([]uint8(text)...); err != nil {
+				return err
+			}
+			continue
+		}
+		value, _, err := as.eval(item)
+		if err != nil {
+			return err
+		}
+		if size == 1 {
+			if value < -0x80 || value > 0xFF {
+				return fmt.Errorf("byte out of range: %d", value)
+			}
+			err = as.emit(uint8(value))
+		} else {
+			if value < -0x8000 || value > 0xFFFF {
+				return fmt.Errorf("word out of range: %d", value)
+			}
+			err = as.emit(uint8(value), uint8(value>>8))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCPU switches the instruction set, "6502" and "6502X" select the NMOS 6502 with its undocumented opcodes.
+func (as *assembly) setCPU(operand string) error {
+	cpu, ok := unquote(operand)
+	if !ok {
+		return fmt.Errorf("expected a quoted CPU name, got %q", operand)
+	}
+	switch strings.ToUpper(cpu) {
+	case "6502", "6502X":
+		as.opcodes = opcodeTable(mos6502.NMOS6502)
+	case "65C02":
+		as.opcodes = opcodeTable(mos6502.CMOS65C02)
+	default:
+		return fmt.Errorf("unsupported CPU %q", cpu)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package assembler
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go-nes/mos6502"
+)
+
+func assemble(t *testing.T, source string) *Program {
+	program, err := Assemble(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func expectBytes(t *testing.T, segment Segment, addr uint16, expected string) {
+	t.Helper()
+	if segment.Addr != addr {
+		t.Errorf("got a segment at $%04X, expected $%04X", segment.Addr, addr)
+	}
+	if got := strings.ToUpper(hex.EncodeToString(segment.Data)); got != expected {
+		t.Errorf("got %s, expected %s", got, expected)
+	}
+}
+
+func TestAssembleProgram(t *testing.T) {
+	// the program of TestBasic, multiplying 10 by 3
+	program := assemble(t, `
+		LDX #10
+		STX a:$0000
+		LDX #3
+		STX a:$0001
+		LDY a:$0000
+		LDA #0
+		CLC
+loop:	ADC a:$0001
+		DEY
+		BNE loop
+		STA a:$0002
+		NOP
+		NOP
+		NOP
+	`)
+	if len(program.Segments) != 1 {
+		t.Fatalf("got %d segments, expected 1", len(program.Segments))
+	}
+	expectBytes(t, program.Segments[0], 0x0000, "A20A8E0000A2038E0100AC0000A900186D010088D0FA8D0200EAEAEA")
+	if program.Labels["loop"] != 0x0010 {
+		t.Errorf("got loop at $%04X, expected $0010", program.Labels["loop"])
+	}
+}
+
+func TestAssembleAddressingModes(t *testing.T) {
+	for _, test := range []struct {
+		source   string
+		expected string
+	}{
+		{"ASL", "0A"},
+		{"ASL A", "0A"},
+		{"LDA #$12", "A912"},
+		{"LDA #'A'", "A941"},
+		{"LDA $12", "A512"},
+		{"LDA $1234", "AD3412"},
+		{"LDA a:$12", "AD1200"},
+		{"LDA $12,X", "B512"},
+		{"LDA $1234,Y", "B93412"},
+		{"LDX $12,Y", "B612"},
+		{"LDA ($12,X)", "A112"},
+		{"LDA ($12),Y", "B112"},
+		{"JMP ($1234)", "6C3412"},
+		{"JMP ($12)", "6C1200"},
+		{"LDA (1+2)*3", "A509"},
+		{"BNE *", "D0FE"},
+		{"BEQ *+4", "F002"},
+		{"SLO $12", "0712"},
+	} {
+		program, err := Assemble(".org $8000\n" + test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := strings.ToUpper(hex.EncodeToString(program.Segments[0].Data)); got != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.expected)
+		}
+	}
+}
+
+func TestAssembleCMOS(t *testing.T) {
+	program, err := New(mos6502.CMOS65C02).Assemble("", `
+		.org $0200
+start:	LDA ($12)
+		JMP ($1234,X)
+		BBR3 $12,start
+		STZ $12
+		BRA start
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectBytes(t, program.Segments[0], 0x0200, "B2127C34123F12F8641280F4")
+
+	if _, err := Assemble("STZ $12"); err == nil {
+		t.Errorf("expected the 65C02 instructions to be unknown to the 6502")
+	}
+	if _, err := Assemble(".setcpu \"65C02\"\nSTZ $12"); err != nil {
+		t.Errorf("expected .setcpu to switch to the 65C02: %v", err)
+	}
+}
+
+func TestAssembleLabelsAndDirectives(t *testing.T) {
+	program := assemble(t, `
+count = 3
+		.org $C000
+reset:	LDX #count
+@loop:	LDA table-1,X
+		STA buffer,X	; forward references use absolute addressing
+		DEX
+		BNE @loop
+nmi:	JMP nmi
+@loop:	RTI
+table:	.byte 1, 2, "ab", <reset, >reset, -1
+buffer = $10
+		.org $FFFA
+		.word nmi, reset, nmi@loop
+	`)
+	if len(program.Segments) != 2 {
+		t.Fatalf("got %d segments, expected 2", len(program.Segments))
+	}
+	expectBytes(t, program.Segments[0], 0xC000, "A203BD0EC09D1000CAD0F74C0BC0400102616200C0FF")
+	expectBytes(t, program.Segments[1], 0xFFFA, "0BC000C00EC0")
+	if program.Labels["reset@loop"] != 0xC002 {
+		t.Errorf("got reset@loop at $%04X, expected $C002", program.Labels["reset@loop"])
+	}
+	if program.Entry() != 0xC000 {
+		t.Errorf("got entry $%04X, expected $C000", program.Entry())
+	}
+
+	// a .org that is not followed by any bytes does not move the entry point
+	if entry := assemble(t, ".org $0300\n.org $0400\nNOP").Entry(); entry != 0x0400 {
+		t.Errorf("got entry $%04X after an empty segment, expected $0400", entry)
+	}
+}
+
+func TestAssembleNumbers(t *testing.T) {
+	for _, test := range []struct {
+		source   string
+		expected string
+	}{
+		{"LDA #08", "A908"},
+		{"LDA #09", "A909"},
+		{".byte 010", "0A"},
+		{".byte 0, 255", "00FF"},
+		{".byte $10, %101, 'A'", "100541"},
+		{".word 01000", "E803"},
+	} {
+		program, err := Assemble(test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := strings.ToUpper(hex.EncodeToString(program.Segments[0].Data)); got != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.expected)
+		}
+	}
+}
+
+func TestAssembleInclude(t *testing.T) {
+	files := map[string]string{
+		"src/main.s":       ".org $8000\n.include \"lib/consts.s\"\nLDA #VALUE\n",
+		"src/lib/consts.s": "VALUE = $42\n.byte VALUE\n",
+		"src/loop.s":       ".include \"loop.s\"\n",
+	}
+	a := New(mos6502.NMOS6502)
+	a.ReadFile = func(path string) ([]byte, error) {
+		if source, ok := files[strings.ReplaceAll(path, "\\", "/")]; ok {
+			return []byte(source), nil
+		}
+		return nil, fmt.Errorf("%s not found", path)
+	}
+
+	program, err := a.AssembleFile("src/main.s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectBytes(t, program.Segments[0], 0x8000, "42A942")
+
+	if _, err := a.AssembleFile("src/loop.s"); err == nil {
+		t.Errorf("expected an error for a file including itself")
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	for source, expected := range map[string]string{
+		"LDA missing":            "line 1: undefined symbol missing",
+		"NOP\nFOO $12":           "line 2: unknown instruction FOO",
+		"LDA #$100":              "line 1: immediate value out of range: 256",
+		"LDX ($12),Y":            "line 1: invalid addressing mode for LDX: \"($12),Y\"",
+		"a: NOP\na: NOP":         "line 2: a is already defined",
+		".org $8000\nBNE $8100":  "line 2: branch target out of range, 254 bytes away",
+		".org later\nlater: NOP": "line 1: .org must not depend on symbols defined further down",
+		".byte 256":              "line 1: byte out of range: 256",
+		".org $FFFF\nNOP\nNOP":   "line 3: the program goes past $FFFF",
+		".foo 1":                 "line 1: unknown directive .FOO",
+		"LDA (1":                 "line 1: missing ) in expression \"(1\"",
+		"LDA #0x10":              "line 1: invalid number \"0x10\"",
+		"LDA #0o7":               "line 1: invalid number \"0o7\"",
+		".byte 1_0":              "line 1: invalid number \"1_0\"",
+	} {
+		_, err := Assemble(source)
+		if err == nil || err.Error() != expected {
+			t.Errorf("%q: got error %v, expected %q", source, err, expected)
+		}
+	}
+}
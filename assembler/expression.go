@@ -0,0 +1,206 @@
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expressionOperators lists the binary operators by increasing precedence.
+var expressionOperators = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// expression evaluates an expression of the source, see: Assembler
+type expression struct {
+	a     *assembly
+	text  string
+	pos   int
+	known bool // false if the value depends on a symbol that is not defined (yet)
+}
+
+// eval evaluates the given expression. Symbols that are not defined are an error in the final pass only,
+// before that the value is returned as unknown.
+func (a *assembly) eval(text string) (int, bool, error) {
+	e := &expression{a: a, text: text, known: true}
+	value, err := e.parseBinary(0)
+	if err != nil {
+		return 0, false, err
+	}
+	e.skipSpace()
+	if e.pos < len(e.text) {
+		return 0, false, fmt.Errorf("unexpected %q in expression %q", e.text[e.pos:], text)
+	}
+	return value, e.known, nil
+}
+
+func (e *expression) skipSpace() {
+	for e.pos < len(e.text) && (e.text[e.pos] == ' ' || e.text[e.pos] == '\t') {
+		e.pos++
+	}
+}
+
+// operator consumes and returns one of the binary operators of the given level, if it is next.
+func (e *expression) operator(level int) (string, bool) {
+	e.skipSpace()
+	for _, operator := range expressionOperators[level] {
+		if strings.HasPrefix(e.text[e.pos:], operator) {
+			e.pos += len(operator)
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (e *expression) parseBinary(level int) (int, error) {
+	if level == len(expressionOperators) {
+		return e.parseUnary()
+	}
+	left, err := e.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		operator, ok := e.operator(level)
+		if !ok {
+			return left, nil
+		}
+		right, err := e.parseBinary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch operator {
+		case "|":
+			left |= right
+		case "^":
+			left ^= right
+		case "&":
+			left &= right
+		case "<<":
+			left <<= uint(right)
+		case ">>":
+			left >>= uint(right)
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/", "%":
+			if right == 0 {
+				if !e.known {
+					return 0, nil
+				}
+				return 0, fmt.Errorf("division by zero in expression %q", e.text)
+			}
+			if operator == "/" {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+}
+
+func (e *expression) parseUnary() (int, error) {
+	e.skipSpace()
+	if e.pos >= len(e.text) {
+		return 0, fmt.Errorf("unexpected end of expression %q", e.text)
+	}
+	c := e.text[e.pos]
+	switch c {
+	case '-', '~', '<', '>':
+		e.pos++
+		value, err := e.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case '-':
+			return -value, nil
+		case '~':
+			return ^value, nil
+		case '<':
+			return value & 0xFF, nil // low byte
+		default:
+			return value >> 8 & 0xFF, nil // high byte
+		}
+	case '(':
+		e.pos++
+		value, err := e.parseBinary(0)
+		if err != nil {
+			return 0, err
+		}
+		e.skipSpace()
+		if e.pos >= len(e.text) || e.text[e.pos] != ')' {
+			return 0, fmt.Errorf("missing ) in expression %q", e.text)
+		}
+		e.pos++
+		return value, nil
+	case '*':
+		// the address of the current instruction
+		e.pos++
+		return int(e.a.pc), nil
+	case '\'':
+		if e.pos+2 >= len(e.text) || e.text[e.pos+2] != '\'' {
+			return 0, fmt.Errorf("invalid character in expression %q", e.text)
+		}
+		value := int(e.text[e.pos+1])
+		e.pos += 3
+		return value, nil
+	}
+	return e.parseOperand()
+}
+
+// parseOperand parses a number, e.g. 12, $0C or %1100, or a symbol.
+func (e *expression) parseOperand() (int, error) {
+	start := e.pos
+	if e.text[e.pos] == '$' || e.text[e.pos] == '%' {
+		e.pos++
+	}
+	for e.pos < len(e.text) && isIdentifierChar(e.text[e.pos]) {
+		e.pos++
+	}
+	token := e.text[start:e.pos]
+	if token == "" {
+		return 0, fmt.Errorf("unexpected %q in expression %q", e.text[start:], e.text)
+	}
+
+	var value int64
+	var err error
+	switch {
+	case token[0] == '$':
+		value, err = strconv.ParseInt(token[1:], 16, 32)
+	case token[0] == '%':
+		value, err = strconv.ParseInt(token[1:], 2, 32)
+	case token[0] >= '0' && token[0] <= '9':
+		// decimal only, a leading zero does not make an octal number
+		value, err = strconv.ParseInt(token, 10, 32)
+	default:
+		symbol, ok, err := e.a.symbol(token)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			e.known = false
+		}
+		return symbol, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", token)
+	}
+	return int(value), nil
+}
+
+func isIdentifierStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '@'
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || c >= '0' && c <= '9'
+}
@@ -0,0 +1,236 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+
+	"go-nes/mos6502"
+)
+
+// operandForm is the syntactic form of an operand, which narrows down its addressing mode.
+type operandForm uint8
+
+const (
+	formNone      operandForm = iota // implied or accumulator
+	formAccu                         // A
+	formImmd                         // #value
+	formAddr                         // address: zero page, absolute or relative
+	formIndexedX                     // address,X
+	formIndexedY                     // address,Y
+	formIndirect                     // (address)
+	formIndirectX                    // (address,X)
+	formIndirectY                    // (address),Y
+	formBitBranch                    // zero page,target of BBR and BBS
+)
+
+// operand is a parsed operand, with the expressions of its address, and of the branch target of BBR and BBS.
+type operand struct {
+	form     operandForm
+	expr     string
+	target   string
+	absolute bool // the a: prefix forces absolute addressing
+}
+
+// parseOperand works out the form of an operand from its syntax.
+func parseOperand(text string) (operand, error) {
+	switch {
+	case text == "":
+		return operand{form: formNone}, nil
+	case strings.EqualFold(text, "A"):
+		return operand{form: formAccu}, nil
+	case strings.HasPrefix(text, "#"):
+		return operand{form: formImmd, expr: strings.TrimSpace(text[1:])}, nil
+	}
+
+	var op operand
+	if strings.HasPrefix(strings.ToLower(text), "a:") {
+		op.absolute = true
+		text = strings.TrimSpace(text[2:])
+	}
+
+	// an operand in parentheses is indirect, unless the parentheses are part of an expression, e.g. (1+2)*3
+	if strings.HasPrefix(text, "(") {
+		if closing := matchingParenthesis(text); closing > 0 {
+			inner, rest := strings.TrimSpace(text[1:closing]), strings.ReplaceAll(text[closing+1:], " ", "")
+			switch {
+			case rest == "":
+				if items := splitList(inner); len(items) == 2 && strings.EqualFold(items[1], "X") {
+					op.form, op.expr = formIndirectX, items[0]
+				} else {
+					op.form, op.expr = formIndirect, inner
+				}
+				return op, nil
+			case strings.EqualFold(rest, ",Y"):
+				op.form, op.expr = formIndirectY, inner
+				return op, nil
+			}
+		}
+	}
+
+	items := splitList(text)
+	switch {
+	case len(items) == 1:
+		op.form, op.expr = formAddr, items[0]
+	case len(items) == 2 && strings.EqualFold(items[1], "X"):
+		op.form, op.expr = formIndexedX, items[0]
+	case len(items) == 2 && strings.EqualFold(items[1], "Y"):
+		op.form, op.expr = formIndexedY, items[0]
+	case len(items) == 2:
+		op.form, op.expr, op.target = formBitBranch, items[0], items[1]
+	default:
+		return op, fmt.Errorf("invalid operand %q", text)
+	}
+	return op, nil
+}
+
+// matchingParenthesis returns the index of the parenthesis closing the one the text starts with, or -1.
+func matchingParenthesis(text string) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// zeroPageModes pairs the addressing modes that exist with a zero-page and an absolute address.
+var zeroPageModes = map[operandForm][2]mos6502.AddressMode{
+	formAddr:      {mos6502.ModeZpag, mos6502.ModeAbso},
+	formIndexedX:  {mos6502.ModeZpgX, mos6502.ModeAbsX},
+	formIndexedY:  {mos6502.ModeZpgY, mos6502.ModeAbsY},
+	formIndirect:  {mos6502.ModeZInd, mos6502.ModeIndi},
+	formIndirectX: {mos6502.ModeXInd, mos6502.ModeAInX},
+}
+
+// chooseMode picks the addressing mode of an instruction, preferring zero-page addressing for addresses below $100.
+func chooseMode(modes map[mos6502.AddressMode]uint8, op operand, value int, known bool) (mos6502.AddressMode, bool) {
+	has := func(mode mos6502.AddressMode) bool {
+		_, ok := modes[mode]
+		return ok
+	}
+
+	switch op.form {
+	case formNone:
+		if has(mos6502.ModeImpl) {
+			return mos6502.ModeImpl, true
+		}
+		return mos6502.ModeAccu, has(mos6502.ModeAccu)
+	case formAccu:
+		return mos6502.ModeAccu, has(mos6502.ModeAccu)
+	case formImmd:
+		return mos6502.ModeImmd, has(mos6502.ModeImmd)
+	case formIndirectY:
+		return mos6502.ModeIndY, has(mos6502.ModeIndY)
+	case formBitBranch:
+		return mos6502.ModeZRel, has(mos6502.ModeZRel)
+	case formAddr:
+		if has(mos6502.ModeRela) {
+			return mos6502.ModeRela, true
+		}
+	}
+
+	pair := zeroPageModes[op.form]
+	zeroPage, absolute := has(pair[0]), has(pair[1])
+	switch {
+	case zeroPage && absolute:
+		if !op.absolute && known && value >= 0 && value <= 0xFF {
+			return pair[0], true
+		}
+		return pair[1], true
+	case zeroPage:
+		return pair[0], !op.absolute
+	case absolute:
+		return pair[1], true
+	}
+	return mos6502.ModeNone, false
+}
+
+// instruction assembles an instruction. Its addressing mode is chosen in the first pass, so that its size does not
+// change once the addresses of the labels are known.
+func (as *assembly) instruction(s statement) error {
+	modes, ok := as.opcodes[s.op]
+	if !ok {
+		return fmt.Errorf("unknown instruction %s", s.op)
+	}
+	op, err := parseOperand(s.operand)
+	if err != nil {
+		return err
+	}
+
+	var value int
+	known := true
+	if op.expr != "" {
+		if value, known, err = as.eval(op.expr); err != nil {
+			return err
+		}
+	}
+
+	mode, ok := as.modes[as.current]
+	if as.pass == 1 {
+		if mode, ok = chooseMode(modes, op, value, known); !ok {
+			return fmt.Errorf("invalid addressing mode for %s: %q", s.op, s.operand)
+		}
+		as.modes[as.current] = mode
+	}
+	opcode := modes[mode]
+	pc := as.pc
+
+	switch mode {
+	case mos6502.ModeImpl, mos6502.ModeAccu:
+		return as.emit(opcode)
+	case mos6502.ModeImmd:
+		if value < -0x80 || value > 0xFF {
+			return fmt.Errorf("immediate value out of range: %d", value)
+		}
+		return as.emit(opcode, uint8(value))
+	case mos6502.ModeZpag, mos6502.ModeZpgX, mos6502.ModeZpgY, mos6502.ModeXInd, mos6502.ModeIndY, mos6502.ModeZInd:
+		if known && (value < 0 || value > 0xFF) {
+			return fmt.Errorf("zero-page address out of range: $%X", value)
+		}
+		return as.emit(opcode, uint8(value))
+	case mos6502.ModeAbso, mos6502.ModeAbsX, mos6502.ModeAbsY, mos6502.ModeIndi, mos6502.ModeAInX:
+		if known && (value < 0 || value > 0xFFFF) {
+			return fmt.Errorf("address out of range: $%X", value)
+		}
+		return as.emit(opcode, uint8(value), uint8(value>>8))
+	case mos6502.ModeRela:
+		offset, err := as.branchOffset(value, known, pc+2)
+		if err != nil {
+			return err
+		}
+		return as.emit(opcode, offset)
+	case mos6502.ModeZRel:
+		if known && (value < 0 || value > 0xFF) {
+			return fmt.Errorf("zero-page address out of range: $%X", value)
+		}
+		target, targetKnown, err := as.eval(op.target)
+		if err != nil {
+			return err
+		}
+		offset, err := as.branchOffset(target, targetKnown, pc+3)
+		if err != nil {
+			return err
+		}
+		return as.emit(opcode, uint8(value), offset)
+	}
+	return fmt.Errorf("invalid addressing mode for %s: %q", s.op, s.operand)
+}
+
+// branchOffset returns the offset of a branch to the target, from the address of the next instruction.
+func (as *assembly) branchOffset(target int, known bool, next uint16) (uint8, error) {
+	if !known {
+		return 0, nil
+	}
+	offset := target - int(next)
+	if offset < -0x80 || offset > 0x7F {
+		return 0, fmt.Errorf("branch target out of range, %d bytes away", offset)
+	}
+	return uint8(offset), nil
+}
@@ -0,0 +1,145 @@
+// Command tracediff compares two execution traces in the nestest.log format, and reports the first instruction where they diverge.
+//
+// Usage:
+//
+//	tracediff [-ignore PPU,CYC] [-context 5] expected.log actual.log
+//
+// The exit status is 0 when the traces match, 1 when they diverge, and 2 on errors.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// traceFields are the fields of a trace line that are compared, in order.
+var traceFields = []string{"PC", "A", "X", "Y", "P", "SP", "PPU", "CYC"}
+
+var traceFieldPatterns = map[string]*regexp.Regexp{
+	"PC":  regexp.MustCompile(`^([0-9A-Fa-f]{4})\s`),
+	"A":   regexp.MustCompile(`\bA:([0-9A-Fa-f]{2})\b`),
+	"X":   regexp.MustCompile(`\bX:([0-9A-Fa-f]{2})\b`),
+	"Y":   regexp.MustCompile(`\bY:([0-9A-Fa-f]{2})\b`),
+	"P":   regexp.MustCompile(`\bP:([0-9A-Fa-f]{2})\b`),
+	"SP":  regexp.MustCompile(`\bSP:([0-9A-Fa-f]{2})\b`),
+	"PPU": regexp.MustCompile(`\bPPU:\s*(-?\d+),\s*(\d+)`),
+	"CYC": regexp.MustCompile(`\bCYC:(\d+)`),
+}
+
+// parseTraceLine returns the fields of a trace line, fields that are missing are left out.
+func parseTraceLine(line string) map[string]string {
+	fields := map[string]string{}
+	for name, pattern := range traceFieldPatterns {
+		if match := pattern.FindStringSubmatch(line); match != nil {
+			fields[name] = strings.ToUpper(strings.Join(match[1:], ","))
+		}
+	}
+	return fields
+}
+
+// divergence is the first difference found between two traces.
+type divergence struct {
+	line             int // 1-based line number
+	expected, actual string
+	fields           []string // differing fields, or empty when one trace ended early
+	context          []string // lines preceding the divergence
+}
+
+// diffTraces compares the traces line by line, ignoring the given fields.
+// It returns nil when the traces match.
+func diffTraces(expected, actual io.Reader, ignore map[string]bool, contextLines int) (*divergence, error) {
+	expectedScanner := bufio.NewScanner(expected)
+	actualScanner := bufio.NewScanner(actual)
+	var context []string
+
+	for line := 1; ; line++ {
+		hasExpected := expectedScanner.Scan()
+		hasActual := actualScanner.Scan()
+		if !hasExpected || !hasActual {
+			if err := expectedScanner.Err(); err != nil {
+				return nil, err
+			}
+			if err := actualScanner.Err(); err != nil {
+				return nil, err
+			}
+			if hasExpected == hasActual {
+				return nil, nil
+			}
+			return &divergence{line: line, expected: expectedScanner.Text(), actual: actualScanner.Text(), context: context}, nil
+		}
+
+		expectedFields := parseTraceLine(expectedScanner.Text())
+		actualFields := parseTraceLine(actualScanner.Text())
+		var differing []string
+		for _, name := range traceFields {
+			if !ignore[name] && expectedFields[name] != actualFields[name] {
+				differing = append(differing, name)
+			}
+		}
+		if len(differing) > 0 {
+			return &divergence{line: line, expected: expectedScanner.Text(), actual: actualScanner.Text(), fields: differing, context: context}, nil
+		}
+
+		context = append(context, actualScanner.Text())
+		if len(context) > contextLines {
+			context = context[1:]
+		}
+	}
+}
+
+func main() {
+	ignoreFlag := flag.String("ignore", "", "comma separated fields to ignore: "+strings.Join(traceFields, ","))
+	contextFlag := flag.Int("context", 5, "number of matching lines to show before the divergence")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: tracediff [-ignore fields] [-context lines] expected.log actual.log")
+		os.Exit(2)
+	}
+
+	ignore := map[string]bool{}
+	for _, name := range strings.Split(*ignoreFlag, ",") {
+		if name != "" {
+			ignore[strings.ToUpper(strings.TrimSpace(name))] = true
+		}
+	}
+
+	expected, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer expected.Close()
+	actual, err := os.Open(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer actual.Close()
+
+	d, err := diffTraces(expected, actual, ignore, *contextFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if d == nil {
+		fmt.Println("traces match")
+		return
+	}
+
+	for _, line := range d.context {
+		fmt.Println("  " + line)
+	}
+	if len(d.fields) == 0 {
+		fmt.Printf("traces diverge at line %d, one of them ends early\n", d.line)
+	} else {
+		fmt.Printf("traces diverge at line %d in %s\n", d.line, strings.Join(d.fields, ", "))
+	}
+	fmt.Println("- " + d.expected)
+	fmt.Println("+ " + d.actual)
+	os.Exit(1)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const expectedTrace = `C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7
+C5F5  A2 00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 30 CYC:10
+C5F7  86 00     STX $00 = 00                    A:00 X:00 Y:00 P:26 SP:FD PPU:  0, 36 CYC:12
+`
+
+func TestDiffTraces(t *testing.T) {
+	d, err := diffTraces(strings.NewReader(expectedTrace), strings.NewReader(expectedTrace), nil, 5)
+	if err != nil || d != nil {
+		t.Errorf("got %+v, %v, expected the traces to match", d, err)
+	}
+
+	actual := strings.Replace(expectedTrace, "P:26 SP:FD PPU:  0, 36 CYC:12", "P:24 SP:FD PPU:  0, 39 CYC:13", 1)
+	d, err = diffTraces(strings.NewReader(expectedTrace), strings.NewReader(actual), nil, 1)
+	if err != nil || d == nil {
+		t.Fatalf("got %+v, %v, expected a divergence", d, err)
+	}
+	if d.line != 3 || !reflect.DeepEqual(d.fields, []string{"P", "PPU", "CYC"}) || len(d.context) != 1 {
+		t.Errorf("got %+v, expected a divergence at line 3 in P, PPU and CYC", d)
+	}
+
+	d, err = diffTraces(strings.NewReader(expectedTrace), strings.NewReader(actual), map[string]bool{"P": true, "PPU": true, "CYC": true}, 1)
+	if err != nil || d != nil {
+		t.Errorf("got %+v, %v, expected the ignored fields to match", d, err)
+	}
+
+	d, err = diffTraces(strings.NewReader(expectedTrace), strings.NewReader(expectedTrace[:strings.LastIndex(expectedTrace[:len(expectedTrace)-1], "\n")+1]), nil, 5)
+	if err != nil || d == nil || d.line != 3 || len(d.fields) != 0 {
+		t.Errorf("got %+v, %v, expected the actual trace to end early at line 3", d, err)
+	}
+}
@@ -0,0 +1,151 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-nes/nes"
+	"os"
+	"path/filepath"
+)
+
+type PaletteSource string
+
+const (
+	DefaultPalette   PaletteSource = "default"
+	GeneratedPalette PaletteSource = "generated"
+	FilePalette      PaletteSource = "file"
+)
+
+type VideoFilter string
+
+const (
+	NoFilter         VideoFilter = "none"
+	CompositeFilter  VideoFilter = "composite"
+	SVideoFilter     VideoFilter = "svideo"
+	RGBFilter        VideoFilter = "rgb"
+	MonochromeFilter VideoFilter = "monochrome"
+)
+
+// Config holds the settings of the emulator that are persisted between runs.
+type Config struct {
+	Palette PaletteConfig    `json:"palette"`
+	Video   VideoConfig      `json:"video"`
+	Input   InputConfig      `json:"input"`
+	PowerOn nes.PowerOnState `json:"powerOn"` // content of the memories at power-on
+}
+
+type PaletteConfig struct {
+	Source    PaletteSource     `json:"source"`
+	File      string            `json:"file,omitempty"` // Path to a .pal file, used when Source is FilePalette
+	Generator nes.PaletteParams `json:"generator"`      // Used when Source is GeneratedPalette
+}
+
+type VideoConfig struct {
+	Filter     VideoFilter           `json:"filter"`
+	Screenshot nes.ScreenshotOptions `json:"screenshot"` // size of the screenshots, see: Emulator.SaveScreenshot
+}
+
+// InputConfig holds the input devices and their bindings.
+// Buttons missing from the bindings in the file keep their default bindings.
+type InputConfig struct {
+	Zapper        bool                    `json:"zapper"`        // plugs a Zapper into port 2, aimed with the mouse
+	TurboRate     int                     `json:"turboRate"`     // frames per press of the turbo buttons, see: nes.InputFilter
+	AllowOpposite bool                    `json:"allowOpposite"` // allow Up+Down and Left+Right to be held together
+	Players       [2]PlayerBindings       `json:"players"`
+	Games         map[string]GameBindings `json:"games,omitempty"` // by ROM file name
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Palette: PaletteConfig{
+			Source:    DefaultPalette,
+			Generator: nes.DefaultPaletteParams(),
+		},
+		Video: VideoConfig{
+			Filter:     NoFilter,
+			Screenshot: nes.ScreenshotOptions{Scale: 1},
+		},
+		PowerOn: nes.DefaultPowerOnState(),
+		Input: InputConfig{
+			TurboRate: 2,
+			Players:   [2]PlayerBindings{DefaultPlayerBindings(0), DefaultPlayerBindings(1)},
+		},
+	}
+}
+
+// DefaultConfigPath returns the path of the config file in the user's config directory.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "go-nes.json"
+	}
+	return filepath.Join(dir, "go-nes", "config.json")
+}
+
+// LoadConfig reads the config file at the given path.
+// Settings missing from the file keep their default values, and a missing file results in the default config.
+func LoadConfig(filePath string) (Config, error) {
+	config := DefaultConfig()
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("error while reading config file: %v", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return DefaultConfig(), fmt.Errorf("error while parsing config file: %v", err)
+	}
+	return config, nil
+}
+
+// Save writes the config to the given path, creating its directory if needed.
+func (c Config) Save(filePath string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("error while creating config directory: %v", err)
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("error while writing config file: %v", err)
+	}
+	return nil
+}
+
+// Filter returns the filter applied to the controller states.
+func (c InputConfig) Filter() nes.InputFilter {
+	return nes.InputFilter{TurboRate: c.TurboRate, AllowOpposite: c.AllowOpposite}
+}
+
+// Palette builds the palette described by the config.
+func (c PaletteConfig) Palette() (*nes.Palette, error) {
+	switch c.Source {
+	case DefaultPalette, "":
+		return nes.DefaultPalette(), nil
+	case GeneratedPalette:
+		return nes.GeneratePalette(c.Generator), nil
+	case FilePalette:
+		return nes.LoadPalette(c.File)
+	}
+	return nil, fmt.Errorf("unknown palette source %q", c.Source)
+}
+
+// NTSCFilter builds the NTSC filter described by the config, or returns nil if no filter is used.
+func (c VideoConfig) NTSCFilter() (*nes.NTSCFilter, error) {
+	switch c.Filter {
+	case NoFilter, "":
+		return nil, nil
+	case CompositeFilter:
+		return nes.NewNTSCFilter(nes.NTSCComposite), nil
+	case SVideoFilter:
+		return nes.NewNTSCFilter(nes.NTSCSVideo), nil
+	case RGBFilter:
+		return nes.NewNTSCFilter(nes.NTSCRGB), nil
+	case MonochromeFilter:
+		return nes.NewNTSCFilter(nes.NTSCMonochrome), nil
+	}
+	return nil, fmt.Errorf("unknown video filter %q", c.Filter)
+}
@@ -1,30 +1,43 @@
 package emulator
 
 import (
+	"bufio"
 	"fmt"
 	"go-nes/nes"
 	"image"
 	"image/color"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
 const (
-	windowWidth  = 1024
-	windowHeight = 960
+	windowHeight = 480 // height of the window before scaling, see: Emulator.Scale, Emulator.windowWidth
 	windowScale  = 2
+	panelWidth   = 240 // width of the debug panels right of the screen
 
-	cpuClockSpeed = 1789773
+	// position of the NES screen in the window, before scaling
+	screenX = 8
+	screenY = 8
 )
 
 var (
-	screenImage               = ebiten.NewImage(256, 240)
+	screenImage               = ebiten.NewImage(nes.ScreenWidth, nes.ScreenHeight)
+	screenPixels              = make([]byte, nes.ScreenWidth*nes.ScreenHeight*4)
+	ntscImage                 = ebiten.NewImage(nes.NTSCOutputWidth, nes.ScreenHeight)
+	ntscPixels                = make([]byte, nes.NTSCOutputWidth*nes.ScreenHeight*4)
 	paletteTableImage         = ebiten.NewImage(256, 8)
 	paletteSelectedBoxImage   = ebiten.NewImage(32, 8)
 	patternTableImage         = ebiten.NewImage(128, 128)
 	disassemblyHighlightImage = ebiten.NewImage(144, 12)
+	disassemblyBreakImage     = ebiten.NewImage(144, 12)
 
 	debugPatternId = 0
 )
@@ -45,6 +58,7 @@ const (
 	Stepping  State = "stepping"
 	Paused    State = "paused"
 	Nametable State = "nametable"
+	Bindings  State = "bindings"
 )
 
 type Emulator struct {
@@ -52,9 +66,12 @@ type Emulator struct {
 	VM *nes.VM
 
 	// Settings
-	Mode      Mode
-	State     State
-	PrevState State
+	Mode       Mode
+	Scale      int // size of the window pixels, in screen pixels
+	State      State
+	PrevState  State
+	Config     Config
+	ConfigPath string
 
 	// Debugging controls
 	IsKeyPressed bool
@@ -62,56 +79,274 @@ type Emulator struct {
 
 	// Debugging info
 	Disassembly map[uint16]string
+	TracePath   string // file the trace log is written to, see: ToggleTrace
+	traceFile   *os.File
+	traceWriter *bufio.Writer // buffers the trace, which gets a line per instruction
+
+	ScreenshotDir string // directory the screenshots and recordings are saved to, see: SaveScreenshot
+	recordingPath string // file of the recording in progress, see: ToggleRecording
+
+	// Input movies
+	MoviePath string // FM2 file movies are recorded to and played back from, see: ToggleMovieRecording
+	movie     *nes.Movie
+
+	// Video output
+	ntscFilter *nes.NTSCFilter
+
+	// Input
+	game   string         // file name of the ROM, selecting the per-game bindings
+	inputs [2]playerInput // bindings of the players, see: updateInputs
+	rebind rebindScreen
+	zapper *nes.Zapper // input device other than the standard controllers
 }
 
 func NewEmulator() *Emulator {
-	return &Emulator{
+	return NewEmulatorWithMode(Normal)
+}
+
+// NewEmulatorWithMode creates an emulator in the given mode.
+// Outside of test mode, the config file is loaded from DefaultConfigPath.
+func NewEmulatorWithMode(mode Mode) *Emulator {
+	e := &Emulator{
 		VM: nes.NewVM(),
 
-		Mode:  Normal,
-		State: Init,
+		Mode:   mode,
+		Scale:  windowScale,
+		State:  Init,
+		Config: DefaultConfig(),
+
+		TracePath: "trace.log",
+		MoviePath: "movie.fm2",
 	}
+
+	if mode != Test {
+		e.ConfigPath = DefaultConfigPath()
+		config, err := LoadConfig(e.ConfigPath)
+		if err != nil {
+			log.Println(err)
+		}
+		e.ApplyConfig(config)
+	}
+
+	return e
 }
 
-func NewEmulatorWithMode(mode Mode) *Emulator {
-	return &Emulator{
-		VM: nes.NewVM(),
+// ApplyConfig replaces the current config and applies its settings to the VM.
+func (e *Emulator) ApplyConfig(config Config) {
+	e.Config = config
+
+	palette, err := config.Palette.Palette()
+	if err != nil {
+		log.Printf("%v, falling back to the default palette", err)
+		palette = nes.DefaultPalette()
+	}
+	e.VM.SetPalette(palette)
 
-		Mode:  mode,
-		State: Init,
+	filter, err := config.Video.NTSCFilter()
+	if err != nil {
+		log.Printf("%v, disabling the video filter", err)
 	}
+	e.ntscFilter = filter
+
+	e.VM.SetPowerOnState(config.PowerOn)
+	e.SetZapper(config.Input.Zapper)
+	e.VM.SetInputFilter(config.Input.Filter())
+	e.updateInputs()
 }
 
-func (e *Emulator) UpdateVMInputs() {
-	input := uint8(0)
-	if ebiten.IsKeyPressed(ebiten.KeyX) {
-		input |= 0x80
+// SetZapper plugs a Zapper into port 2, aimed with the mouse and fired with the left button,
+// or plugs the standard controller back in.
+func (e *Emulator) SetZapper(enabled bool) {
+	if enabled == (e.zapper != nil) {
+		return
+	}
+	if enabled {
+		e.zapper = nes.NewZapper()
+		e.VM.ConnectInputDevice(1, e.zapper)
+	} else {
+		e.zapper = nil
+		e.VM.ConnectInputDevice(1, nes.NewController())
+	}
+}
+
+// SaveConfig persists the current config to ConfigPath, if there is one.
+func (e *Emulator) SaveConfig() error {
+	if e.ConfigPath == "" {
+		return nil
+	}
+	return e.Config.Save(e.ConfigPath)
+}
+
+// UseDefaultPalette switches to the built-in palette and persists the choice.
+func (e *Emulator) UseDefaultPalette() error {
+	e.Config.Palette.Source = DefaultPalette
+	e.VM.SetPalette(nes.DefaultPalette())
+	return e.SaveConfig()
+}
+
+// UseGeneratedPalette switches to a palette generated with the given params and persists the choice.
+func (e *Emulator) UseGeneratedPalette(params nes.PaletteParams) error {
+	e.Config.Palette.Source = GeneratedPalette
+	e.Config.Palette.Generator = params
+	e.VM.SetPalette(nes.GeneratePalette(params))
+	return e.SaveConfig()
+}
+
+// SetVideoFilter switches the NTSC filter applied to the screen and persists the choice.
+func (e *Emulator) SetVideoFilter(filter VideoFilter) error {
+	ntscFilter, err := VideoConfig{Filter: filter}.NTSCFilter()
+	if err != nil {
+		return err
+	}
+	e.Config.Video.Filter = filter
+	e.ntscFilter = ntscFilter
+	ebiten.SetWindowSize(e.windowWidth()*e.Scale, windowHeight*e.Scale)
+	return e.SaveConfig()
+}
+
+// UsePaletteFile switches to the palette in the given .pal file and persists the choice.
+func (e *Emulator) UsePaletteFile(filePath string) error {
+	palette, err := nes.LoadPalette(filePath)
+	if err != nil {
+		return err
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyZ) {
-		input |= 0x40
+	e.Config.Palette.Source = FilePalette
+	e.Config.Palette.File = filePath
+	e.VM.SetPalette(palette)
+	return e.SaveConfig()
+}
+
+// ToggleTrace starts or stops logging the executed instructions to TracePath.
+// The file is truncated when the trace is first started, later toggles append to it.
+// The buffered lines are written out when the trace is stopped, and the file is closed on exit, see: closeTrace
+func (e *Emulator) ToggleTrace() error {
+	if e.traceFile == nil {
+		file, err := os.Create(e.TracePath)
+		if err != nil {
+			return err
+		}
+		e.traceFile = file
+		e.traceWriter = bufio.NewWriter(file)
+		tracer := nes.NewTraceLogger(e.traceWriter)
+		// label the trace when symbols were loaded along with the ROM
+		tracer.SetLabels(e.VM.Symbols().Len() > 0)
+		e.VM.SetTraceLogger(tracer)
+		return nil
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyA) {
-		input |= 0x20
+	tracer := e.VM.TraceLogger()
+	tracer.SetEnabled(!tracer.Enabled())
+	if err := tracer.Err(); err != nil {
+		return err
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyS) {
-		input |= 0x10
+	if !tracer.Enabled() {
+		return e.traceWriter.Flush()
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		input |= 0x08
+	return nil
+}
+
+// closeTrace writes out the rest of the trace and closes its file, if the trace was ever started.
+func (e *Emulator) closeTrace() error {
+	if e.traceFile == nil {
+		return nil
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		input |= 0x04
+	err := e.traceWriter.Flush()
+	if closeErr := e.traceFile.Close(); err == nil {
+		err = closeErr
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-		input |= 0x02
+	e.traceFile = nil
+	e.traceWriter = nil
+	e.VM.SetTraceLogger(nil)
+	return err
+}
+
+// capturePath returns the path of a new capture file in ScreenshotDir, named after the game and the time.
+func (e *Emulator) capturePath(ext string) string {
+	name := strings.TrimSuffix(e.game, filepath.Ext(e.game))
+	if name == "" {
+		name = "screenshot"
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyRight) {
-		input |= 0x01
+	return filepath.Join(e.ScreenshotDir, fmt.Sprintf("%s-%s%s", name, time.Now().Format("20060102-150405"), ext))
+}
+
+// SaveScreenshot writes the screen to a PNG file in ScreenshotDir, see: capturePath
+func (e *Emulator) SaveScreenshot() error {
+	filePath := e.capturePath(".png")
+	if err := e.VM.SaveScreenshot(filePath, e.Config.Video.Screenshot); err != nil {
+		return err
 	}
-	e.VM.SetControllerState(input)
+	log.Printf("saved screenshot to %s", filePath)
+	return nil
+}
+
+// ToggleRecording starts recording the frames to a file in ScreenshotDir, see: capturePath, or stops the recording
+// in progress. The extension chooses the format, .gif for short clips or .y4m for longer ones, see: nes.CreateRecording
+func (e *Emulator) ToggleRecording(ext string) error {
+	if e.VM.Recording() {
+		err := e.VM.StopRecording()
+		if err == nil {
+			log.Printf("saved recording to %s", e.recordingPath)
+		}
+		return err
+	}
+	e.recordingPath = e.capturePath(ext)
+	recorder, err := nes.CreateRecording(e.recordingPath, e.VM.Region().FrameRate(), e.Config.Video.Screenshot)
+	if err != nil {
+		return err
+	}
+	return e.VM.StartRecording(recorder, e.Config.Video.Screenshot)
 }
 
-// Update will run at exactly 60Hz
+// ToggleMovieRecording starts recording a movie from power-on, or stops the recording and saves it to MoviePath.
+func (e *Emulator) ToggleMovieRecording() error {
+	if e.VM.MovieRecording() {
+		e.VM.StopMovie()
+		log.Printf("recorded %d frames to %s", len(e.movie.Frames), e.MoviePath)
+		return e.movie.SaveFM2(e.MoviePath)
+	}
+	e.movie = &nes.Movie{ROMName: strings.TrimSuffix(e.game, filepath.Ext(e.game))}
+	e.VM.RecordMovie(e.movie)
+	return nil
+}
+
+// PlayMovie plays back the movie at MoviePath from power-on, or stops the movie being played back.
+func (e *Emulator) PlayMovie() error {
+	if e.VM.MoviePlaying() {
+		e.VM.StopMovie()
+		return nil
+	}
+	if e.VM.MovieRecording() {
+		if err := e.ToggleMovieRecording(); err != nil {
+			return err
+		}
+	}
+	movie, err := nes.LoadFM2(e.MoviePath)
+	if err != nil {
+		return err
+	}
+	e.movie = movie
+	e.VM.PlayMovie(movie)
+	return nil
+}
+
+// UpdateVMInputs sets the state of the controllers from the inputs bound to their buttons, see: InputConfig
+func (e *Emulator) UpdateVMInputs() {
+	gamepads := ebiten.AppendGamepadIDs(nil)
+	for port, input := range e.inputs {
+		buttons, turbo := input.state(gamepads)
+		e.VM.SetControllerState(port, buttons)
+		e.VM.SetTurboState(port, turbo)
+	}
+
+	if e.zapper != nil {
+		// the filtered screen is wider than the NES screen
+		x, y := ebiten.CursorPosition()
+		x = int(math.Floor(float64(x-screenX) * nes.ScreenWidth / float64(e.screenWidth())))
+		e.zapper.Aim(x, y-screenY)
+		e.zapper.SetTrigger(ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft))
+	}
+}
+
+// Update will run at the frame rate of the VM's region, see: Start
 func (e *Emulator) Update() error {
 	ebiten.SetWindowTitle(fmt.Sprintf("NES Emulator in Go! TPS: %v FPS: %v", ebiten.ActualTPS(), ebiten.ActualFPS()))
 	switch e.State {
@@ -120,8 +355,10 @@ func (e *Emulator) Update() error {
 			e.State = Stepping
 		}
 	case Running:
-		for i := 0; i < cpuClockSpeed/60; i++ {
-			e.VM.Step()
+		e.VM.StepFrame()
+		if hit, ok := e.VM.Debugger().Hit(); ok {
+			log.Printf("break at 0x%04X: %s", hit.PC, hit.ToString())
+			e.State = Stepping
 		}
 
 		if ebiten.IsKeyPressed(ebiten.KeyP) {
@@ -138,6 +375,48 @@ func (e *Emulator) Update() error {
 			e.IsKeyPressed = true
 			e.IsDebugMode = !e.IsDebugMode
 		}
+		if ebiten.IsKeyPressed(ebiten.KeyF3) {
+			e.PrevState = Running
+			e.State = Bindings
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF9) {
+			if err := e.SaveScreenshot(); err != nil {
+				log.Println(err)
+			}
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF10) {
+			if err := e.ToggleRecording(".gif"); err != nil {
+				log.Println(err)
+			}
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+			if err := e.ToggleRecording(".y4m"); err != nil {
+				log.Println(err)
+			}
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF7) {
+			e.VM.Reset()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF8) {
+			e.VM.PowerCycle()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+			if err := e.ToggleMovieRecording(); err != nil {
+				log.Println(err)
+			}
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF6) {
+			if err := e.PlayMovie(); err != nil {
+				log.Println(err)
+			}
+		}
+
+		if !e.IsKeyPressed && ebiten.IsKeyPressed(ebiten.KeyT) {
+			e.IsKeyPressed = true
+			if err := e.ToggleTrace(); err != nil {
+				log.Println(err)
+			}
+		}
 
 		if !e.IsKeyPressed && ebiten.IsKeyPressed(ebiten.KeyPeriod) {
 			e.IsKeyPressed = true
@@ -153,7 +432,7 @@ func (e *Emulator) Update() error {
 			fmt.Println("debugPatternId", debugPatternId)
 		}
 
-		if !ebiten.IsKeyPressed(ebiten.KeyPeriod) && !ebiten.IsKeyPressed(ebiten.KeyComma) && !ebiten.IsKeyPressed(ebiten.KeyTab) {
+		if !ebiten.IsKeyPressed(ebiten.KeyPeriod) && !ebiten.IsKeyPressed(ebiten.KeyComma) && !ebiten.IsKeyPressed(ebiten.KeyTab) && !ebiten.IsKeyPressed(ebiten.KeyT) {
 			e.IsKeyPressed = false
 		}
 
@@ -164,11 +443,31 @@ func (e *Emulator) Update() error {
 			e.IsKeyPressed = true
 			e.VM.Step()
 		}
+		if !e.IsKeyPressed && ebiten.IsKeyPressed(ebiten.KeyC) {
+			e.IsKeyPressed = true
+			e.VM.StepCycle()
+		}
 		if !e.IsKeyPressed && ebiten.IsKeyPressed(ebiten.KeyF) {
 			e.IsKeyPressed = true
 			e.VM.StepFrame()
 		}
-		if !ebiten.IsKeyPressed(ebiten.KeySpace) && !ebiten.IsKeyPressed(ebiten.KeyF) && !ebiten.IsKeyPressed(ebiten.KeyTab) {
+		if !e.IsKeyPressed && ebiten.IsKeyPressed(ebiten.KeyB) {
+			e.IsKeyPressed = true
+			e.VM.Debugger().ToggleBreakpoint(e.VM.PeekCPU().PC)
+		}
+		if !e.IsKeyPressed && ebiten.IsKeyPressed(ebiten.KeyT) {
+			e.IsKeyPressed = true
+			if err := e.ToggleTrace(); err != nil {
+				log.Println(err)
+			}
+		}
+		if !e.IsKeyPressed && ebiten.IsKeyPressed(ebiten.KeyO) {
+			// step out of the current subroutine
+			e.IsKeyPressed = true
+			e.VM.Debugger().RunUntilReturn()
+			e.State = Running
+		}
+		if !ebiten.IsKeyPressed(ebiten.KeySpace) && !ebiten.IsKeyPressed(ebiten.KeyC) && !ebiten.IsKeyPressed(ebiten.KeyF) && !ebiten.IsKeyPressed(ebiten.KeyB) && !ebiten.IsKeyPressed(ebiten.KeyO) && !ebiten.IsKeyPressed(ebiten.KeyT) && !ebiten.IsKeyPressed(ebiten.KeyTab) {
 			e.IsKeyPressed = false
 		}
 		if ebiten.IsKeyPressed(ebiten.KeyF2) {
@@ -190,9 +489,17 @@ func (e *Emulator) Update() error {
 			e.PrevState = Paused
 			e.State = Nametable
 		}
+		if ebiten.IsKeyPressed(ebiten.KeyF3) {
+			e.PrevState = Paused
+			e.State = Bindings
+		}
+	case Bindings:
+		e.updateRebinding()
 	case Nametable:
 		if e.PrevState == Running {
-			for i := 0; i < cpuClockSpeed/600; i++ {
+			// run at a tenth of the normal speed
+			cpuClockSpeed := e.VM.Region().CPUClockSpeed()
+			for i := 0; i < cpuClockSpeed/int(e.VM.Region().FrameRate())/10; i++ {
 				e.VM.Step()
 			}
 		}
@@ -210,12 +517,17 @@ func (e *Emulator) Draw(screen *ebiten.Image) {
 	if e.State == Nametable {
 		e.DrawAllNametables(screen)
 	} else {
-		e.DrawScreenAt(screen, 8, 8)
+		panelX := e.panelX()
+		e.DrawScreenAt(screen, screenX, screenY)
 		e.DrawPaletteTableAt(screen, 8, 256)
 		e.DrawPatternTableAt(screen, 8, 272)
-		e.DrawStateAt(screen, 272, 8)
-		e.DrawCpuAt(screen, 272, 36)
-		e.DrawDisassemblyAt(screen, 272, 128)
+		e.DrawStateAt(screen, panelX, 8)
+		if e.State == Bindings {
+			e.DrawBindingsAt(screen, panelX, 36)
+			return
+		}
+		e.DrawCpuAt(screen, panelX, 36)
+		e.DrawDisassemblyAt(screen, panelX, 128)
 	}
 }
 
@@ -273,16 +585,40 @@ func (e *Emulator) DrawAllNametables(screen *ebiten.Image) {
 
 func (e *Emulator) DrawDisassemblyAt(screen *ebiten.Image, x, y int) {
 	cpu := e.VM.PeekCPU()
+	debugger := e.VM.Debugger()
 
-	ebitenutil.DebugPrintAt(screen, "Disassembly:", x, y)
+	if _, ok := e.Disassembly[cpu.PC]; !ok {
+		// the code was not reached from the interrupt vectors, e.g. it was jumped to indirectly
+		e.Disassembly = e.VM.PeekDisassembly()
+	}
+
+	// breakpoints are marked with a *, and a break is highlighted in red along with its reason
+	line := func(addr uint16) string {
+		marker := " "
+		if debugger.HasBreakpoint(addr) {
+			marker = "*"
+		}
+		return fmt.Sprintf("%s0x%04X: %s", marker, addr, e.Disassembly[addr])
+	}
+	hit, isHit := debugger.Hit()
+
+	if isHit {
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Disassembly: break on %s", hit.ToString()), x, y)
+	} else {
+		ebitenutil.DebugPrintAt(screen, "Disassembly:", x, y)
+	}
 
 	yOffset := 10
 
 	// print middle
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(float64(x), float64(y+yOffset*12+2+4))
-	screen.DrawImage(disassemblyHighlightImage, op)
-	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("0x%04X: %s", cpu.PC, e.Disassembly[cpu.PC]), x, y+yOffset*12+4)
+	if isHit && hit.PC == cpu.PC {
+		screen.DrawImage(disassemblyBreakImage, op)
+	} else {
+		screen.DrawImage(disassemblyHighlightImage, op)
+	}
+	ebitenutil.DebugPrintAt(screen, line(cpu.PC), x, y+yOffset*12+4)
 
 	// move up
 	for i, o := 0, uint16(1); i < yOffset-1; i, o = i+1, o+1 {
@@ -293,7 +629,7 @@ func (e *Emulator) DrawDisassemblyAt(screen *ebiten.Image, x, y int) {
 				break
 			}
 		}
-		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("0x%04X: %s", cpu.PC-o, e.Disassembly[cpu.PC-o]), x, y+(yOffset-1-i)*12+4)
+		ebitenutil.DebugPrintAt(screen, line(cpu.PC-o), x, y+(yOffset-1-i)*12+4)
 	}
 
 	// move down
@@ -305,7 +641,7 @@ func (e *Emulator) DrawDisassemblyAt(screen *ebiten.Image, x, y int) {
 				break
 			}
 		}
-		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("0x%04X: %s", cpu.PC+o, e.Disassembly[cpu.PC+o]), x, y+(yOffset+1+i)*12+4)
+		ebitenutil.DebugPrintAt(screen, line(cpu.PC+o), x, y+(yOffset+1+i)*12+4)
 	}
 
 }
@@ -381,51 +717,50 @@ func (e *Emulator) DrawPatternTableAt(screen *ebiten.Image, x, y int) {
 
 func (e *Emulator) DrawScreenAt(screen *ebiten.Image, x, y int) {
 
-	vmScreen := e.VM.GetScreen()
-
-	var pixels []byte
-	for py := 0; py < 240; py++ {
-		for px := 0; px < 256; px++ {
-			r, g, b, a := vmScreen[px][py].RGBA()
-			pixels = append(pixels, uint8(r))
-			pixels = append(pixels, uint8(g))
-			pixels = append(pixels, uint8(b))
-			pixels = append(pixels, uint8(a))
-		}
-	}
+	op := &ebiten.DrawImageOptions{}
 
-	screenImage.WritePixels(pixels)
+	if e.ntscFilter != nil {
+		// the filtered image is drawn at its native width, see: screenWidth
+		e.ntscFilter.Filter(e.VM.FrameIndices(), e.VM.FrameBurstPhase(), ntscPixels)
+		ntscImage.WritePixels(ntscPixels)
 
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(x), float64(y))
+		op.GeoM.Translate(float64(x), float64(y))
+		screen.DrawImage(ntscImage, op)
+	} else {
+		e.VM.FrameRGBA(screenPixels)
+		screenImage.WritePixels(screenPixels)
 
-	screen.DrawImage(screenImage, op)
+		op.GeoM.Translate(float64(x), float64(y))
+		screen.DrawImage(screenImage, op)
+	}
 
 	clr8 := color.RGBA{R: 255, G: 255, B: 255, A: 50}
 	clr32 := color.RGBA{R: 255, G: 255, B: 0, A: 150}
 
 	if e.IsDebugMode {
 		attrTable := e.VM.GetPPUAttributeTable(0)
+		pixelWidth := float64(e.screenWidth()) / nes.ScreenWidth
 
 		for dx := 0; dx <= 256; dx += 8 {
 			clr := clr8
 			if dx%32 == 0 {
 				clr = clr32
 			}
-			ebitenutil.DrawLine(screen, float64(x+dx), float64(y), float64(x+dx), float64(y)+240, clr)
+			lineX := float64(x) + float64(dx)*pixelWidth
+			ebitenutil.DrawLine(screen, lineX, float64(y), lineX, float64(y)+240, clr)
 		}
 		for dy := 0; dy <= 240; dy += 8 {
 			clr := clr8
 			if dy%32 == 0 {
 				clr = clr32
 			}
-			ebitenutil.DrawLine(screen, float64(x), float64(y+dy), float64(x)+256, float64(y+dy), clr)
+			ebitenutil.DrawLine(screen, float64(x), float64(y+dy), float64(x+e.screenWidth()), float64(y+dy), clr)
 		}
 
 		for dy := 0; dy < 240; dy += 32 {
 			for dx := 0; dx < 256; dx += 32 {
 				idx := (dx / 32) + (dy/32)*8
-				ebitenutil.DebugPrintAt(screen, fmt.Sprintf("0x%02X", attrTable[idx]), x+dx, y+dy)
+				ebitenutil.DebugPrintAt(screen, fmt.Sprintf("0x%02X", attrTable[idx]), x+int(float64(dx)*pixelWidth), y+dy)
 			}
 		}
 
@@ -460,13 +795,32 @@ func (e *Emulator) DrawStateAt(screen *ebiten.Image, x, y int) {
 	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("State: %v", e.State), x, y+12)
 }
 
+// screenWidth returns the width the NES screen is drawn at, the output of the NTSC filter being wider.
+func (e *Emulator) screenWidth() int {
+	if e.ntscFilter != nil {
+		return nes.NTSCOutputWidth
+	}
+	return nes.ScreenWidth
+}
+
+// panelX returns the position of the debug panels, right of the screen.
+func (e *Emulator) panelX() int {
+	return screenX + e.screenWidth() + 8
+}
+
+// windowWidth returns the width of the window before scaling, see: Emulator.Scale
+func (e *Emulator) windowWidth() int {
+	return e.panelX() + panelWidth
+}
+
 func (e *Emulator) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	return outsideWidth / windowScale, outsideHeight / windowScale
+	return outsideWidth / e.Scale, outsideHeight / e.Scale
 }
 
 func (e *Emulator) Start() {
 	ebiten.SetWindowTitle("NES Emulator in Go!")
-	ebiten.SetWindowSize(windowWidth, windowHeight)
+	ebiten.SetWindowSize(e.windowWidth()*e.Scale, windowHeight*e.Scale)
+	ebiten.SetTPS(int(math.Round(e.VM.Region().FrameRate())))
 
 	e.Disassembly = e.VM.PeekDisassembly()
 	disassemblyHighlightImage.Fill(color.RGBA{
@@ -475,6 +829,12 @@ func (e *Emulator) Start() {
 		B: 100,
 		A: 255,
 	})
+	disassemblyBreakImage.Fill(color.RGBA{
+		R: 120,
+		G: 0,
+		B: 0,
+		A: 255,
+	})
 
 	for px := 0; px < 32; px++ {
 		for py := 0; py < 8; py++ {
@@ -484,17 +844,26 @@ func (e *Emulator) Start() {
 		}
 	}
 
-	if err := ebiten.RunGame(e); err != nil {
+	err := ebiten.RunGame(e)
+	if traceErr := e.closeTrace(); traceErr != nil {
+		log.Println(traceErr)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 func (e *Emulator) StartWithROM(filePath string) {
 	e.VM.LoadROM(filePath)
+	e.game = filepath.Base(filePath)
+	e.updateInputs()
+	if err := e.VM.LoadSymbolsForROM(filePath); err != nil {
+		log.Println(err)
+	}
 	if e.Mode == Automation {
 		e.VM.ForceSetResetVector(0xC000)
 	}
-	e.VM.Reset()
+	e.VM.PowerCycle()
 	e.Start()
 }
 
@@ -0,0 +1,266 @@
+// Standard gamepad layout Reference: https://www.w3.org/TR/gamepad/#remapping
+
+package emulator
+
+import (
+	"fmt"
+	"go-nes/nes"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Binding is an input of the host bound to an NES button, as named in the config file:
+// a key, e.g. "Key:X", a button of a gamepad with the standard layout, e.g. "Pad:RightBottom",
+// or a direction of one of its axes, e.g. "Axis:LeftStickHorizontal-".
+type Binding string
+
+// PlayerBindings binds the inputs of the host to the buttons of the controller of one player.
+type PlayerBindings struct {
+	Gamepad int                  `json:"gamepad"` // index of the gamepad among the connected ones
+	Buttons map[string][]Binding `json:"buttons"` // by button name, see: ButtonNames
+}
+
+// GameBindings overrides the bindings of the buttons it lists, for a single game.
+type GameBindings struct {
+	Players [2]map[string][]Binding `json:"players"`
+}
+
+// ButtonNames lists the names of the NES buttons, as used in the bindings.
+// TurboA and TurboB hold A and B down with turbo, see: nes.InputFilter
+var ButtonNames = []string{"A", "B", "Select", "Start", "Up", "Down", "Left", "Right", "TurboA", "TurboB"}
+
+var buttonMasks = map[string]uint8{
+	"A":      nes.ButtonA,
+	"B":      nes.ButtonB,
+	"Select": nes.ButtonSelect,
+	"Start":  nes.ButtonStart,
+	"Up":     nes.ButtonUp,
+	"Down":   nes.ButtonDown,
+	"Left":   nes.ButtonLeft,
+	"Right":  nes.ButtonRight,
+}
+
+var turboMasks = map[string]uint8{
+	"TurboA": nes.ButtonA,
+	"TurboB": nes.ButtonB,
+}
+
+var gamepadButtonNames = map[ebiten.StandardGamepadButton]string{
+	ebiten.StandardGamepadButtonRightBottom:      "RightBottom",
+	ebiten.StandardGamepadButtonRightRight:       "RightRight",
+	ebiten.StandardGamepadButtonRightLeft:        "RightLeft",
+	ebiten.StandardGamepadButtonRightTop:         "RightTop",
+	ebiten.StandardGamepadButtonFrontTopLeft:     "FrontTopLeft",
+	ebiten.StandardGamepadButtonFrontTopRight:    "FrontTopRight",
+	ebiten.StandardGamepadButtonFrontBottomLeft:  "FrontBottomLeft",
+	ebiten.StandardGamepadButtonFrontBottomRight: "FrontBottomRight",
+	ebiten.StandardGamepadButtonCenterLeft:       "CenterLeft",
+	ebiten.StandardGamepadButtonCenterRight:      "CenterRight",
+	ebiten.StandardGamepadButtonLeftStick:        "LeftStick",
+	ebiten.StandardGamepadButtonRightStick:       "RightStick",
+	ebiten.StandardGamepadButtonLeftTop:          "LeftTop",
+	ebiten.StandardGamepadButtonLeftBottom:       "LeftBottom",
+	ebiten.StandardGamepadButtonLeftLeft:         "LeftLeft",
+	ebiten.StandardGamepadButtonLeftRight:        "LeftRight",
+	ebiten.StandardGamepadButtonCenterCenter:     "CenterCenter",
+}
+
+var gamepadAxisNames = map[ebiten.StandardGamepadAxis]string{
+	ebiten.StandardGamepadAxisLeftStickHorizontal:  "LeftStickHorizontal",
+	ebiten.StandardGamepadAxisLeftStickVertical:    "LeftStickVertical",
+	ebiten.StandardGamepadAxisRightStickHorizontal: "RightStickHorizontal",
+	ebiten.StandardGamepadAxisRightStickVertical:   "RightStickVertical",
+}
+
+// axisThreshold is how far an axis must be pushed to press the button bound to it.
+const axisThreshold = 0.5
+
+func KeyBinding(key ebiten.Key) Binding {
+	return Binding("Key:" + key.String())
+}
+
+func GamepadButtonBinding(button ebiten.StandardGamepadButton) Binding {
+	return Binding("Pad:" + gamepadButtonNames[button])
+}
+
+// GamepadAxisBinding binds the given axis, pushed in the direction of the sign of the given value.
+func GamepadAxisBinding(axis ebiten.StandardGamepadAxis, value float64) Binding {
+	if value < 0 {
+		return Binding("Axis:" + gamepadAxisNames[axis] + "-")
+	}
+	return Binding("Axis:" + gamepadAxisNames[axis] + "+")
+}
+
+// DefaultPlayerBindings returns the bindings of the given player: the keyboard for the first player,
+// and the gamepad with the same index as the player for both.
+func DefaultPlayerBindings(player int) PlayerBindings {
+	bindings := PlayerBindings{
+		Gamepad: player,
+		Buttons: map[string][]Binding{
+			"A":      {GamepadButtonBinding(ebiten.StandardGamepadButtonRightBottom)},
+			"B":      {GamepadButtonBinding(ebiten.StandardGamepadButtonRightLeft)},
+			"Select": {GamepadButtonBinding(ebiten.StandardGamepadButtonCenterLeft)},
+			"Start":  {GamepadButtonBinding(ebiten.StandardGamepadButtonCenterRight)},
+			"TurboA": {GamepadButtonBinding(ebiten.StandardGamepadButtonRightRight)},
+			"TurboB": {GamepadButtonBinding(ebiten.StandardGamepadButtonRightTop)},
+			"Up":     {GamepadButtonBinding(ebiten.StandardGamepadButtonLeftTop), GamepadAxisBinding(ebiten.StandardGamepadAxisLeftStickVertical, -1)},
+			"Down":   {GamepadButtonBinding(ebiten.StandardGamepadButtonLeftBottom), GamepadAxisBinding(ebiten.StandardGamepadAxisLeftStickVertical, 1)},
+			"Left":   {GamepadButtonBinding(ebiten.StandardGamepadButtonLeftLeft), GamepadAxisBinding(ebiten.StandardGamepadAxisLeftStickHorizontal, -1)},
+			"Right":  {GamepadButtonBinding(ebiten.StandardGamepadButtonLeftRight), GamepadAxisBinding(ebiten.StandardGamepadAxisLeftStickHorizontal, 1)},
+		},
+	}
+	if player == 0 {
+		keys := map[string]ebiten.Key{
+			"A":      ebiten.KeyX,
+			"B":      ebiten.KeyZ,
+			"Select": ebiten.KeyA,
+			"Start":  ebiten.KeyS,
+			"Up":     ebiten.KeyArrowUp,
+			"Down":   ebiten.KeyArrowDown,
+			"Left":   ebiten.KeyArrowLeft,
+			"Right":  ebiten.KeyArrowRight,
+		}
+		for name, key := range keys {
+			bindings.Buttons[name] = append([]Binding{KeyBinding(key)}, bindings.Buttons[name]...)
+		}
+	}
+	return bindings
+}
+
+// PlayerBindings returns the bindings of the given player in the given game, with the overrides of the game applied.
+func (c InputConfig) PlayerBindings(player int, game string) PlayerBindings {
+	bindings := PlayerBindings{
+		Gamepad: c.Players[player].Gamepad,
+		Buttons: make(map[string][]Binding),
+	}
+	for name, inputs := range c.Players[player].Buttons {
+		bindings.Buttons[name] = inputs
+	}
+	if override, ok := c.Games[game]; ok {
+		for name, inputs := range override.Players[player] {
+			bindings.Buttons[name] = inputs
+		}
+	}
+	return bindings
+}
+
+// hostInput is a parsed Binding.
+type hostInput struct {
+	key    ebiten.Key
+	button ebiten.StandardGamepadButton
+	axis   ebiten.StandardGamepadAxis
+	kind   string // Key, Pad or Axis
+	sign   float64
+}
+
+func parseBinding(binding Binding) (hostInput, error) {
+	kind, name, _ := strings.Cut(string(binding), ":")
+	input := hostInput{kind: kind}
+	switch kind {
+	case "Key":
+		if err := input.key.UnmarshalText([]byte(name)); err != nil {
+			return input, fmt.Errorf("unknown key in binding %q", binding)
+		}
+		return input, nil
+	case "Pad":
+		for button, buttonName := range gamepadButtonNames {
+			if buttonName == name {
+				input.button = button
+				return input, nil
+			}
+		}
+		return input, fmt.Errorf("unknown gamepad button in binding %q", binding)
+	case "Axis":
+		switch {
+		case strings.HasSuffix(name, "+"):
+			input.sign = 1
+		case strings.HasSuffix(name, "-"):
+			input.sign = -1
+		default:
+			return input, fmt.Errorf("missing axis direction in binding %q", binding)
+		}
+		name = name[:len(name)-1]
+		for axis, axisName := range gamepadAxisNames {
+			if axisName == name {
+				input.axis = axis
+				return input, nil
+			}
+		}
+		return input, fmt.Errorf("unknown gamepad axis in binding %q", binding)
+	}
+	return input, fmt.Errorf("invalid binding %q", binding)
+}
+
+// pressed returns true if the input is held down, the gamepad inputs are read from the given gamepad, if there is one.
+func (i hostInput) pressed(gamepad ebiten.GamepadID, hasGamepad bool) bool {
+	switch i.kind {
+	case "Key":
+		return ebiten.IsKeyPressed(i.key)
+	case "Pad":
+		return hasGamepad && ebiten.IsStandardGamepadButtonPressed(gamepad, i.button)
+	case "Axis":
+		return hasGamepad && ebiten.StandardGamepadAxisValue(gamepad, i.axis)*i.sign > axisThreshold
+	}
+	return false
+}
+
+// playerInput is the parsed form of PlayerBindings, polled every frame to build the state of a controller.
+type playerInput struct {
+	gamepad int
+	buttons map[uint8][]hostInput
+	turbo   map[uint8][]hostInput
+}
+
+// newPlayerInput parses the given bindings, bindings that are not valid are skipped and returned as an error.
+func newPlayerInput(bindings PlayerBindings) (playerInput, error) {
+	input := playerInput{gamepad: bindings.Gamepad, buttons: make(map[uint8][]hostInput), turbo: make(map[uint8][]hostInput)}
+	var invalid []string
+	for name, bound := range bindings.Buttons {
+		buttons := input.buttons
+		mask, ok := buttonMasks[name]
+		if !ok {
+			buttons = input.turbo
+			mask, ok = turboMasks[name]
+		}
+		if !ok {
+			invalid = append(invalid, fmt.Sprintf("unknown button %q", name))
+			continue
+		}
+		for _, binding := range bound {
+			parsed, err := parseBinding(binding)
+			if err != nil {
+				invalid = append(invalid, err.Error())
+				continue
+			}
+			buttons[mask] = append(buttons[mask], parsed)
+		}
+	}
+	if len(invalid) > 0 {
+		return input, fmt.Errorf("invalid input bindings: %s", strings.Join(invalid, ", "))
+	}
+	return input, nil
+}
+
+// state returns the buttons held down by the player, and those held down with turbo, see: nes.ButtonA
+func (p playerInput) state(gamepads []ebiten.GamepadID) (uint8, uint8) {
+	var gamepad ebiten.GamepadID
+	hasGamepad := p.gamepad >= 0 && p.gamepad < len(gamepads) && ebiten.IsStandardGamepadLayoutAvailable(gamepads[p.gamepad])
+	if hasGamepad {
+		gamepad = gamepads[p.gamepad]
+	}
+
+	pressed := func(bound map[uint8][]hostInput) uint8 {
+		buttons := uint8(0)
+		for mask, inputs := range bound {
+			for _, input := range inputs {
+				if input.pressed(gamepad, hasGamepad) {
+					buttons |= mask
+					break
+				}
+			}
+		}
+		return buttons
+	}
+	return pressed(p.buttons), pressed(p.turbo)
+}
@@ -0,0 +1,162 @@
+package emulator
+
+import (
+	"go-nes/nes"
+	"path/filepath"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestParseBinding(t *testing.T) {
+	tests := []struct {
+		binding Binding
+		want    hostInput
+	}{
+		{"Key:X", hostInput{kind: "Key", key: ebiten.KeyX}},
+		{"Key:ArrowUp", hostInput{kind: "Key", key: ebiten.KeyArrowUp}},
+		{"Pad:RightBottom", hostInput{kind: "Pad", button: ebiten.StandardGamepadButtonRightBottom}},
+		{"Pad:CenterRight", hostInput{kind: "Pad", button: ebiten.StandardGamepadButtonCenterRight}},
+		{"Axis:LeftStickVertical-", hostInput{kind: "Axis", axis: ebiten.StandardGamepadAxisLeftStickVertical, sign: -1}},
+		{"Axis:RightStickHorizontal+", hostInput{kind: "Axis", axis: ebiten.StandardGamepadAxisRightStickHorizontal, sign: 1}},
+	}
+	for _, test := range tests {
+		got, err := parseBinding(test.binding)
+		if err != nil {
+			t.Errorf("%s: %v", test.binding, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %+v, expected %+v", test.binding, got, test.want)
+		}
+	}
+
+	invalid := []Binding{"", "X", "Key:NoSuchKey", "Pad:NoSuchButton", "Axis:LeftStickVertical", "Axis:NoSuchAxis+", "Mouse:Left"}
+	for _, binding := range invalid {
+		if _, err := parseBinding(binding); err == nil {
+			t.Errorf("%q: expected an error", binding)
+		}
+	}
+}
+
+func TestBindingRoundTrip(t *testing.T) {
+	bindings := []Binding{
+		KeyBinding(ebiten.KeyZ),
+		GamepadButtonBinding(ebiten.StandardGamepadButtonLeftTop),
+		GamepadAxisBinding(ebiten.StandardGamepadAxisLeftStickHorizontal, -0.8),
+		GamepadAxisBinding(ebiten.StandardGamepadAxisLeftStickHorizontal, 0.8),
+	}
+	for _, binding := range bindings {
+		if _, err := parseBinding(binding); err != nil {
+			t.Errorf("%s: %v", binding, err)
+		}
+	}
+	for player := range [2]int{} {
+		if _, err := newPlayerInput(DefaultPlayerBindings(player)); err != nil {
+			t.Errorf("default bindings of player %d: %v", player+1, err)
+		}
+	}
+}
+
+func TestNewPlayerInput(t *testing.T) {
+	input, err := newPlayerInput(PlayerBindings{
+		Gamepad: 1,
+		Buttons: map[string][]Binding{
+			"A":      {"Key:X", "Pad:RightBottom"},
+			"Up":     {"Axis:LeftStickVertical-"},
+			"Start":  {},
+			"TurboB": {"Key:Z"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.gamepad != 1 {
+		t.Errorf("gamepad: got %d, expected 1", input.gamepad)
+	}
+	if len(input.buttons[nes.ButtonA]) != 2 {
+		t.Errorf("A: got %d inputs, expected 2", len(input.buttons[nes.ButtonA]))
+	}
+	if len(input.buttons[nes.ButtonUp]) != 1 || input.buttons[nes.ButtonUp][0].sign != -1 {
+		t.Errorf("Up: got %+v, expected the left stick pushed up", input.buttons[nes.ButtonUp])
+	}
+	if len(input.buttons[nes.ButtonStart]) != 0 {
+		t.Errorf("Start: got %+v, expected no inputs", input.buttons[nes.ButtonStart])
+	}
+	if len(input.turbo[nes.ButtonB]) != 1 || len(input.buttons[nes.ButtonB]) != 0 {
+		t.Errorf("TurboB: got turbo %+v and buttons %+v, expected a turbo input only", input.turbo[nes.ButtonB], input.buttons[nes.ButtonB])
+	}
+
+	// invalid bindings are skipped, the valid ones are still used
+	input, err = newPlayerInput(PlayerBindings{
+		Buttons: map[string][]Binding{
+			"A":    {"Key:NoSuchKey", "Key:X"},
+			"Jump": {"Key:Space"},
+		},
+	})
+	if err == nil {
+		t.Error("invalid bindings: expected an error")
+	}
+	if len(input.buttons[nes.ButtonA]) != 1 || input.buttons[nes.ButtonA][0].key != ebiten.KeyX {
+		t.Errorf("A: got %+v, expected Key:X", input.buttons[nes.ButtonA])
+	}
+}
+
+func TestPlayerBindings(t *testing.T) {
+	config := InputConfig{
+		Players: [2]PlayerBindings{
+			{Gamepad: 0, Buttons: map[string][]Binding{"A": {"Key:X"}, "B": {"Key:Z"}}},
+			{Gamepad: 1, Buttons: map[string][]Binding{"A": {"Pad:RightBottom"}}},
+		},
+		Games: map[string]GameBindings{
+			"game": {Players: [2]map[string][]Binding{{"A": {"Key:C"}, "B": {}}}},
+		},
+	}
+
+	bindings := config.PlayerBindings(0, "other")
+	if got := bindings.Buttons["A"]; len(got) != 1 || got[0] != "Key:X" {
+		t.Errorf("A of another game: got %v, expected [Key:X]", got)
+	}
+
+	bindings = config.PlayerBindings(0, "game")
+	if got := bindings.Buttons["A"]; len(got) != 1 || got[0] != "Key:C" {
+		t.Errorf("A: got %v, expected [Key:C]", got)
+	}
+	if got, ok := bindings.Buttons["B"]; !ok || len(got) != 0 {
+		t.Errorf("B: got %v, expected no bindings", got)
+	}
+
+	bindings = config.PlayerBindings(1, "game")
+	if bindings.Gamepad != 1 {
+		t.Errorf("gamepad of player 2: got %d, expected 1", bindings.Gamepad)
+	}
+	if got := bindings.Buttons["A"]; len(got) != 1 || got[0] != "Pad:RightBottom" {
+		t.Errorf("A of player 2: got %v, expected [Pad:RightBottom]", got)
+	}
+
+	// the overrides are not written to the config
+	if got := config.Players[0].Buttons["A"]; len(got) != 1 || got[0] != "Key:X" {
+		t.Errorf("A of the config: got %v, expected [Key:X]", got)
+	}
+}
+
+func TestClearedBindingPersists(t *testing.T) {
+	e := &Emulator{Config: DefaultConfig()}
+	e.rebind.button = 0 // A
+	e.clearBindings()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := e.Config.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := config.Input.Players[0].Buttons["A"]; !ok || len(got) != 0 {
+		t.Errorf("A: got %v, expected no bindings", got)
+	}
+	if got := config.Input.Players[0].Buttons["B"]; len(got) == 0 {
+		t.Error("B: expected the default bindings")
+	}
+}
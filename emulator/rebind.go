@@ -0,0 +1,196 @@
+package emulator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// rebindScreen is the state of the Bindings screen, where the bindings of the buttons are changed.
+type rebindScreen struct {
+	player  int
+	button  int  // index into ButtonNames
+	game    bool // edit the overrides of the current game instead of the bindings of all games
+	waiting bool // waiting for the input to bind to the selected button
+}
+
+// updateInputs parses the bindings of the current game, which are polled by UpdateVMInputs.
+func (e *Emulator) updateInputs() {
+	for player := range e.inputs {
+		input, err := newPlayerInput(e.Config.Input.PlayerBindings(player, e.game))
+		if err != nil {
+			log.Printf("player %d: %v", player+1, err)
+		}
+		e.inputs[player] = input
+	}
+}
+
+// updateRebinding handles the input of the Bindings screen, returning to the previous state once the bindings are saved.
+func (e *Emulator) updateRebinding() {
+	r := &e.rebind
+	if r.waiting {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			r.waiting = false
+			return
+		}
+		if binding, gamepad, ok := justPressedBinding(); ok {
+			e.addBinding(binding, gamepad)
+			r.waiting = false
+		}
+		return
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		r.button = (r.button + len(ButtonNames) - 1) % len(ButtonNames)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		r.button = (r.button + 1) % len(ButtonNames)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft), inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		r.player = 1 - r.player
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		r.game = !r.game && e.game != ""
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		r.waiting = true
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace), inpututil.IsKeyJustPressed(ebiten.KeyDelete):
+		e.clearBindings()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEqual):
+		e.Config.Input.TurboRate++
+		e.VM.SetInputFilter(e.Config.Input.Filter())
+	case inpututil.IsKeyJustPressed(ebiten.KeyMinus):
+		if e.Config.Input.TurboRate > 2 {
+			e.Config.Input.TurboRate--
+		}
+		e.VM.SetInputFilter(e.Config.Input.Filter())
+	case inpututil.IsKeyJustPressed(ebiten.KeyO):
+		e.Config.Input.AllowOpposite = !e.Config.Input.AllowOpposite
+		e.VM.SetInputFilter(e.Config.Input.Filter())
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		if err := e.SaveConfig(); err != nil {
+			log.Println(err)
+		}
+		e.State = e.PrevState
+	}
+}
+
+// justPressedBinding returns the key, gamepad button or axis that was pressed in this frame,
+// along with the index of the gamepad it was pressed on, or -1 for a key.
+func justPressedBinding() (Binding, int, bool) {
+	for _, key := range inpututil.AppendPressedKeys(nil) {
+		if inpututil.IsKeyJustPressed(key) {
+			return KeyBinding(key), -1, true
+		}
+	}
+	for index, gamepad := range ebiten.AppendGamepadIDs(nil) {
+		if !ebiten.IsStandardGamepadLayoutAvailable(gamepad) {
+			continue
+		}
+		for button := range gamepadButtonNames {
+			if inpututil.IsStandardGamepadButtonJustPressed(gamepad, button) {
+				return GamepadButtonBinding(button), index, true
+			}
+		}
+		for axis := range gamepadAxisNames {
+			if value := ebiten.StandardGamepadAxisValue(gamepad, axis); math.Abs(value) > axisThreshold {
+				return GamepadAxisBinding(axis, value), index, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// addBinding binds the given input to the selected button, in addition to the inputs already bound to it.
+// Binding a gamepad input for all games also switches the player to that gamepad.
+func (e *Emulator) addBinding(binding Binding, gamepad int) {
+	r := e.rebind
+	name := ButtonNames[r.button]
+	if r.game {
+		override := e.Config.Input.Games[e.game]
+		if override.Players[r.player] == nil {
+			override.Players[r.player] = make(map[string][]Binding)
+		}
+		override.Players[r.player][name] = appendBinding(override.Players[r.player][name], binding)
+		if e.Config.Input.Games == nil {
+			e.Config.Input.Games = make(map[string]GameBindings)
+		}
+		e.Config.Input.Games[e.game] = override
+	} else {
+		player := &e.Config.Input.Players[r.player]
+		if player.Buttons == nil {
+			player.Buttons = make(map[string][]Binding)
+		}
+		player.Buttons[name] = appendBinding(player.Buttons[name], binding)
+		if gamepad >= 0 {
+			player.Gamepad = gamepad
+		}
+	}
+	e.updateInputs()
+}
+
+func appendBinding(bindings []Binding, binding Binding) []Binding {
+	for _, b := range bindings {
+		if b == binding {
+			return bindings
+		}
+	}
+	return append(bindings, binding)
+}
+
+// clearBindings unbinds the selected button, or removes its override for the current game.
+// The button is kept with no bindings rather than removed, otherwise its default bindings come back with LoadConfig.
+func (e *Emulator) clearBindings() {
+	r := e.rebind
+	name := ButtonNames[r.button]
+	if r.game {
+		if override, ok := e.Config.Input.Games[e.game]; ok {
+			delete(override.Players[r.player], name)
+		}
+	} else {
+		player := &e.Config.Input.Players[r.player]
+		if player.Buttons == nil {
+			player.Buttons = make(map[string][]Binding)
+		}
+		player.Buttons[name] = []Binding{}
+	}
+	e.updateInputs()
+}
+
+func (e *Emulator) DrawBindingsAt(screen *ebiten.Image, x, y int) {
+	r := e.rebind
+	scope := "all games"
+	if r.game {
+		scope = e.game
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Bindings of player %d, for %s:", r.player+1, scope), x, y)
+
+	bindings := e.Config.Input.PlayerBindings(r.player, e.game)
+	override := e.Config.Input.Games[e.game].Players[r.player]
+	for i, name := range ButtonNames {
+		marker := " "
+		if i == r.button {
+			marker = ">"
+		}
+		// overrides of the current game are marked with a *
+		if _, ok := override[name]; ok {
+			name += "*"
+		}
+		inputs := make([]string, len(bindings.Buttons[ButtonNames[i]]))
+		for n, binding := range bindings.Buttons[ButtonNames[i]] {
+			inputs[n] = string(binding)
+		}
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%s %-7s %s", marker, name, strings.Join(inputs, ", ")), x, y+(i+2)*12)
+	}
+
+	filter := e.Config.Input.Filter()
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Turbo every %d frames (-/+), opposite directions allowed: %v (O)", filter.TurboRate, filter.AllowOpposite), x, y+(len(ButtonNames)+3)*12)
+
+	help := "Up/Down: button, Left/Right: player, Tab: all games/this game\nEnter: add, Delete: clear, Esc: save and exit"
+	if r.waiting {
+		help = fmt.Sprintf("Press the key or gamepad button to bind to %s, Esc: cancel", ButtonNames[r.button])
+	}
+	ebitenutil.DebugPrintAt(screen, help, x, y+(len(ButtonNames)+5)*12)
+}
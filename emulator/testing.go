@@ -20,6 +20,24 @@ func (e *Emulator) StartWithProgramAsTest(program string, startAddr uint16, cycl
 	return e.VM.PeekCPU(), e.VM.PeekRAM(0x0000, 0x07FF)
 }
 
+// StartWithProgramSourceAsTest will assemble and load the given 6502 source, then execute the given number of instructions.
+// It will return the resulting PeekCPUResult and the CpuRam as an array of bytes.
+func (e *Emulator) StartWithProgramSourceAsTest(source string, cycles int) (nes.PeekCPUResult, []byte) {
+	if e.Mode != Test {
+		panic("Cannot start emulator as test: emulator is not in test mode!")
+	}
+
+	if err := e.VM.LoadProgramSource(source); err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < cycles; i++ {
+		e.VM.Step()
+	}
+
+	return e.VM.PeekCPU(), e.VM.PeekRAM(0x0000, 0x07FF)
+}
+
 func (e *Emulator) StartWithNestestROMAsTest() (nes.PeekCPUResult, []byte) {
 	if e.Mode != Test {
 		panic("Cannot start emulator as test: emulator is not in test mode!")
@@ -27,7 +45,7 @@ func (e *Emulator) StartWithNestestROMAsTest() (nes.PeekCPUResult, []byte) {
 
 	e.VM.LoadROM("roms/nestest.nes")
 	e.VM.ForceSetResetVector(0xC000)
-	e.VM.Reset()
+	e.VM.PowerCycle()
 
 	return e.VM.PeekCPU(), e.VM.PeekRAM(0x0000, 0x07FF)
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-nes/nes"
+	"io"
+	"os"
+	"strconv"
+)
+
+// headlessOptions are the flags of the headless command.
+type headlessOptions struct {
+	rom        string
+	frames     int
+	movie      string
+	screenshot string
+	shot       nes.ScreenshotOptions
+	hash       bool
+	expectHash string
+	ram        string
+	record     string
+}
+
+func parseHeadlessFlags(args []string, stderr io.Writer) (headlessOptions, error) {
+	var o headlessOptions
+	flags := flag.NewFlagSet("headless", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.IntVar(&o.frames, "frames", 60, "number of frames to run, or 0 to run until the end of the movie")
+	flags.StringVar(&o.movie, "movie", "", "FCEUX .fm2 movie to play back from power-on")
+	flags.StringVar(&o.screenshot, "screenshot", "", "write the last frame to this PNG file")
+	flags.IntVar(&o.shot.Scale, "scale", 1, "scale of the screenshot pixels")
+	flags.BoolVar(&o.shot.PixelAspect, "aspect", false, "stretch the screenshot pixels to the 8:7 aspect ratio of a TV")
+	flags.BoolVar(&o.shot.CropOverscan, "crop-overscan", false, "crop the 8 pixels on each edge of the screenshot")
+	flags.BoolVar(&o.hash, "hash", false, "print the hash of the last frame")
+	flags.StringVar(&o.expectHash, "expect-hash", "", "exit with status 1 unless the last frame has this hash")
+	flags.StringVar(&o.ram, "ram", "", "write the 2KB CPU RAM to this file")
+	flags.StringVar(&o.record, "record", "", "record the frames to this .gif or .y4m file, scaled as the screenshot")
+	if err := flags.Parse(args); err != nil {
+		return o, err
+	}
+	if flags.NArg() != 1 {
+		return o, fmt.Errorf("usage: go-nes headless [flags] rom.nes")
+	}
+	o.rom = flags.Arg(0)
+	if o.frames < 0 || o.frames == 0 && o.movie == "" {
+		return o, fmt.Errorf("the number of frames must be positive, or 0 with a movie")
+	}
+	return o, nil
+}
+
+// runHeadless runs a ROM for a number of frames without a window, then writes the requested outputs.
+// It returns the exit status, see: main
+func runHeadless(args []string, stdout, stderr io.Writer) (status int) {
+	o, err := parseHeadlessFlags(args, stderr)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(stderr, err)
+		}
+		return 2
+	}
+
+	// the cartridge panics on invalid ROMs
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(stderr, r)
+			status = 2
+		}
+	}()
+
+	if _, err := os.Stat(o.rom); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	vm := nes.NewVM()
+	vm.LoadROM(o.rom)
+
+	if o.movie != "" {
+		movie, err := nes.LoadFM2(o.movie)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		if o.frames == 0 {
+			o.frames = len(movie.Frames)
+		}
+		if movie.FourScore {
+			adapter := nes.NewFourPlayerAdapter(false)
+			vm.ConnectInputDevice(0, adapter.Port(0))
+			vm.ConnectInputDevice(1, adapter.Port(1))
+		}
+		vm.PlayMovie(movie)
+	} else {
+		vm.PowerCycle()
+	}
+
+	if o.record != "" {
+		recorder, err := nes.CreateRecording(o.record, vm.Region().FrameRate(), o.shot)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		if err := vm.StartRecording(recorder, o.shot); err != nil {
+			fmt.Fprintf(stderr, "error while recording: %v\n", err)
+			return 2
+		}
+	}
+	for i := 0; i < o.frames && !vm.Jammed(); i++ {
+		vm.StepFrame()
+	}
+	if err := vm.StopRecording(); err != nil {
+		fmt.Fprintf(stderr, "error while recording: %v\n", err)
+		return 2
+	}
+
+	if o.screenshot != "" {
+		if err := vm.SaveScreenshot(o.screenshot, o.shot); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	if o.ram != "" {
+		if err := os.WriteFile(o.ram, vm.PeekRAM(0x0000, 0x07FF), 0644); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	hash := fmt.Sprintf("%016x", vm.FrameHash())
+	if o.hash {
+		fmt.Fprintln(stdout, hash)
+	}
+
+	if vm.Jammed() {
+		fmt.Fprintf(stderr, "the CPU jammed at $%04X\n", vm.PeekCPU().PC)
+		return 1
+	}
+	if o.expectHash != "" {
+		expected, err := strconv.ParseUint(o.expectHash, 16, 64)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid hash %q\n", o.expectHash)
+			return 2
+		}
+		if expected != vm.FrameHash() {
+			fmt.Fprintf(stderr, "frame hash %s does not match %016x\n", hash, expected)
+			return 1
+		}
+	}
+	return 0
+}
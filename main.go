@@ -1,16 +1,72 @@
+// Command go-nes runs NES ROMs, in a window or headless.
+//
+// Usage:
+//
+//	go-nes run [-scale 2] [-mode normal|automation] rom.nes
+//	go-nes headless [-frames 60] [-movie input.fm2] [-screenshot out.png [-scale 1] [-aspect] [-crop-overscan]] [-record out.gif|out.y4m] [-hash] [-expect-hash hash] [-ram out.bin] rom.nes
+//
+// The headless runner exits with status 0 when the run succeeds, 1 when the frame hash does not match -expect-hash
+// or the CPU jammed, and 2 on errors.
 package main
 
-import "go-nes/emulator"
+import (
+	"flag"
+	"fmt"
+	"go-nes/emulator"
+	"os"
+)
+
+const usage = `usage:
+  go-nes run [flags] rom.nes        run the ROM in a window
+  go-nes headless [flags] rom.nes   run the ROM for a number of frames without a window
+
+run "go-nes <command> -h" for the flags of a command`
 
 func main() {
-	//emulator.NewEmulator().StartWithROM("roms/Super Mario Bros. (World).nes")
-	//emulator.NewEmulator().StartWithROM("roms/DuckTales (USA).nes")
-	//emulator.NewEmulator().StartWithROM("roms/Ice Climber (U).nes")
-	emulator.NewEmulator().StartWithROM("roms/Donkey Kong (World) (Rev A).nes")
-	//emulator.NewEmulatorWithMode(emulator.Automation).StartWithROM("roms/nestest.nes")
-	//emulator.NewEmulator().StartWithROM("roms/nestest.nes")
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "run":
+		os.Exit(runWindowed(os.Args[2:]))
+	case "headless":
+		os.Exit(runHeadless(os.Args[2:], os.Stdout, os.Stderr))
+	case "-h", "-help", "--help", "help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runWindowed runs a ROM in a window until it is closed.
+func runWindowed(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	scale := flags.Int("scale", 2, "size of the window pixels, in screen pixels")
+	mode := flags.String("mode", string(emulator.Normal), "normal, or automation to start at $C000 as nestest does")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: go-nes run [-scale n] [-mode normal|automation] rom.nes")
+		return 2
+	}
+	if *scale < 1 {
+		fmt.Fprintln(os.Stderr, "the scale must be at least 1")
+		return 2
+	}
+	if emulator.Mode(*mode) != emulator.Normal && emulator.Mode(*mode) != emulator.Automation {
+		fmt.Fprintf(os.Stderr, "unknown mode %q\n", *mode)
+		return 2
+	}
+	if _, err := os.Stat(flags.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
 
-	//program := "A20A8E0000A2038E0100AC0000A900186D010088D0FA8D0200EAEAEA"
-	//startAddr := uint16(0x1000)
-	//emulator.NewEmulator().StartWithProgram(program, startAddr)
+	e := emulator.NewEmulatorWithMode(emulator.Mode(*mode))
+	e.Scale = *scale
+	e.StartWithROM(flags.Arg(0))
+	return 0
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"go-nes/assembler"
 	"go-nes/emulator"
 	"go-nes/nes"
 	"os"
@@ -21,11 +22,27 @@ func TestBasic(t *testing.T) {
 	t.SkipNow()
 	fmt.Println("Running TestBasic...")
 
-	program := "A20A8E0000A2038E0100AC0000A900186D010088D0FA8D0200EAEAEA"
-	startAddr := uint16(0x0000)
+	// multiplies 10 by 3, the absolute addressing keeps the bytes checked below
+	program := `
+		.org $0000
+		LDX #10
+		STX a:$0000
+		LDX #3
+		STX a:$0001
+		LDY a:$0000
+		LDA #0
+		CLC
+loop:	ADC a:$0001
+		DEY
+		BNE loop
+		STA a:$0002
+		NOP
+		NOP
+		NOP
+	`
 	nes := emulator.NewEmulatorWithMode(emulator.Test)
 
-	cpuSnapshot, ramSnapshot := nes.StartWithProgramAsTest(program, startAddr, 38)
+	cpuSnapshot, ramSnapshot := nes.StartWithProgramSourceAsTest(program, 38)
 
 	assert(cpuSnapshot.PC, uint16(0x0019))
 	assert(cpuSnapshot.A, uint8(0x1E))
@@ -80,7 +97,7 @@ func TestNestest(t *testing.T) {
 	nes := emulator.NewEmulatorWithMode(emulator.Test)
 
 	cpuSnapshot, _ := nes.StartWithNestestROMAsTest()
-	for i := 0; i < 5003; i++ {
+	for i := 0; i < len(results); i++ {
 		fmt.Print(nes.PeekCurrentSnapshot())
 		assert(cpuSnapshot, results[i])
 		fmt.Println("\t\u2713")
@@ -172,3 +189,72 @@ func parseNestestLog() []nes.PeekCPUResult {
 
 	return results
 }
+
+// loopProgram sets the backdrop colour, then loops forever.
+const loopProgram = `
+		.org $C000
+reset:	LDA #$3F
+		STA $2006
+		LDA #$00
+		STA $2006
+		LDA #$21
+		STA $2007
+loop:	JMP loop
+		.org $FFFA
+		.word loop, reset, loop
+`
+
+// writeLoopROM writes an NROM image of loopProgram.
+func writeLoopROM(t *testing.T) string {
+	program, err := assembler.Assemble(loopProgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rom := make([]uint8, 16+16384+8192)
+	copy(rom, []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0, 0})
+	for _, segment := range program.Segments {
+		copy(rom[16+int(segment.Addr)-0xC000:], segment.Data)
+	}
+	path := t.TempDir() + "/loop.nes"
+	if err := os.WriteFile(path, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHeadless(t *testing.T) {
+	rom := writeLoopROM(t)
+	dir := t.TempDir()
+	var stdout, stderr strings.Builder
+
+	status := runHeadless([]string{"-frames", "3", "-hash", "-ram", dir + "/ram.bin", "-screenshot", dir + "/shot.png", rom}, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("got status %d, expected 0: %s", status, stderr.String())
+	}
+	hash := strings.TrimSpace(stdout.String())
+	if len(hash) != 16 {
+		t.Errorf("got hash %q, expected 16 hex digits", hash)
+	}
+	if ram, err := os.ReadFile(dir + "/ram.bin"); err != nil || len(ram) != 0x800 {
+		t.Errorf("got %d bytes of RAM, %v, expected 2KB", len(ram), err)
+	}
+	if _, err := os.Stat(dir + "/shot.png"); err != nil {
+		t.Error(err)
+	}
+
+	if status := runHeadless([]string{"-frames", "3", "-expect-hash", hash, rom}, &stdout, &stderr); status != 0 {
+		t.Errorf("got status %d with the same hash, expected 0", status)
+	}
+	if status := runHeadless([]string{"-frames", "3", "-expect-hash", "0123456789abcdef", rom}, &stdout, &stderr); status != 1 {
+		t.Errorf("got status %d with a different hash, expected 1", status)
+	}
+	if status := runHeadless([]string{"-frames", "3", "-record", dir + "/clip.y4m", rom}, &stdout, &stderr); status != 0 {
+		t.Errorf("got status %d while recording, expected 0: %s", status, stderr.String())
+	}
+	if video, err := os.ReadFile(dir + "/clip.y4m"); err != nil || strings.Count(string(video), "FRAME\n") != 3 {
+		t.Errorf("expected a recording of 3 frames, got %v", err)
+	}
+	if status := runHeadless([]string{"-frames", "3", dir + "/missing.nes"}, &stdout, &stderr); status != 2 {
+		t.Errorf("got status %d for a missing ROM, expected 2", status)
+	}
+}
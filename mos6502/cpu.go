@@ -0,0 +1,411 @@
+// Package mos6502 emulates the MOS 6502 family of CPUs, cycle by cycle, on top of a memory bus.
+// It can run standalone against a plain 64 KiB memory, or as part of a bigger system such as the NES.
+package mos6502
+
+// Bus is the memory bus the CPU is connected to.
+// Every call to Read or Write is one CPU cycle, which gives the bus the chance to run the rest of the system in step.
+type Bus interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, data uint8)
+}
+
+// DMA is a transfer that takes over the bus while the CPU is halted, see: CPU.Halt
+type DMA interface {
+	// Cycle runs the next cycle of the transfer, given the number of CPU cycles since reset. It either makes a single
+	// Read or Write on the given bus and returns true, or returns false to leave the cycle to the halted CPU,
+	// which repeats its last read.
+	Cycle(bus Bus, cycle int) bool
+	// Done returns true once the transfer is complete and the CPU can resume.
+	Done() bool
+}
+
+// Variant selects the chip being emulated.
+type Variant uint8
+
+const (
+	NMOS6502  Variant = iota // original NMOS 6502, with decimal mode and the unofficial opcodes
+	Ricoh2A03                // NES CPU, an NMOS 6502 with decimal mode disconnected
+	CMOS65C02                // WDC 65C02, with its extra instructions and the Rockwell bit instructions
+)
+
+func (v Variant) ToString() string {
+	switch v {
+	case NMOS6502:
+		return "6502"
+	case Ricoh2A03:
+		return "2A03"
+	case CMOS65C02:
+		return "65C02"
+	}
+	return ""
+}
+
+// Interrupt identifies the kind of interrupt the CPU has entered.
+type Interrupt uint8
+
+const (
+	InterruptNone Interrupt = iota
+	InterruptNMI
+	InterruptIRQ
+	InterruptBRK
+)
+
+type CPU struct {
+	bus     Bus
+	variant Variant
+	decimal bool // whether ADC and SBC honour the D flag
+
+	a  uint8  // Accumulator register
+	x  uint8  // X register
+	y  uint8  // Y register
+	p  uint8  // Status register
+	sp uint8  // Stack pointer
+	pc uint16 // Program counter
+
+	// Number of CPU cycles since reset
+	cycle int
+
+	// Set by KIL and STP, the CPU no longer executes instructions until it is reset
+	jammed bool
+
+	// Set by WAI, the CPU no longer executes instructions until an interrupt is signalled
+	waiting bool
+
+	// Instruction currently being executed
+	currentOpcode uint8
+	currentInst   Instruction
+
+	// Interrupt entered by the current instruction, if any
+	interrupted Interrupt
+
+	// Interrupt lines, updated on every bus cycle
+	lines cpuLines
+
+	// Transfer the CPU is halted for once the current instruction is complete, see: Halt
+	dma DMA
+
+	// Instruction being executed one bus cycle at a time, see: StepCycle
+	stepper cycleStepper
+
+	// Opcode table
+	table [256]OpcodeInfo
+}
+
+// Registers holds the programmer visible state of the CPU.
+type Registers struct {
+	A  uint8  // Accumulator register
+	X  uint8  // X register
+	Y  uint8  // Y register
+	P  uint8  // Status register
+	SP uint8  // Stack pointer
+	PC uint16 // Program counter
+}
+
+// cpuLines holds the interrupt lines.
+// Interrupts are detected at the end of every cycle, and polled on the second-to-last cycle of an instruction,
+// see: https://www.nesdev.org/wiki/CPU_interrupts
+type cpuLines struct {
+	nmi         bool // level of the NMI line
+	prevNmi     bool // level of the NMI line at the end of the previous cycle
+	needNmi     bool // an NMI edge has been detected
+	prevNeedNmi bool // needNmi as of the end of the previous cycle
+
+	irq        bool // level of the IRQ line
+	runIrq     bool // the IRQ line is asserted and not masked by the I flag
+	prevRunIrq bool // runIrq as of the end of the previous cycle
+}
+
+// cycleStepper releases the bus cycles of an executed instruction one at a time, see: StepCycle
+type cycleStepper struct {
+	active    bool
+	registers Registers // registers from before the instruction
+	start     int       // cycle count from before the instruction
+	cycles    int       // bus cycles of the instruction, including halted cycles and an interrupt it entered
+	released  int       // bus cycles released so far
+}
+
+// NewCPU creates a CPU of the given variant connected to the given bus.
+// Decimal mode is enabled for the variants that have it, see: SetDecimalMode
+func NewCPU(bus Bus, variant Variant) *CPU {
+	cpu := &CPU{
+		bus:     bus,
+		variant: variant,
+		decimal: variant != Ricoh2A03,
+	}
+	cpu.InitOpcodeTable()
+	if variant == CMOS65C02 {
+		cpu.initCMOSOpcodeTable()
+	}
+	return cpu
+}
+
+func (cpu *CPU) Variant() Variant {
+	return cpu.variant
+}
+
+// SetDecimalMode enables or disables BCD arithmetic in ADC and SBC when the D flag is set.
+func (cpu *CPU) SetDecimalMode(enabled bool) {
+	cpu.decimal = enabled
+}
+
+func (cpu *CPU) DecimalMode() bool {
+	return cpu.decimal
+}
+
+// PowerOn clears the registers, then runs the reset sequence, leaving the stack pointer at $FD.
+func (cpu *CPU) PowerOn() {
+	cpu.a = 0
+	cpu.x = 0
+	cpu.y = 0
+	cpu.sp = 0x00
+	cpu.p = 0x24
+	cpu.resetSequence()
+}
+
+// Reset runs the reset sequence, which keeps A, X and Y, sets the I flag and moves the stack pointer down by 3.
+// See: https://www.nesdev.org/wiki/CPU_power_up_state
+func (cpu *CPU) Reset() {
+	cpu.p |= uint8(I | U)
+	cpu.resetSequence()
+}
+
+func (cpu *CPU) resetSequence() {
+	cpu.jammed = false
+	cpu.waiting = false
+	cpu.stepper.active = false
+	cpu.lines = cpuLines{}
+	cpu.dma = nil
+
+	// Reset cycle, the reset sequence takes 7 cycles like an interrupt, with the pushes turned into reads
+	cpu.cycle = 0
+	cpu.Read(cpu.pc)
+	cpu.Read(cpu.pc)
+	for i := 0; i < 3; i++ {
+		cpu.Read(0x100 | uint16(cpu.sp))
+		cpu.sp--
+	}
+
+	// Set PC
+	cpu.pc = cpu.Read16(0xFFFC)
+}
+
+// Registers returns the current state of the registers.
+// While an instruction is being cycle stepped, they are those from before the instruction.
+func (cpu *CPU) Registers() Registers {
+	if cpu.stepper.active {
+		return cpu.stepper.registers
+	}
+	return Registers{A: cpu.a, X: cpu.x, Y: cpu.y, P: cpu.p, SP: cpu.sp, PC: cpu.pc}
+}
+
+// SetRegisters overwrites the registers, e.g. to start executing at a given address.
+// The remaining cycles of an instruction being cycle stepped are released.
+func (cpu *CPU) SetRegisters(r Registers) {
+	cpu.stepper.active = false
+	cpu.a, cpu.x, cpu.y, cpu.p, cpu.sp, cpu.pc = r.A, r.X, r.Y, r.P, r.SP, r.PC
+}
+
+// Cycles returns the number of cycles since reset.
+// While an instruction is being cycle stepped, only its released cycles are counted.
+func (cpu *CPU) Cycles() int {
+	if cpu.stepper.active {
+		return cpu.stepper.start + cpu.stepper.released
+	}
+	return cpu.cycle
+}
+
+// Jammed returns true when the CPU has executed a KIL or STP instruction, and stopped until the next reset.
+func (cpu *CPU) Jammed() bool {
+	return cpu.jammed
+}
+
+// CurrentOpcode returns the opcode of the instruction being executed, or executed last.
+func (cpu *CPU) CurrentOpcode() uint8 {
+	return cpu.currentOpcode
+}
+
+// LastInterrupt returns the interrupt entered by the last instruction, including BRK, or InterruptNone.
+func (cpu *CPU) LastInterrupt() Interrupt {
+	return cpu.interrupted
+}
+
+// SetNMI sets the level of the NMI line, an NMI is triggered when it goes from low to high.
+func (cpu *CPU) SetNMI(level bool) {
+	cpu.lines.nmi = level
+}
+
+// SetIRQ sets the level of the IRQ line, an IRQ is triggered for as long as it is high and the I flag is clear.
+func (cpu *CPU) SetIRQ(level bool) {
+	cpu.lines.irq = level
+}
+
+// Halt halts the CPU once the current instruction is complete, and hands the bus over to the DMA until it is done.
+// The cycles of the transfer go through the CPU, so they are counted and interrupts are still detected.
+func (cpu *CPU) Halt(dma DMA) {
+	cpu.dma = dma
+}
+
+// Read will read 1 byte (8 bits) from the given address.
+// Every read or write takes one CPU cycle.
+func (cpu *CPU) Read(addr uint16) uint8 {
+	data := cpu.bus.Read(addr)
+	cpu.endCycle()
+	return data
+}
+
+// Write will write 1 byte of data to the given address.
+func (cpu *CPU) Write(addr uint16, data uint8) {
+	cpu.bus.Write(addr, data)
+	cpu.endCycle()
+}
+
+// endCycle finishes a CPU cycle by detecting interrupts, see: https://www.nesdev.org/wiki/CPU_interrupts#Detailed_interrupt_behavior
+func (cpu *CPU) endCycle() {
+	cpu.cycle++
+
+	cpu.lines.prevRunIrq = cpu.lines.runIrq
+	cpu.lines.runIrq = cpu.lines.irq && cpu.GetFlag(I) == 0
+
+	cpu.lines.prevNeedNmi = cpu.lines.needNmi
+	if !cpu.lines.prevNmi && cpu.lines.nmi {
+		cpu.lines.needNmi = true
+	}
+	cpu.lines.prevNmi = cpu.lines.nmi
+}
+
+// Read16 will read 2 bytes (16 bits) from the given address.
+// 16-bit address words are little endian, lo(w)-byte first, followed by the hi(gh)-byte.
+// (An assembler will use a human-readable, big-endian notation as in $HHLL)
+func (cpu *CPU) Read16(addr uint16) uint16 {
+	lo := uint16(cpu.Read(addr))
+	hi := uint16(cpu.Read(addr + 1))
+	return hi<<8 | lo
+}
+
+func (cpu *CPU) Write16(addr, data uint16) {
+	hi := uint8(data >> 8)
+	lo := uint8(data & 0xFF)
+	cpu.Write(addr, lo)
+	cpu.Write(addr+1, hi)
+}
+
+func (cpu *CPU) Push(data uint8) {
+	cpu.Write(0x100|uint16(cpu.sp), data)
+	cpu.sp--
+}
+
+func (cpu *CPU) Push16(data uint16) {
+	hi := uint8(data >> 8)
+	lo := uint8(data & 0xFF)
+	cpu.Push(hi)
+	cpu.Push(lo)
+}
+
+func (cpu *CPU) Pull() uint8 {
+	cpu.sp++
+	return cpu.Read(0x100 | uint16(cpu.sp))
+}
+
+func (cpu *CPU) Pull16() uint16 {
+	lo := uint16(cpu.Pull())
+	hi := uint16(cpu.Pull())
+	return hi<<8 | lo
+}
+
+func (cpu *CPU) GetStatus() uint8 {
+	return cpu.p
+}
+
+func (cpu *CPU) PushStatus() {
+	cpu.Push(cpu.p)
+}
+
+func (cpu *CPU) PullStatus() {
+	cpu.p = cpu.Pull()
+	cpu.SetFlag(U, true)
+	cpu.SetFlag(B, false)
+}
+
+// Clock executes one instruction, followed by any halted cycles and a pending interrupt.
+// When the CPU is jammed or waiting for an interrupt, only a single cycle passes.
+// If the instruction is being cycle stepped, its remaining cycles are released instead.
+func (cpu *CPU) Clock() {
+	if cpu.stepper.active {
+		cpu.stepper.active = false
+		return
+	}
+	cpu.execute()
+}
+
+func (cpu *CPU) execute() {
+	cpu.interrupted = InterruptNone
+	if cpu.jammed {
+		// a jammed CPU keeps the clock running, so the rest of the system still gets its cycles
+		cpu.Read(0xFFFF)
+		return
+	}
+	if cpu.waiting {
+		if !cpu.lines.nmi && !cpu.lines.irq {
+			cpu.Read(cpu.pc)
+			return
+		}
+		cpu.waiting = false
+	}
+
+	opcode := cpu.Read(cpu.pc)
+	info := cpu.table[opcode]
+	cpu.currentOpcode = opcode
+	cpu.currentInst = info.inst
+
+	addrInfo := info.addrModeFunc()
+
+	cpu.pc += uint16(info.instSize)
+
+	info.instFunc(addrInfo.mode, addrInfo.address)
+
+	// a halted CPU keeps repeating the read of the next opcode, on the cycles the DMA leaves to it
+	for cpu.dma != nil {
+		if cpu.dma.Done() {
+			cpu.dma = nil
+		} else if !cpu.dma.Cycle(cpu, cpu.cycle) {
+			cpu.Read(cpu.pc)
+		}
+	}
+
+	// interrupts are polled on the second-to-last cycle
+	if cpu.lines.prevNeedNmi || cpu.lines.prevRunIrq {
+		cpu.interrupt()
+	}
+}
+
+// StepCycle advances a single CPU cycle, returning true when this completes the current instruction.
+// The instruction is executed on its first cycle, then its bus cycles are released one per call: until the last one,
+// the registers read as they were before it, and Cycles only counts the released cycles.
+// All the bus cycles take place on the first call, so a bus running the rest of the system is ahead while stepping.
+func (cpu *CPU) StepCycle() bool {
+	s := &cpu.stepper
+	if !s.active {
+		s.registers = cpu.Registers()
+		s.start = cpu.cycle
+		cpu.execute()
+		s.cycles = cpu.cycle - s.start
+		s.released = 0
+		s.active = true
+	}
+	s.released++
+	if s.released < s.cycles {
+		return false
+	}
+	s.active = false
+	return true
+}
+
+// branch jumps to the given address, taking one more cycle, and another one if the page changes.
+// Those cycles do dummy reads of the next opcode, and of the target address before its hi-byte is fixed up.
+func (cpu *CPU) branch(addr uint16) {
+	cpu.Read(cpu.pc)
+	if IsCrossed(cpu.pc, addr) {
+		cpu.Read(cpu.pc&0xFF00 | addr&0x00FF)
+	}
+	cpu.pc = addr
+}
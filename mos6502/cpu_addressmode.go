@@ -1,56 +1,67 @@
 // Reference document: https://www.masswerk.at/6502/6502_instruction_set.html
 
-package nes
+package mos6502
 
 type AddressMode uint8
 
 const (
-	modeNone AddressMode = iota
-	modeAccu
-	modeAbso
-	modeAbsX
-	modeAbsY
-	modeImmd
-	modeImpl
-	modeIndi
-	modeXInd
-	modeIndY
-	modeRela
-	modeZpag
-	modeZpgX
-	modeZpgY
+	ModeNone AddressMode = iota
+	ModeAccu
+	ModeAbso
+	ModeAbsX
+	ModeAbsY
+	ModeImmd
+	ModeImpl
+	ModeIndi
+	ModeXInd
+	ModeIndY
+	ModeRela
+	ModeZpag
+	ModeZpgX
+	ModeZpgY
+
+	// 65C02 addressing modes
+	ModeZInd
+	ModeAInX
+	ModeZRel
 )
 
 func (am AddressMode) ToString() string {
 	switch am {
-	case modeNone:
+	case ModeNone:
 		return "---"
-	case modeAccu:
+	case ModeAccu:
 		return "A"
-	case modeAbso:
+	case ModeAbso:
 		return "abs"
-	case modeAbsX:
+	case ModeAbsX:
 		return "abs,X"
-	case modeAbsY:
+	case ModeAbsY:
 		return "abs,Y"
-	case modeImmd:
+	case ModeImmd:
 		return "#"
-	case modeImpl:
+	case ModeImpl:
 		return "impl"
-	case modeIndi:
+	case ModeIndi:
 		return "ind"
-	case modeXInd:
+	case ModeXInd:
 		return "X,ind"
-	case modeIndY:
+	case ModeIndY:
 		return "ind,Y"
-	case modeRela:
+	case ModeRela:
 		return "rel"
-	case modeZpag:
+	case ModeZpag:
 		return "zpg"
-	case modeZpgX:
+	case ModeZpgX:
 		return "zpg,X"
-	case modeZpgY:
+	case ModeZpgY:
 		return "zpg,Y"
+	case ModeZInd:
+		return "(zpg)"
+	case ModeAInX:
+		return "(abs,X)"
+	case ModeZRel:
+		return "zpg,rel"
 	}
 	return ""
 }
@@ -69,14 +80,27 @@ func IsCrossed(old, new uint16) bool {
 	return old&0xFF00 != new&0xFF00
 }
 
+// indexCycle spends the cycle needed to fix up the hi-byte of an indexed address,
+// during which the CPU does a dummy read from the address with the hi-byte not fixed up yet.
+// Reading instructions skip it when the page is not crossed, writing ones always take it.
+func (cpu *CPU) indexCycle(baseAddr, addr uint16) {
+	if IsCrossed(baseAddr, addr) || cpu.currentInst.IsWrite() || cpu.currentInst.IsReadModifyWrite() {
+		cpu.Read(baseAddr&0xFF00 | addr&0x00FF)
+	}
+}
+
+// None - no operand, used by the single cycle NOPs of the 65C02
 func (cpu *CPU) None() AddressInfo {
-	panic("Unsupported opcode!")
+	return AddressInfo{
+		mode: ModeNone,
+	}
 }
 
 // Accu (Accumulator) - operand is AC (implied single byte instruction)
 func (cpu *CPU) Accu() AddressInfo {
+	cpu.Read(cpu.pc + 1) // dummy read of the next byte
 	return AddressInfo{
-		mode: modeAccu,
+		mode: ModeAccu,
 	}
 }
 
@@ -87,17 +111,27 @@ func (cpu *CPU) Accu() AddressInfo {
 func (cpu *CPU) Abso() AddressInfo {
 	addr := cpu.Read16(cpu.pc + 1)
 	return AddressInfo{
-		mode:    modeAbso,
+		mode:    ModeAbso,
 		address: addr,
 	}
 }
 
+// jsrAbso is the absolute addressing of JSR, which only reads the lo-byte of the address up front.
+// The hi-byte is read on the last cycle, after the return address is pushed, see: jsr
+func (cpu *CPU) jsrAbso() AddressInfo {
+	return AddressInfo{
+		mode:    ModeAbso,
+		address: uint16(cpu.Read(cpu.pc + 1)),
+	}
+}
+
 // AbsX (absolute, X-indexed) - operand is address; effective address is address incremented by X with carry
 func (cpu *CPU) AbsX() AddressInfo {
 	baseAddr := cpu.Read16(cpu.pc + 1)
 	addr := baseAddr + uint16(cpu.x)
+	cpu.indexCycle(baseAddr, addr)
 	return AddressInfo{
-		mode:    modeAbsX,
+		mode:    ModeAbsX,
 		address: addr,
 		crossed: IsCrossed(baseAddr, addr),
 	}
@@ -107,8 +141,9 @@ func (cpu *CPU) AbsX() AddressInfo {
 func (cpu *CPU) AbsY() AddressInfo {
 	baseAddr := cpu.Read16(cpu.pc + 1)
 	addr := baseAddr + uint16(cpu.y)
+	cpu.indexCycle(baseAddr, addr)
 	return AddressInfo{
-		mode:    modeAbsY,
+		mode:    ModeAbsY,
 		address: addr,
 		crossed: IsCrossed(baseAddr, addr),
 	}
@@ -117,15 +152,16 @@ func (cpu *CPU) AbsY() AddressInfo {
 // Immd (immediate) - operand is byte BB
 func (cpu *CPU) Immd() AddressInfo {
 	return AddressInfo{
-		mode:    modeImmd,
+		mode:    ModeImmd,
 		address: cpu.pc + 1,
 	}
 }
 
 // Impl (implied) - operand implied
 func (cpu *CPU) Impl() AddressInfo {
+	cpu.Read(cpu.pc + 1) // dummy read of the next byte
 	return AddressInfo{
-		mode: modeImpl,
+		mode: ModeImpl,
 	}
 }
 
@@ -133,8 +169,12 @@ func (cpu *CPU) Impl() AddressInfo {
 func (cpu *CPU) Indi() AddressInfo {
 	var addr uint16
 	pointer := cpu.Read16(cpu.pc + 1)
-	// simulate the 6502 bug - if pointer is at page boundary, the hi-byte will actually not have its page incremented
-	if pointer&0x00FF == 0x00FF {
+	if cpu.variant == CMOS65C02 {
+		// the 65C02 fixes the bug below, taking an extra cycle
+		cpu.Read(cpu.pc + 2)
+		addr = cpu.Read16(pointer)
+	} else if pointer&0x00FF == 0x00FF {
+		// simulate the 6502 bug - if pointer is at page boundary, the hi-byte will actually not have its page incremented
 		lo := uint16(cpu.Read(pointer))
 		hi := uint16(cpu.Read(pointer & 0xFF00))
 		addr = hi<<8 | lo
@@ -142,7 +182,7 @@ func (cpu *CPU) Indi() AddressInfo {
 		addr = cpu.Read16(pointer)
 	}
 	return AddressInfo{
-		mode:    modeIndi,
+		mode:    ModeIndi,
 		address: addr,
 	}
 }
@@ -151,6 +191,7 @@ func (cpu *CPU) Indi() AddressInfo {
 func (cpu *CPU) XInd() AddressInfo {
 	var addr uint16
 	baseAddr := cpu.Read(cpu.pc + 1)
+	cpu.Read(uint16(baseAddr)) // dummy read while X is added
 	absAddr := uint16(baseAddr) + uint16(cpu.x)
 	pointer := absAddr & 0x00FF
 	// simulate the 6502 bug - if pointer is at page boundary, the hi-byte will actually not have its page incremented
@@ -162,7 +203,7 @@ func (cpu *CPU) XInd() AddressInfo {
 		addr = cpu.Read16(pointer)
 	}
 	return AddressInfo{
-		mode:    modeXInd,
+		mode:    ModeXInd,
 		address: addr,
 	}
 }
@@ -180,8 +221,9 @@ func (cpu *CPU) IndY() AddressInfo {
 		baseAddr = cpu.Read16(pointer)
 	}
 	addr := baseAddr + uint16(cpu.y)
+	cpu.indexCycle(baseAddr, addr)
 	return AddressInfo{
-		mode:    modeIndY,
+		mode:    ModeIndY,
 		address: addr,
 		crossed: IsCrossed(baseAddr, addr),
 	}
@@ -198,7 +240,7 @@ func (cpu *CPU) Rela() AddressInfo {
 		addr = baseAddr + offset - 0x100
 	}
 	return AddressInfo{
-		mode:    modeRela,
+		mode:    ModeRela,
 		address: addr,
 		crossed: IsCrossed(baseAddr, addr),
 	}
@@ -208,25 +250,58 @@ func (cpu *CPU) Rela() AddressInfo {
 func (cpu *CPU) Zpag() AddressInfo {
 	addr := uint16(cpu.Read(cpu.pc + 1))
 	return AddressInfo{
-		mode:    modeZpag,
+		mode:    ModeZpag,
 		address: addr,
 	}
 }
 
 // ZpgX (zero-page, X-indexed) - operand is zero-page address; effective address is address incremented by X without carry
 func (cpu *CPU) ZpgX() AddressInfo {
-	addr := uint16(cpu.Read(cpu.pc+1) + cpu.x)
+	baseAddr := cpu.Read(cpu.pc + 1)
+	cpu.Read(uint16(baseAddr)) // dummy read while X is added
+	addr := uint16(baseAddr + cpu.x)
 	return AddressInfo{
-		mode:    modeZpgX,
+		mode:    ModeZpgX,
 		address: addr,
 	}
 }
 
 // ZpgY (zero-page, Y-indexed) - operand is zero-page address; effective address is address incremented by Y without carry
 func (cpu *CPU) ZpgY() AddressInfo {
-	addr := uint16(cpu.Read(cpu.pc+1) + cpu.y)
+	baseAddr := cpu.Read(cpu.pc + 1)
+	cpu.Read(uint16(baseAddr)) // dummy read while Y is added
+	addr := uint16(baseAddr + cpu.y)
 	return AddressInfo{
-		mode:    modeZpgY,
+		mode:    ModeZpgY,
 		address: addr,
 	}
 }
+
+// ZInd (zero-page indirect, 65C02) - operand is zero-page address; effective address is word in (LL, LL + 1), inc. without carry: C.w($00LL)
+func (cpu *CPU) ZInd() AddressInfo {
+	pointer := cpu.Read(cpu.pc + 1)
+	lo := uint16(cpu.Read(uint16(pointer)))
+	hi := uint16(cpu.Read(uint16(pointer + 1)))
+	return AddressInfo{
+		mode:    ModeZInd,
+		address: hi<<8 | lo,
+	}
+}
+
+// AInX (absolute X-indexed indirect, 65C02) - operand is address; effective address is word at address incremented by X with carry: C.w($HHLL + X)
+func (cpu *CPU) AInX() AddressInfo {
+	baseAddr := cpu.Read16(cpu.pc + 1)
+	cpu.Read(cpu.pc + 2) // dummy read while X is added
+	return AddressInfo{
+		mode:    ModeAInX,
+		address: cpu.Read16(baseAddr + uint16(cpu.x)),
+	}
+}
+
+// ZRel (zero-page, relative, 65C02) - operand is zero-page address, followed by a branch offset BB
+func (cpu *CPU) ZRel() AddressInfo {
+	return AddressInfo{
+		mode:    ModeZRel,
+		address: uint16(cpu.Read(cpu.pc + 1)),
+	}
+}
@@ -0,0 +1,245 @@
+// Reference document: http://www.6502.org/tutorials/65c02opcodes.html
+
+package mos6502
+
+// initCMOSOpcodeTable turns the NMOS opcode table into that of the 65C02.
+// The unofficial opcodes of the NMOS 6502 are NOPs on the 65C02, unless they got a new instruction.
+func (cpu *CPU) initCMOSOpcodeTable() {
+	for opcode := range cpu.table {
+		if isUnofficial(uint8(opcode), cpu.table[opcode].inst) {
+			cpu.table[opcode] = OpcodeInfo{ModeNone, cpu.None, NOP, cpu.nop, 1, 1}
+		}
+	}
+
+	for _, opcode := range []uint8{0x02, 0x22, 0x42, 0x62, 0x82, 0xC2, 0xE2} {
+		cpu.table[opcode] = OpcodeInfo{ModeImmd, cpu.Immd, NOP, cpu.nop, 2, 2}
+	}
+	cpu.table[0x44] = OpcodeInfo{ModeZpag, cpu.Zpag, NOP, cpu.nop, 2, 3}
+	for _, opcode := range []uint8{0x54, 0xD4, 0xF4} {
+		cpu.table[opcode] = OpcodeInfo{ModeZpgX, cpu.ZpgX, NOP, cpu.nop, 2, 4}
+	}
+	for _, opcode := range []uint8{0x5C, 0xDC, 0xFC} {
+		cpu.table[opcode] = OpcodeInfo{ModeAbso, cpu.Abso, NOP, cpu.nop, 3, 4}
+	}
+
+	// indirect zero-page addressing for the accumulator instructions
+	cpu.table[0x12] = OpcodeInfo{ModeZInd, cpu.ZInd, ORA, cpu.ora, 2, 5}
+	cpu.table[0x32] = OpcodeInfo{ModeZInd, cpu.ZInd, AND, cpu.and, 2, 5}
+	cpu.table[0x52] = OpcodeInfo{ModeZInd, cpu.ZInd, EOR, cpu.eor, 2, 5}
+	cpu.table[0x72] = OpcodeInfo{ModeZInd, cpu.ZInd, ADC, cpu.adc, 2, 5}
+	cpu.table[0x92] = OpcodeInfo{ModeZInd, cpu.ZInd, STA, cpu.sta, 2, 5}
+	cpu.table[0xB2] = OpcodeInfo{ModeZInd, cpu.ZInd, LDA, cpu.lda, 2, 5}
+	cpu.table[0xD2] = OpcodeInfo{ModeZInd, cpu.ZInd, CMP, cpu.cmp, 2, 5}
+	cpu.table[0xF2] = OpcodeInfo{ModeZInd, cpu.ZInd, SBC, cpu.sbc, 2, 5}
+
+	// new addressing modes for existing instructions
+	cpu.table[0x1A] = OpcodeInfo{ModeAccu, cpu.Accu, INC, cpu.inc, 1, 2}
+	cpu.table[0x3A] = OpcodeInfo{ModeAccu, cpu.Accu, DEC, cpu.dec, 1, 2}
+	cpu.table[0x34] = OpcodeInfo{ModeZpgX, cpu.ZpgX, BIT, cpu.bit, 2, 4}
+	cpu.table[0x3C] = OpcodeInfo{ModeAbsX, cpu.AbsX, BIT, cpu.bit, 3, 4}
+	cpu.table[0x89] = OpcodeInfo{ModeImmd, cpu.Immd, BIT, cpu.bit, 2, 2}
+	cpu.table[0x6C] = OpcodeInfo{ModeIndi, cpu.Indi, JMP, cpu.jmp, 3, 6}
+	cpu.table[0x7C] = OpcodeInfo{ModeAInX, cpu.AInX, JMP, cpu.jmp, 3, 6}
+
+	// new instructions
+	cpu.table[0x80] = OpcodeInfo{ModeRela, cpu.Rela, BRA, cpu.bra, 2, 3}
+	cpu.table[0xDA] = OpcodeInfo{ModeImpl, cpu.Impl, PHX, cpu.phx, 1, 3}
+	cpu.table[0x5A] = OpcodeInfo{ModeImpl, cpu.Impl, PHY, cpu.phy, 1, 3}
+	cpu.table[0xFA] = OpcodeInfo{ModeImpl, cpu.Impl, PLX, cpu.plx, 1, 4}
+	cpu.table[0x7A] = OpcodeInfo{ModeImpl, cpu.Impl, PLY, cpu.ply, 1, 4}
+	cpu.table[0x64] = OpcodeInfo{ModeZpag, cpu.Zpag, STZ, cpu.stz, 2, 3}
+	cpu.table[0x74] = OpcodeInfo{ModeZpgX, cpu.ZpgX, STZ, cpu.stz, 2, 4}
+	cpu.table[0x9C] = OpcodeInfo{ModeAbso, cpu.Abso, STZ, cpu.stz, 3, 4}
+	cpu.table[0x9E] = OpcodeInfo{ModeAbsX, cpu.AbsX, STZ, cpu.stz, 3, 5}
+	cpu.table[0x14] = OpcodeInfo{ModeZpag, cpu.Zpag, TRB, cpu.trb, 2, 5}
+	cpu.table[0x1C] = OpcodeInfo{ModeAbso, cpu.Abso, TRB, cpu.trb, 3, 6}
+	cpu.table[0x04] = OpcodeInfo{ModeZpag, cpu.Zpag, TSB, cpu.tsb, 2, 5}
+	cpu.table[0x0C] = OpcodeInfo{ModeAbso, cpu.Abso, TSB, cpu.tsb, 3, 6}
+	cpu.table[0xCB] = OpcodeInfo{ModeImpl, cpu.Impl, WAI, cpu.wai, 1, 3}
+	cpu.table[0xDB] = OpcodeInfo{ModeImpl, cpu.Impl, STP, cpu.stp, 1, 3}
+
+	// Rockwell bit instructions, the bit number is in the high nibble of the opcode
+	for bit := 0; bit < 8; bit++ {
+		cpu.table[bit<<4|0x07] = OpcodeInfo{ModeZpag, cpu.Zpag, RMB, cpu.rmb, 2, 5}
+		cpu.table[bit<<4|0x87] = OpcodeInfo{ModeZpag, cpu.Zpag, SMB, cpu.smb, 2, 5}
+		cpu.table[bit<<4|0x0F] = OpcodeInfo{ModeZRel, cpu.ZRel, BBR, cpu.bbr, 3, 5}
+		cpu.table[bit<<4|0x8F] = OpcodeInfo{ModeZRel, cpu.ZRel, BBS, cpu.bbs, 3, 5}
+	}
+}
+
+// BBR - Branch on Memory Bit Reset
+//
+//	branch on Mb = 0
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) bbr(mode AddressMode, addr uint16) {
+	cpu.branchOnBit(addr, false)
+}
+
+// BBS - Branch on Memory Bit Set
+//
+//	branch on Mb = 1
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) bbs(mode AddressMode, addr uint16) {
+	cpu.branchOnBit(addr, true)
+}
+
+// branchOnBit tests the bit of the zero-page value selected by the opcode, and branches by the offset that follows.
+func (cpu *CPU) branchOnBit(addr uint16, set bool) {
+	M := cpu.Read(addr)
+	cpu.Read(addr) // dummy read while the bit is tested
+	offset := uint16(cpu.Read(cpu.pc - 1))
+	if offset >= 0x80 {
+		offset -= 0x100
+	}
+	if (M&cpu.opcodeBit() != 0) == set {
+		cpu.branch(cpu.pc + offset)
+	}
+}
+
+// opcodeBit returns the mask of the bit that the Rockwell bit instructions work on.
+func (cpu *CPU) opcodeBit() uint8 {
+	return 1 << (cpu.currentOpcode >> 4 & 0x07)
+}
+
+// BRA - Branch Always
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) bra(mode AddressMode, addr uint16) {
+	cpu.branch(addr)
+}
+
+// PHX - Push Index X on Stack
+//
+//	push X
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) phx(mode AddressMode, addr uint16) {
+	cpu.Push(cpu.x)
+}
+
+// PHY - Push Index Y on Stack
+//
+//	push Y
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) phy(mode AddressMode, addr uint16) {
+	cpu.Push(cpu.y)
+}
+
+// PLX - Pull Index X from Stack
+//
+//	pull X
+//
+//	N Z C I D V
+//	+ + - - - -
+func (cpu *CPU) plx(mode AddressMode, addr uint16) {
+	cpu.Read(0x100 | uint16(cpu.sp)) // dummy read of the stack while the stack pointer is incremented
+	cpu.x = cpu.Pull()
+
+	cpu.SetFlag(N, IsNegative(cpu.x))
+	cpu.SetFlag(Z, IsZero(cpu.x))
+}
+
+// PLY - Pull Index Y from Stack
+//
+//	pull Y
+//
+//	N Z C I D V
+//	+ + - - - -
+func (cpu *CPU) ply(mode AddressMode, addr uint16) {
+	cpu.Read(0x100 | uint16(cpu.sp)) // dummy read of the stack while the stack pointer is incremented
+	cpu.y = cpu.Pull()
+
+	cpu.SetFlag(N, IsNegative(cpu.y))
+	cpu.SetFlag(Z, IsZero(cpu.y))
+}
+
+// RMB - Reset Memory Bit
+//
+//	0 -> Mb
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) rmb(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	cpu.Write(addr, M&^cpu.opcodeBit())
+}
+
+// SMB - Set Memory Bit
+//
+//	1 -> Mb
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) smb(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	cpu.Write(addr, M|cpu.opcodeBit())
+}
+
+// STP - Stop the Clock
+//
+// The CPU stops executing instructions, and only a reset brings it back.
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) stp(mode AddressMode, addr uint16) {
+	cpu.Read(cpu.pc) // dummy read while the clock is stopped
+	cpu.kil(mode, addr)
+}
+
+// STZ - Store Zero in Memory
+//
+//	0 -> M
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) stz(mode AddressMode, addr uint16) {
+	cpu.Write(addr, 0)
+}
+
+// TRB - Test and Reset Memory Bits with Accumulator
+//
+//	M AND NOT A -> M, A AND M
+//
+//	N Z C I D V
+//	- + - - - -
+func (cpu *CPU) trb(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	cpu.SetFlag(Z, IsZero(cpu.a&M))
+	cpu.Write(addr, M&^cpu.a)
+}
+
+// TSB - Test and Set Memory Bits with Accumulator
+//
+//	M OR A -> M, A AND M
+//
+//	N Z C I D V
+//	- + - - - -
+func (cpu *CPU) tsb(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	cpu.SetFlag(Z, IsZero(cpu.a&M))
+	cpu.Write(addr, M|cpu.a)
+}
+
+// WAI - Wait for Interrupt
+//
+// The CPU stops executing instructions until the NMI or IRQ line is asserted.
+// When the I flag masks the IRQ, execution simply continues with the next instruction.
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) wai(mode AddressMode, addr uint16) {
+	cpu.Read(cpu.pc) // dummy read while the CPU starts waiting
+	cpu.waiting = true
+}
@@ -0,0 +1,76 @@
+// Reference document: http://www.6502.org/tutorials/decimal_mode.html
+
+package mos6502
+
+// decimalActive returns true when ADC and SBC work in BCD.
+func (cpu *CPU) decimalActive() bool {
+	return cpu.decimal && cpu.GetFlag(D) == 1
+}
+
+// addDecimal adds M and the carry to the accumulator in BCD.
+// The NMOS 6502 sets Z from the binary sum, and N and V from the sum before the upper digit is adjusted.
+// The 65C02 sets N and Z from the result, taking an extra cycle.
+func (cpu *CPU) addDecimal(M uint8) {
+	A := cpu.a
+	carry := int(cpu.GetFlag(C))
+
+	lo := int(A&0x0F) + int(M&0x0F) + carry
+	if lo >= 0x0A {
+		lo = ((lo + 0x06) & 0x0F) + 0x10
+	}
+	sum := int(A&0xF0) + int(M&0xF0) + lo
+	signed := int(int8(A&0xF0)) + int(int8(M&0xF0)) + lo
+	if sum >= 0xA0 {
+		sum += 0x60
+	}
+	cpu.a = uint8(sum)
+
+	cpu.SetFlag(C, sum >= 0x100)
+	cpu.SetFlag(V, signed < -128 || signed > 127)
+	if cpu.variant == CMOS65C02 {
+		cpu.Read(cpu.pc - 1) // re-reads the last byte of the instruction while the flags are fixed up
+		cpu.SetFlag(N, IsNegative(cpu.a))
+		cpu.SetFlag(Z, IsZero(cpu.a))
+	} else {
+		cpu.SetFlag(N, signed&0x80 != 0)
+		cpu.SetFlag(Z, A+M+uint8(carry) == 0)
+	}
+}
+
+// subtractDecimal subtracts M and the borrow from the accumulator in BCD.
+// The NMOS 6502 sets all flags from the binary difference.
+// The 65C02 sets N and Z from the result, taking an extra cycle.
+func (cpu *CPU) subtractDecimal(M uint8) {
+	A := cpu.a
+	borrow := 1 - int(cpu.GetFlag(C))
+
+	// the flags come from the binary difference, the 65C02 fixes up N and Z afterwards
+	cpu.addWithCarry(M ^ 0xFF)
+
+	lo := int(A&0x0F) - int(M&0x0F) - borrow
+	var diff int
+	if cpu.variant == CMOS65C02 {
+		diff = int(A) - int(M) - borrow
+		if diff < 0 {
+			diff -= 0x60
+		}
+		if lo < 0 {
+			diff -= 0x06
+		}
+	} else {
+		if lo < 0 {
+			lo = ((lo - 0x06) & 0x0F) - 0x10
+		}
+		diff = int(A&0xF0) - int(M&0xF0) + lo
+		if diff < 0 {
+			diff -= 0x60
+		}
+	}
+	cpu.a = uint8(diff)
+
+	if cpu.variant == CMOS65C02 {
+		cpu.Read(cpu.pc - 1) // re-reads the last byte of the instruction while the flags are fixed up
+		cpu.SetFlag(N, IsNegative(cpu.a))
+		cpu.SetFlag(Z, IsZero(cpu.a))
+	}
+}
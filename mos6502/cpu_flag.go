@@ -1,4 +1,4 @@
-package nes
+package mos6502
 
 // 7  bit  0
 // ---- ----
@@ -1,4 +1,4 @@
-package nes
+package mos6502
 
 type Instruction uint8
 
@@ -59,6 +59,44 @@ const (
 	TXA                    // transfer X to accumulator
 	TXS                    // transfer X to stack pointer
 	TYA                    // transfer Y to accumulator
+
+	// Unofficial instructions, see: https://www.nesdev.org/wiki/CPU_unofficial_opcodes
+	ALR // and with immediate, then logical shift right
+	ANC // and with immediate, copying N into carry
+	ARR // and with immediate, then rotate right
+	AXS // X and accumulator minus immediate into X
+	DCP // decrement, then compare
+	ISC // increment, then subtract with carry
+	KIL // halt the CPU
+	LAS // memory and stack pointer into accumulator, X and stack pointer
+	LAX // load accumulator and X
+	LXA // load accumulator and X with immediate (unstable)
+	RLA // rotate left, then and
+	RRA // rotate right, then add with carry
+	SAX // store accumulator and X
+	SHA // store accumulator and X and high byte + 1 (unstable)
+	SHX // store X and high byte + 1 (unstable)
+	SHY // store Y and high byte + 1 (unstable)
+	SLO // arithmetic shift left, then or
+	SRE // logical shift right, then exclusive or
+	TAS // accumulator and X into stack pointer, then SHA (unstable)
+	XAA // X and immediate into accumulator (unstable)
+
+	// 65C02 instructions, see: http://www.6502.org/tutorials/65c02opcodes.html
+	BBR // branch on memory bit reset
+	BBS // branch on memory bit set
+	BRA // branch always
+	PHX // push X
+	PHY // push Y
+	PLX // pull X
+	PLY // pull Y
+	RMB // reset memory bit
+	SMB // set memory bit
+	STP // stop the CPU
+	STZ // store zero
+	TRB // test and reset bits
+	TSB // test and set bits
+	WAI // wait for interrupt
 )
 
 func (i Instruction) ToString() string {
@@ -175,19 +213,105 @@ func (i Instruction) ToString() string {
 		return "TXS"
 	case TYA:
 		return "TYA"
+	case ALR:
+		return "ALR"
+	case ANC:
+		return "ANC"
+	case ARR:
+		return "ARR"
+	case AXS:
+		return "AXS"
+	case DCP:
+		return "DCP"
+	case ISC:
+		return "ISC"
+	case KIL:
+		return "KIL"
+	case LAS:
+		return "LAS"
+	case LAX:
+		return "LAX"
+	case LXA:
+		return "LXA"
+	case RLA:
+		return "RLA"
+	case RRA:
+		return "RRA"
+	case SAX:
+		return "SAX"
+	case SHA:
+		return "SHA"
+	case SHX:
+		return "SHX"
+	case SHY:
+		return "SHY"
+	case SLO:
+		return "SLO"
+	case SRE:
+		return "SRE"
+	case TAS:
+		return "TAS"
+	case XAA:
+		return "XAA"
+	case BBR:
+		return "BBR"
+	case BBS:
+		return "BBS"
+	case BRA:
+		return "BRA"
+	case PHX:
+		return "PHX"
+	case PHY:
+		return "PHY"
+	case PLX:
+		return "PLX"
+	case PLY:
+		return "PLY"
+	case RMB:
+		return "RMB"
+	case SMB:
+		return "SMB"
+	case STP:
+		return "STP"
+	case STZ:
+		return "STZ"
+	case TRB:
+		return "TRB"
+	case TSB:
+		return "TSB"
+	case WAI:
+		return "WAI"
 	}
 	return ""
 }
 
 func (i Instruction) IsBranch() bool {
-	branchInstructions := map[Instruction]bool{BCC: true, BCS: true, BEQ: true, BMI: true, BNE: true, BPL: true, BVC: true, BVS: true}
+	branchInstructions := map[Instruction]bool{BCC: true, BCS: true, BEQ: true, BMI: true, BNE: true, BPL: true, BVC: true, BVS: true, BRA: true, BBR: true, BBS: true}
 	if _, ok := branchInstructions[i]; ok {
 		return true
 	}
 	return false
 }
 
-type InstructionFunc func(mode AddressMode, addr uint16) bool
+// IsWrite returns true for instructions that write to memory without reading it first.
+func (i Instruction) IsWrite() bool {
+	switch i {
+	case STA, STX, STY, SAX, SHA, SHX, SHY, TAS, STZ:
+		return true
+	}
+	return false
+}
+
+// IsReadModifyWrite returns true for instructions that read memory, modify the value, and write it back.
+func (i Instruction) IsReadModifyWrite() bool {
+	switch i {
+	case ASL, DEC, INC, LSR, ROL, ROR, DCP, ISC, RLA, RRA, SLO, SRE, RMB, SMB, TRB, TSB:
+		return true
+	}
+	return false
+}
+
+type InstructionFunc func(mode AddressMode, addr uint16)
 
 // ADC - Add Memory to Accumulator with Carry
 //
@@ -195,9 +319,32 @@ type InstructionFunc func(mode AddressMode, addr uint16) bool
 //
 //	N Z C I D V
 //	+ + + - - +
-func (cpu *CPU) adc(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) adc(mode AddressMode, addr uint16) {
+	cpu.add(cpu.Read(addr))
+}
+
+// add adds M and the carry to the accumulator, as done by ADC, in BCD when decimal mode is active.
+func (cpu *CPU) add(M uint8) {
+	if cpu.decimalActive() {
+		cpu.addDecimal(M)
+	} else {
+		cpu.addWithCarry(M)
+	}
+}
+
+// subtract subtracts M and the borrow from the accumulator, as done by SBC, in BCD when decimal mode is active.
+func (cpu *CPU) subtract(M uint8) {
+	if cpu.decimalActive() {
+		cpu.subtractDecimal(M)
+	} else {
+		cpu.addWithCarry(M ^ 0xFF)
+	}
+}
+
+// addWithCarry adds M and the carry to the accumulator in binary.
+// Subtracting is done by adding the inverse of M.
+func (cpu *CPU) addWithCarry(M uint8) {
 	A := cpu.a
-	M := cpu.Read(addr)
 	carry := cpu.GetFlag(C)
 	cpu.a = A + M + carry
 
@@ -205,8 +352,6 @@ func (cpu *CPU) adc(mode AddressMode, addr uint16) bool {
 	cpu.SetFlag(Z, IsZero(cpu.a))
 	cpu.SetFlag(C, uint16(A)+uint16(M)+uint16(carry) > 0xFF)
 	cpu.SetFlag(V, (A^M)&0x80 == 0 && (A^cpu.a)&0x80 != 0)
-
-	return true
 }
 
 // AND - AND Memory with Accumulator
@@ -215,15 +360,13 @@ func (cpu *CPU) adc(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) and(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) and(mode AddressMode, addr uint16) {
 	A := cpu.a
 	M := cpu.Read(addr)
 	cpu.a = A & M
 
 	cpu.SetFlag(N, IsNegative(cpu.a))
 	cpu.SetFlag(Z, IsZero(cpu.a))
-
-	return true
 }
 
 // ASL - Shift Left One Bit (Memory or Accumulator)
@@ -232,8 +375,8 @@ func (cpu *CPU) and(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + + - - -
-func (cpu *CPU) asl(mode AddressMode, addr uint16) bool {
-	if mode == modeAccu {
+func (cpu *CPU) asl(mode AddressMode, addr uint16) {
+	if mode == ModeAccu {
 
 		cpu.SetFlag(C, uint16(cpu.a)<<1 > 0xFF)
 
@@ -243,6 +386,7 @@ func (cpu *CPU) asl(mode AddressMode, addr uint16) bool {
 		cpu.SetFlag(Z, IsZero(cpu.a))
 	} else {
 		M := cpu.Read(addr)
+		cpu.modifyCycle(addr, M)
 
 		cpu.SetFlag(C, uint16(M)<<1 > 0xFF)
 
@@ -253,8 +397,6 @@ func (cpu *CPU) asl(mode AddressMode, addr uint16) bool {
 
 		cpu.Write(addr, M)
 	}
-
-	return false
 }
 
 // BCC - Branch on Carry Clear
@@ -263,13 +405,10 @@ func (cpu *CPU) asl(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) bcc(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) bcc(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(C) == 0 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // BCS - Branch on Carry Set
@@ -278,13 +417,10 @@ func (cpu *CPU) bcc(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) bcs(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) bcs(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(C) == 1 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // BEQ - Branch on Result Zero
@@ -293,13 +429,10 @@ func (cpu *CPU) bcs(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) beq(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) beq(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(Z) == 1 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // BIT - Test Bits in Memory with Accumulator
@@ -311,15 +444,19 @@ func (cpu *CPU) beq(mode AddressMode, addr uint16) bool {
 //
 //	N  Z C I D V
 //	M7 + - - - M6
-func (cpu *CPU) bit(mode AddressMode, addr uint16) bool {
+//
+// The immediate variant of the 65C02 only affects Z.
+func (cpu *CPU) bit(mode AddressMode, addr uint16) {
 	A := cpu.a
 	M := cpu.Read(addr)
+	if mode == ModeImmd {
+		cpu.SetFlag(Z, IsZero(A&M))
+		return
+	}
 
 	cpu.SetFlag(N, IsNegative(M))
 	cpu.SetFlag(Z, IsZero(A&M))
 	cpu.SetFlag(V, M&0x40 == 0x40)
-
-	return false
 }
 
 // BMI - Branch on Result Minus
@@ -328,13 +465,10 @@ func (cpu *CPU) bit(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) bmi(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) bmi(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(N) == 1 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // BNE - Branch on Result not Zero
@@ -343,13 +477,10 @@ func (cpu *CPU) bmi(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) bne(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) bne(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(Z) == 0 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // BPL - Branch on Result Plus
@@ -358,13 +489,10 @@ func (cpu *CPU) bne(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) bpl(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) bpl(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(N) == 0 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // BRK - Force Break
@@ -383,15 +511,17 @@ func (cpu *CPU) bpl(mode AddressMode, addr uint16) bool {
 //
 //		N Z C I D V
 //		- - - 1 - -
-func (cpu *CPU) brk(mode AddressMode, addr uint16) bool {
-	cpu.SetFlag(I, true)
+func (cpu *CPU) brk(mode AddressMode, addr uint16) {
+	// skip the break mark, which was read as the dummy read of the implied addressing mode
+	cpu.pc++
 	cpu.Push16(cpu.pc)
-	cpu.SetFlag(B, true)
-	cpu.PushStatus()
-	cpu.SetFlag(B, false)
-	cpu.pc = cpu.Read16(0xFFFE)
-
-	return false
+	vector := cpu.interruptVector()
+	cpu.Push(cpu.p | uint8(B) | uint8(U))
+	cpu.interrupted = InterruptBRK
+	if vector == 0xFFFA {
+		cpu.interrupted = InterruptNMI
+	}
+	cpu.enterInterrupt(vector)
 }
 
 // BVC - Branch on Overflow Clear
@@ -400,13 +530,10 @@ func (cpu *CPU) brk(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) bvc(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) bvc(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(V) == 0 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // BVS - Branch on Overflow Set
@@ -415,13 +542,10 @@ func (cpu *CPU) bvc(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) bvs(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) bvs(mode AddressMode, addr uint16) {
 	if cpu.GetFlag(V) == 1 {
-		cpu.pc = addr
-		return true
+		cpu.branch(addr)
 	}
-
-	return false
 }
 
 // CLC - Clear Carry Flag
@@ -430,10 +554,8 @@ func (cpu *CPU) bvs(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - 0 - - -
-func (cpu *CPU) clc(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) clc(mode AddressMode, addr uint16) {
 	cpu.SetFlag(C, false)
-
-	return false
 }
 
 // CLD - Clear Decimal Mode
@@ -442,10 +564,8 @@ func (cpu *CPU) clc(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - 0 -
-func (cpu *CPU) cld(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) cld(mode AddressMode, addr uint16) {
 	cpu.SetFlag(D, false)
-
-	return false
 }
 
 // CLD - Clear Interrupt Disable Bit
@@ -454,10 +574,8 @@ func (cpu *CPU) cld(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - 0 - -
-func (cpu *CPU) cli(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) cli(mode AddressMode, addr uint16) {
 	cpu.SetFlag(I, false)
-
-	return false
 }
 
 // CLD - Clear Overflow Flag
@@ -466,10 +584,8 @@ func (cpu *CPU) cli(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - 0
-func (cpu *CPU) clv(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) clv(mode AddressMode, addr uint16) {
 	cpu.SetFlag(V, false)
-
-	return false
 }
 
 // CMP - Compare Memory with Accumulator
@@ -478,23 +594,25 @@ func (cpu *CPU) clv(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + + - - -
-func (cpu *CPU) cmp(mode AddressMode, addr uint16) bool {
-	A := cpu.a
-	M := cpu.Read(addr)
-	if A < M {
-		cpu.SetFlag(Z, false)
-		cpu.SetFlag(C, false)
-		cpu.SetFlag(N, IsNegative(A-M))
-	} else if A > M {
-		cpu.SetFlag(Z, false)
-		cpu.SetFlag(C, true)
-		cpu.SetFlag(N, IsNegative(A-M))
-	} else {
-		cpu.SetFlag(Z, true)
-		cpu.SetFlag(C, true)
-		cpu.SetFlag(N, IsNegative(A-M))
+func (cpu *CPU) cmp(mode AddressMode, addr uint16) {
+	cpu.compare(cpu.a, cpu.Read(addr))
+}
+
+// modifyCycle spends the cycle in which a read-modify-write instruction computes the new value.
+// The NMOS 6502 writes the unmodified value back during it, the 65C02 reads it again instead.
+func (cpu *CPU) modifyCycle(addr uint16, M uint8) {
+	if cpu.variant == CMOS65C02 {
+		cpu.Read(addr)
+		return
 	}
-	return true
+	cpu.Write(addr, M)
+}
+
+// compare sets the flags for the comparison of a register R with M, as done by CMP, CPX and CPY.
+func (cpu *CPU) compare(R, M uint8) {
+	cpu.SetFlag(N, IsNegative(R-M))
+	cpu.SetFlag(Z, R == M)
+	cpu.SetFlag(C, R >= M)
 }
 
 // CPX - Compare Memory and Index X
@@ -503,23 +621,8 @@ func (cpu *CPU) cmp(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + + - - -
-func (cpu *CPU) cpx(mode AddressMode, addr uint16) bool {
-	X := cpu.x
-	M := cpu.Read(addr)
-	if X < M {
-		cpu.SetFlag(Z, false)
-		cpu.SetFlag(C, false)
-		cpu.SetFlag(N, IsNegative(X-M))
-	} else if X > M {
-		cpu.SetFlag(Z, false)
-		cpu.SetFlag(C, true)
-		cpu.SetFlag(N, IsNegative(X-M))
-	} else {
-		cpu.SetFlag(Z, true)
-		cpu.SetFlag(C, true)
-		cpu.SetFlag(N, IsNegative(X-M))
-	}
-	return true
+func (cpu *CPU) cpx(mode AddressMode, addr uint16) {
+	cpu.compare(cpu.x, cpu.Read(addr))
 }
 
 // CPY - Compare Memory and Index Y
@@ -528,23 +631,8 @@ func (cpu *CPU) cpx(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + + - - -
-func (cpu *CPU) cpy(mode AddressMode, addr uint16) bool {
-	Y := cpu.y
-	M := cpu.Read(addr)
-	if Y < M {
-		cpu.SetFlag(Z, false)
-		cpu.SetFlag(C, false)
-		cpu.SetFlag(N, IsNegative(Y-M))
-	} else if Y > M {
-		cpu.SetFlag(Z, false)
-		cpu.SetFlag(C, true)
-		cpu.SetFlag(N, IsNegative(Y-M))
-	} else {
-		cpu.SetFlag(Z, true)
-		cpu.SetFlag(C, true)
-		cpu.SetFlag(N, IsNegative(Y-M))
-	}
-	return true
+func (cpu *CPU) cpy(mode AddressMode, addr uint16) {
+	cpu.compare(cpu.y, cpu.Read(addr))
 }
 
 // DEC - Decrement Memory by One
@@ -553,15 +641,23 @@ func (cpu *CPU) cpy(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) dec(mode AddressMode, addr uint16) bool {
+//
+// The accumulator variant only exists on the 65C02.
+func (cpu *CPU) dec(mode AddressMode, addr uint16) {
+	if mode == ModeAccu {
+		cpu.a -= 1
+		cpu.SetFlag(N, IsNegative(cpu.a))
+		cpu.SetFlag(Z, IsZero(cpu.a))
+		return
+	}
+
 	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
 	M -= 1
 	cpu.Write(addr, M)
 
 	cpu.SetFlag(N, IsNegative(M))
 	cpu.SetFlag(Z, IsZero(M))
-
-	return false
 }
 
 // DEX - Decrement Index X by One
@@ -570,13 +666,11 @@ func (cpu *CPU) dec(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) dex(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) dex(mode AddressMode, addr uint16) {
 	cpu.x--
 
 	cpu.SetFlag(N, IsNegative(cpu.x))
 	cpu.SetFlag(Z, IsZero(cpu.x))
-
-	return false
 }
 
 // DEY - Decrement Index Y by One
@@ -585,13 +679,11 @@ func (cpu *CPU) dex(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) dey(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) dey(mode AddressMode, addr uint16) {
 	cpu.y--
 
 	cpu.SetFlag(N, IsNegative(cpu.y))
 	cpu.SetFlag(Z, IsZero(cpu.y))
-
-	return false
 }
 
 // EOR - Exclusive-OR Memory with Accumulator
@@ -600,7 +692,7 @@ func (cpu *CPU) dey(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) eor(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) eor(mode AddressMode, addr uint16) {
 	A := cpu.a
 	M := cpu.Read(addr)
 	A ^= M
@@ -608,8 +700,6 @@ func (cpu *CPU) eor(mode AddressMode, addr uint16) bool {
 
 	cpu.SetFlag(N, IsNegative(A))
 	cpu.SetFlag(Z, IsZero(A))
-
-	return true
 }
 
 // INC - Increment Memory by One
@@ -618,15 +708,23 @@ func (cpu *CPU) eor(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) inc(mode AddressMode, addr uint16) bool {
+//
+// The accumulator variant only exists on the 65C02.
+func (cpu *CPU) inc(mode AddressMode, addr uint16) {
+	if mode == ModeAccu {
+		cpu.a += 1
+		cpu.SetFlag(N, IsNegative(cpu.a))
+		cpu.SetFlag(Z, IsZero(cpu.a))
+		return
+	}
+
 	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
 	M += 1
 	cpu.Write(addr, M)
 
 	cpu.SetFlag(N, IsNegative(M))
 	cpu.SetFlag(Z, IsZero(M))
-
-	return false
 }
 
 // INX - Increment Index X by One
@@ -635,13 +733,11 @@ func (cpu *CPU) inc(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) inx(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) inx(mode AddressMode, addr uint16) {
 	cpu.x++
 
 	cpu.SetFlag(N, IsNegative(cpu.x))
 	cpu.SetFlag(Z, IsZero(cpu.x))
-
-	return false
 }
 
 // INY - Increment Index Y by One
@@ -650,13 +746,11 @@ func (cpu *CPU) inx(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) iny(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) iny(mode AddressMode, addr uint16) {
 	cpu.y++
 
 	cpu.SetFlag(N, IsNegative(cpu.y))
 	cpu.SetFlag(Z, IsZero(cpu.y))
-
-	return false
 }
 
 // JMP - Jump to New Location
@@ -666,10 +760,8 @@ func (cpu *CPU) iny(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) jmp(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) jmp(mode AddressMode, addr uint16) {
 	cpu.pc = addr
-
-	return false
 }
 
 // JMP - Jump to New Location Saving Return Address
@@ -680,11 +772,12 @@ func (cpu *CPU) jmp(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) jsr(mode AddressMode, addr uint16) bool {
-	cpu.Push16(cpu.pc - 1) // ?!?!
-	cpu.pc = addr
-
-	return false
+func (cpu *CPU) jsr(mode AddressMode, addr uint16) {
+	// dummy read of the stack while the lo-byte of the address is buffered
+	cpu.Read(0x100 | uint16(cpu.sp))
+	// the return address pushed is that of the hi-byte of the operand, which is read last
+	cpu.Push16(cpu.pc - 1)
+	cpu.pc = uint16(cpu.Read(cpu.pc-1))<<8 | addr
 }
 
 // LDA - Load Accumulator with Memory
@@ -693,13 +786,11 @@ func (cpu *CPU) jsr(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) lda(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) lda(mode AddressMode, addr uint16) {
 	cpu.a = cpu.Read(addr)
 
 	cpu.SetFlag(N, IsNegative(cpu.a))
 	cpu.SetFlag(Z, IsZero(cpu.a))
-
-	return true
 }
 
 // LDX - Load Index X with Memory
@@ -708,13 +799,11 @@ func (cpu *CPU) lda(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) ldx(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) ldx(mode AddressMode, addr uint16) {
 	cpu.x = cpu.Read(addr)
 
 	cpu.SetFlag(N, IsNegative(cpu.x))
 	cpu.SetFlag(Z, IsZero(cpu.x))
-
-	return true
 }
 
 // LDY - Load Index Y with Memory
@@ -723,13 +812,11 @@ func (cpu *CPU) ldx(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) ldy(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) ldy(mode AddressMode, addr uint16) {
 	cpu.y = cpu.Read(addr)
 
 	cpu.SetFlag(N, IsNegative(cpu.y))
 	cpu.SetFlag(Z, IsZero(cpu.y))
-
-	return true
 }
 
 // LSR - Shift One Bit Right (Memory or Accumulator)
@@ -738,27 +825,30 @@ func (cpu *CPU) ldy(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	0 + + - - -
-func (cpu *CPU) lsr(mode AddressMode, addr uint16) bool {
-	if mode == modeAccu {
+func (cpu *CPU) lsr(mode AddressMode, addr uint16) {
+	if mode == ModeAccu {
 		cpu.SetFlag(C, cpu.a&1 > 0)
 		cpu.a >>= 1
 		cpu.SetFlag(N, IsNegative(cpu.a))
 		cpu.SetFlag(Z, IsZero(cpu.a))
 	} else {
 		M := cpu.Read(addr)
+		cpu.modifyCycle(addr, M)
 		cpu.SetFlag(C, M&1 > 0)
 		M >>= 1
 		cpu.SetFlag(N, IsNegative(M))
 		cpu.SetFlag(Z, IsZero(M))
 		cpu.Write(addr, M)
 	}
-
-	return false
 }
 
 // NOP - No Operation
-func (cpu *CPU) nop(mode AddressMode, addr uint16) bool {
-	return false
+//
+// The unofficial variants with an operand read it, and discard the value.
+func (cpu *CPU) nop(mode AddressMode, addr uint16) {
+	if mode != ModeImpl && mode != ModeNone {
+		cpu.Read(addr)
+	}
 }
 
 // ORA - OR Memory with Accumulator
@@ -767,13 +857,11 @@ func (cpu *CPU) nop(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) ora(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) ora(mode AddressMode, addr uint16) {
 	cpu.a |= cpu.Read(addr)
 
 	cpu.SetFlag(N, IsNegative(cpu.a))
 	cpu.SetFlag(Z, IsZero(cpu.a))
-
-	return true
 }
 
 // PHA - Push Accumulator on Stack
@@ -782,10 +870,8 @@ func (cpu *CPU) ora(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) pha(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) pha(mode AddressMode, addr uint16) {
 	cpu.Push(cpu.a)
-
-	return false
 }
 
 // PHP - Push Processor Status on Stack
@@ -794,13 +880,11 @@ func (cpu *CPU) pha(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) php(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) php(mode AddressMode, addr uint16) {
 	cpu.SetFlag(U, true)
 	cpu.SetFlag(B, true)
 	cpu.PushStatus()
 	cpu.SetFlag(B, false)
-
-	return false
 }
 
 // PLA - Pull Accumulator from Stack
@@ -809,13 +893,12 @@ func (cpu *CPU) php(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) pla(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) pla(mode AddressMode, addr uint16) {
+	cpu.Read(0x100 | uint16(cpu.sp)) // dummy read of the stack while the stack pointer is incremented
 	cpu.a = cpu.Pull()
 
 	cpu.SetFlag(N, IsNegative(cpu.a))
 	cpu.SetFlag(Z, IsZero(cpu.a))
-
-	return false
 }
 
 // PLP - Pull Processor Status from Stack
@@ -824,10 +907,9 @@ func (cpu *CPU) pla(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	from stack
-func (cpu *CPU) plp(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) plp(mode AddressMode, addr uint16) {
+	cpu.Read(0x100 | uint16(cpu.sp)) // dummy read of the stack while the stack pointer is incremented
 	cpu.PullStatus()
-
-	return false
 }
 
 // ROL - Rotate One Bit Left (Memory or Accumulator)
@@ -836,8 +918,8 @@ func (cpu *CPU) plp(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + + - - -
-func (cpu *CPU) rol(mode AddressMode, addr uint16) bool {
-	if mode == modeAccu {
+func (cpu *CPU) rol(mode AddressMode, addr uint16) {
+	if mode == ModeAccu {
 		carry := cpu.GetFlag(C)
 		cpu.SetFlag(C, cpu.a>>7 == 1)
 		cpu.a = cpu.a<<1 | carry
@@ -847,6 +929,7 @@ func (cpu *CPU) rol(mode AddressMode, addr uint16) bool {
 	} else {
 		carry := cpu.GetFlag(C)
 		M := cpu.Read(addr)
+		cpu.modifyCycle(addr, M)
 		cpu.SetFlag(C, M>>7 == 1)
 		M = M<<1 | carry
 
@@ -854,8 +937,6 @@ func (cpu *CPU) rol(mode AddressMode, addr uint16) bool {
 		cpu.SetFlag(Z, IsZero(M))
 		cpu.Write(addr, M)
 	}
-
-	return false
 }
 
 // ROR - Rotate One Bit Right (Memory or Accumulator)
@@ -864,8 +945,8 @@ func (cpu *CPU) rol(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + + - - -
-func (cpu *CPU) ror(mode AddressMode, addr uint16) bool {
-	if mode == modeAccu {
+func (cpu *CPU) ror(mode AddressMode, addr uint16) {
+	if mode == ModeAccu {
 		carry := cpu.GetFlag(C)
 		cpu.SetFlag(C, cpu.a&1 == 1)
 		cpu.a = cpu.a>>1 | carry<<7
@@ -875,6 +956,7 @@ func (cpu *CPU) ror(mode AddressMode, addr uint16) bool {
 	} else {
 		carry := cpu.GetFlag(C)
 		M := cpu.Read(addr)
+		cpu.modifyCycle(addr, M)
 		cpu.SetFlag(C, M&1 == 1)
 		M = M>>1 | carry<<7
 
@@ -882,8 +964,6 @@ func (cpu *CPU) ror(mode AddressMode, addr uint16) bool {
 		cpu.SetFlag(Z, IsZero(M))
 		cpu.Write(addr, M)
 	}
-
-	return false
 }
 
 // RTI - Return from Interrupt
@@ -895,11 +975,10 @@ func (cpu *CPU) ror(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	from stack
-func (cpu *CPU) rti(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) rti(mode AddressMode, addr uint16) {
+	cpu.Read(0x100 | uint16(cpu.sp)) // dummy read of the stack while the stack pointer is incremented
 	cpu.PullStatus()
 	cpu.pc = cpu.Pull16()
-
-	return false
 }
 
 // RTS - Return from Subroutine
@@ -908,10 +987,11 @@ func (cpu *CPU) rti(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) rts(mode AddressMode, addr uint16) bool {
-	cpu.pc = cpu.Pull16() + 1
-
-	return false
+func (cpu *CPU) rts(mode AddressMode, addr uint16) {
+	cpu.Read(0x100 | uint16(cpu.sp)) // dummy read of the stack while the stack pointer is incremented
+	cpu.pc = cpu.Pull16()
+	cpu.Read(cpu.pc) // dummy read while PC is incremented
+	cpu.pc++
 }
 
 // SBC - Subtract Memory from Accumulator with Borrow
@@ -920,19 +1000,8 @@ func (cpu *CPU) rts(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + + - - +
-func (cpu *CPU) sbc(mode AddressMode, addr uint16) bool {
-	A := uint16(cpu.a)
-	M := uint16(cpu.Read(addr)) ^ 0x00FF
-	carry := uint16(cpu.GetFlag(C))
-	temp := A + M + carry
-	cpu.a = uint8(temp)
-
-	cpu.SetFlag(N, IsNegative(cpu.a))
-	cpu.SetFlag(Z, IsZero(cpu.a))
-	cpu.SetFlag(C, A+M+carry > 0xFF)
-	cpu.SetFlag(V, (A^M)&0x80 == 0 && (A^temp)&0x80 != 0)
-
-	return true
+func (cpu *CPU) sbc(mode AddressMode, addr uint16) {
+	cpu.subtract(cpu.Read(addr))
 }
 
 // SEC - Set Carry Flag
@@ -941,10 +1010,8 @@ func (cpu *CPU) sbc(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - 1 - - -
-func (cpu *CPU) sec(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) sec(mode AddressMode, addr uint16) {
 	cpu.SetFlag(C, true)
-
-	return false
 }
 
 // SED - Set Decimal Flag
@@ -953,10 +1020,8 @@ func (cpu *CPU) sec(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - 1 -
-func (cpu *CPU) sed(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) sed(mode AddressMode, addr uint16) {
 	cpu.SetFlag(D, true)
-
-	return false
 }
 
 // SEI - Set Interrupt Disable Status
@@ -965,10 +1030,8 @@ func (cpu *CPU) sed(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - 1 - -
-func (cpu *CPU) sei(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) sei(mode AddressMode, addr uint16) {
 	cpu.SetFlag(I, true)
-
-	return false
 }
 
 // STA - Store Accumulator in Memory
@@ -977,10 +1040,8 @@ func (cpu *CPU) sei(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) sta(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) sta(mode AddressMode, addr uint16) {
 	cpu.Write(addr, cpu.a)
-
-	return false
 }
 
 // STX - Store Index X in Memory
@@ -989,10 +1050,8 @@ func (cpu *CPU) sta(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) stx(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) stx(mode AddressMode, addr uint16) {
 	cpu.Write(addr, cpu.x)
-
-	return false
 }
 
 // STY - Store Index Y in Memory
@@ -1001,10 +1060,8 @@ func (cpu *CPU) stx(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) sty(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) sty(mode AddressMode, addr uint16) {
 	cpu.Write(addr, cpu.y)
-
-	return false
 }
 
 // TAX - Transfer Accumulator to Index X
@@ -1013,13 +1070,11 @@ func (cpu *CPU) sty(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) tax(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) tax(mode AddressMode, addr uint16) {
 	cpu.x = cpu.a
 
 	cpu.SetFlag(N, IsNegative(cpu.x))
 	cpu.SetFlag(Z, IsZero(cpu.x))
-
-	return false
 }
 
 // TAY - Transfer Accumulator to Index Y
@@ -1028,13 +1083,11 @@ func (cpu *CPU) tax(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) tay(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) tay(mode AddressMode, addr uint16) {
 	cpu.y = cpu.a
 
 	cpu.SetFlag(N, IsNegative(cpu.y))
 	cpu.SetFlag(Z, IsZero(cpu.y))
-
-	return false
 }
 
 // TSX - Transfer Stack Pointer to Index X
@@ -1043,13 +1096,11 @@ func (cpu *CPU) tay(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) tsx(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) tsx(mode AddressMode, addr uint16) {
 	cpu.x = cpu.sp
 
 	cpu.SetFlag(N, IsNegative(cpu.x))
 	cpu.SetFlag(Z, IsZero(cpu.x))
-
-	return false
 }
 
 // TXA - Transfer Index X to Accumulator
@@ -1058,13 +1109,11 @@ func (cpu *CPU) tsx(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) txa(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) txa(mode AddressMode, addr uint16) {
 	cpu.a = cpu.x
 
 	cpu.SetFlag(N, IsNegative(cpu.a))
 	cpu.SetFlag(Z, IsZero(cpu.a))
-
-	return false
 }
 
 // TXS - Transfer Index X to Stack Register
@@ -1073,10 +1122,8 @@ func (cpu *CPU) txa(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	- - - - - -
-func (cpu *CPU) txs(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) txs(mode AddressMode, addr uint16) {
 	cpu.sp = cpu.x
-
-	return false
 }
 
 // TYA - Transfer Index Y to Accumulator
@@ -1085,21 +1132,43 @@ func (cpu *CPU) txs(mode AddressMode, addr uint16) bool {
 //
 //	N Z C I D V
 //	+ + - - - -
-func (cpu *CPU) tya(mode AddressMode, addr uint16) bool {
+func (cpu *CPU) tya(mode AddressMode, addr uint16) {
 	cpu.a = cpu.y
 
 	cpu.SetFlag(N, IsNegative(cpu.a))
 	cpu.SetFlag(Z, IsZero(cpu.a))
+}
 
-	return false
+// Hardware interrupt (NMI or IRQ), see: https://www.nesdev.org/wiki/CPU_interrupts
+// The first 2 cycles do dummy reads of the next opcode, which is then not executed.
+// The status register is pushed with the break flag cleared.
+func (cpu *CPU) interrupt() {
+	cpu.Read(cpu.pc)
+	cpu.Read(cpu.pc)
+	cpu.Push16(cpu.pc)
+	vector := cpu.interruptVector()
+	cpu.Push(cpu.p&^uint8(B) | uint8(U))
+	cpu.interrupted = InterruptIRQ
+	if vector == 0xFFFA {
+		cpu.interrupted = InterruptNMI
+	}
+	cpu.enterInterrupt(vector)
+}
+
+// interruptVector returns the address of the vector to jump through, an NMI takes over an IRQ or BRK in progress.
+func (cpu *CPU) interruptVector() uint16 {
+	if cpu.lines.needNmi {
+		cpu.lines.needNmi = false
+		return 0xFFFA
+	}
+	return 0xFFFE
 }
 
-// Non-maskable interrupt
-func (cpu *CPU) nmi() {
+// enterInterrupt masks interrupts and jumps through the given vector, once the return address and status are pushed.
+func (cpu *CPU) enterInterrupt(vector uint16) {
 	cpu.SetFlag(I, true)
-	cpu.Push16(cpu.pc)
-	cpu.SetFlag(B, true)
-	cpu.PushStatus()
-	cpu.SetFlag(B, false)
-	cpu.pc = cpu.Read16(0xFFFA)
+	if cpu.variant == CMOS65C02 {
+		cpu.SetFlag(D, false)
+	}
+	cpu.pc = cpu.Read16(vector)
 }
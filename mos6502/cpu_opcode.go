@@ -0,0 +1,78 @@
+// Reference document: https://www.masswerk.at/6502/6502_instruction_set.html
+
+package mos6502
+
+type OpcodeInfo struct {
+	addrMode     AddressMode
+	addrModeFunc AddressModeFunc
+	inst         Instruction
+	instFunc     InstructionFunc
+	instSize     uint8
+	instCycles   uint8
+}
+
+func (cpu *CPU) InitOpcodeTable() {
+	cpu.table = [256]OpcodeInfo{
+		{ModeImpl, cpu.Impl, BRK, cpu.brk, 1, 7}, {ModeXInd, cpu.XInd, ORA, cpu.ora, 2, 6}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeXInd, cpu.XInd, SLO, cpu.slo, 2, 8}, {ModeZpag, cpu.Zpag, NOP, cpu.nop, 2, 3}, {ModeZpag, cpu.Zpag, ORA, cpu.ora, 2, 3}, {ModeZpag, cpu.Zpag, ASL, cpu.asl, 2, 5}, {ModeZpag, cpu.Zpag, SLO, cpu.slo, 2, 5}, {ModeImpl, cpu.Impl, PHP, cpu.php, 1, 3}, {ModeImmd, cpu.Immd, ORA, cpu.ora, 2, 2}, {ModeAccu, cpu.Accu, ASL, cpu.asl, 1, 2}, {ModeImmd, cpu.Immd, ANC, cpu.anc, 2, 2}, {ModeAbso, cpu.Abso, NOP, cpu.nop, 3, 4}, {ModeAbso, cpu.Abso, ORA, cpu.ora, 3, 4}, {ModeAbso, cpu.Abso, ASL, cpu.asl, 3, 6}, {ModeAbso, cpu.Abso, SLO, cpu.slo, 3, 6},
+		{ModeRela, cpu.Rela, BPL, cpu.bpl, 2, 2}, {ModeIndY, cpu.IndY, ORA, cpu.ora, 2, 5}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, SLO, cpu.slo, 2, 8}, {ModeZpgX, cpu.ZpgX, NOP, cpu.nop, 2, 4}, {ModeZpgX, cpu.ZpgX, ORA, cpu.ora, 2, 4}, {ModeZpgX, cpu.ZpgX, ASL, cpu.asl, 2, 6}, {ModeZpgX, cpu.ZpgX, SLO, cpu.slo, 2, 6}, {ModeImpl, cpu.Impl, CLC, cpu.clc, 1, 2}, {ModeAbsY, cpu.AbsY, ORA, cpu.ora, 3, 4}, {ModeImpl, cpu.Impl, NOP, cpu.nop, 1, 2}, {ModeAbsY, cpu.AbsY, SLO, cpu.slo, 3, 7}, {ModeAbsX, cpu.AbsX, NOP, cpu.nop, 3, 4}, {ModeAbsX, cpu.AbsX, ORA, cpu.ora, 3, 4}, {ModeAbsX, cpu.AbsX, ASL, cpu.asl, 3, 7}, {ModeAbsX, cpu.AbsX, SLO, cpu.slo, 3, 7},
+		{ModeAbso, cpu.jsrAbso, JSR, cpu.jsr, 3, 6}, {ModeXInd, cpu.XInd, AND, cpu.and, 2, 6}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeXInd, cpu.XInd, RLA, cpu.rla, 2, 8}, {ModeZpag, cpu.Zpag, BIT, cpu.bit, 2, 3}, {ModeZpag, cpu.Zpag, AND, cpu.and, 2, 3}, {ModeZpag, cpu.Zpag, ROL, cpu.rol, 2, 5}, {ModeZpag, cpu.Zpag, RLA, cpu.rla, 2, 5}, {ModeImpl, cpu.Impl, PLP, cpu.plp, 1, 4}, {ModeImmd, cpu.Immd, AND, cpu.and, 2, 2}, {ModeAccu, cpu.Accu, ROL, cpu.rol, 1, 2}, {ModeImmd, cpu.Immd, ANC, cpu.anc, 2, 2}, {ModeAbso, cpu.Abso, BIT, cpu.bit, 3, 4}, {ModeAbso, cpu.Abso, AND, cpu.and, 3, 4}, {ModeAbso, cpu.Abso, ROL, cpu.rol, 3, 6}, {ModeAbso, cpu.Abso, RLA, cpu.rla, 3, 6},
+		{ModeRela, cpu.Rela, BMI, cpu.bmi, 2, 2}, {ModeIndY, cpu.IndY, AND, cpu.and, 2, 5}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, RLA, cpu.rla, 2, 8}, {ModeZpgX, cpu.ZpgX, NOP, cpu.nop, 2, 4}, {ModeZpgX, cpu.ZpgX, AND, cpu.and, 2, 4}, {ModeZpgX, cpu.ZpgX, ROL, cpu.rol, 2, 6}, {ModeZpgX, cpu.ZpgX, RLA, cpu.rla, 2, 6}, {ModeImpl, cpu.Impl, SEC, cpu.sec, 1, 2}, {ModeAbsY, cpu.AbsY, AND, cpu.and, 3, 4}, {ModeImpl, cpu.Impl, NOP, cpu.nop, 1, 2}, {ModeAbsY, cpu.AbsY, RLA, cpu.rla, 3, 7}, {ModeAbsX, cpu.AbsX, NOP, cpu.nop, 3, 4}, {ModeAbsX, cpu.AbsX, AND, cpu.and, 3, 4}, {ModeAbsX, cpu.AbsX, ROL, cpu.rol, 3, 7}, {ModeAbsX, cpu.AbsX, RLA, cpu.rla, 3, 7},
+		{ModeImpl, cpu.Impl, RTI, cpu.rti, 1, 6}, {ModeXInd, cpu.XInd, EOR, cpu.eor, 2, 6}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeXInd, cpu.XInd, SRE, cpu.sre, 2, 8}, {ModeZpag, cpu.Zpag, NOP, cpu.nop, 2, 3}, {ModeZpag, cpu.Zpag, EOR, cpu.eor, 2, 3}, {ModeZpag, cpu.Zpag, LSR, cpu.lsr, 2, 5}, {ModeZpag, cpu.Zpag, SRE, cpu.sre, 2, 5}, {ModeImpl, cpu.Impl, PHA, cpu.pha, 1, 3}, {ModeImmd, cpu.Immd, EOR, cpu.eor, 2, 2}, {ModeAccu, cpu.Accu, LSR, cpu.lsr, 1, 2}, {ModeImmd, cpu.Immd, ALR, cpu.alr, 2, 2}, {ModeAbso, cpu.Abso, JMP, cpu.jmp, 3, 3}, {ModeAbso, cpu.Abso, EOR, cpu.eor, 3, 4}, {ModeAbso, cpu.Abso, LSR, cpu.lsr, 3, 6}, {ModeAbso, cpu.Abso, SRE, cpu.sre, 3, 6},
+		{ModeRela, cpu.Rela, BVC, cpu.bvc, 2, 2}, {ModeIndY, cpu.IndY, EOR, cpu.eor, 2, 5}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, SRE, cpu.sre, 2, 8}, {ModeZpgX, cpu.ZpgX, NOP, cpu.nop, 2, 4}, {ModeZpgX, cpu.ZpgX, EOR, cpu.eor, 2, 4}, {ModeZpgX, cpu.ZpgX, LSR, cpu.lsr, 2, 6}, {ModeZpgX, cpu.ZpgX, SRE, cpu.sre, 2, 6}, {ModeImpl, cpu.Impl, CLI, cpu.cli, 1, 2}, {ModeAbsY, cpu.AbsY, EOR, cpu.eor, 3, 4}, {ModeImpl, cpu.Impl, NOP, cpu.nop, 1, 2}, {ModeAbsY, cpu.AbsY, SRE, cpu.sre, 3, 7}, {ModeAbsX, cpu.AbsX, NOP, cpu.nop, 3, 4}, {ModeAbsX, cpu.AbsX, EOR, cpu.eor, 3, 4}, {ModeAbsX, cpu.AbsX, LSR, cpu.lsr, 3, 7}, {ModeAbsX, cpu.AbsX, SRE, cpu.sre, 3, 7},
+		{ModeImpl, cpu.Impl, RTS, cpu.rts, 1, 6}, {ModeXInd, cpu.XInd, ADC, cpu.adc, 2, 6}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeXInd, cpu.XInd, RRA, cpu.rra, 2, 8}, {ModeZpag, cpu.Zpag, NOP, cpu.nop, 2, 3}, {ModeZpag, cpu.Zpag, ADC, cpu.adc, 2, 3}, {ModeZpag, cpu.Zpag, ROR, cpu.ror, 2, 5}, {ModeZpag, cpu.Zpag, RRA, cpu.rra, 2, 5}, {ModeImpl, cpu.Impl, PLA, cpu.pla, 1, 4}, {ModeImmd, cpu.Immd, ADC, cpu.adc, 2, 2}, {ModeAccu, cpu.Accu, ROR, cpu.ror, 1, 2}, {ModeImmd, cpu.Immd, ARR, cpu.arr, 2, 2}, {ModeIndi, cpu.Indi, JMP, cpu.jmp, 3, 5}, {ModeAbso, cpu.Abso, ADC, cpu.adc, 3, 4}, {ModeAbso, cpu.Abso, ROR, cpu.ror, 3, 6}, {ModeAbso, cpu.Abso, RRA, cpu.rra, 3, 6},
+		{ModeRela, cpu.Rela, BVS, cpu.bvs, 2, 2}, {ModeIndY, cpu.IndY, ADC, cpu.adc, 2, 5}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, RRA, cpu.rra, 2, 8}, {ModeZpgX, cpu.ZpgX, NOP, cpu.nop, 2, 4}, {ModeZpgX, cpu.ZpgX, ADC, cpu.adc, 2, 4}, {ModeZpgX, cpu.ZpgX, ROR, cpu.ror, 2, 6}, {ModeZpgX, cpu.ZpgX, RRA, cpu.rra, 2, 6}, {ModeImpl, cpu.Impl, SEI, cpu.sei, 1, 2}, {ModeAbsY, cpu.AbsY, ADC, cpu.adc, 3, 4}, {ModeImpl, cpu.Impl, NOP, cpu.nop, 1, 2}, {ModeAbsY, cpu.AbsY, RRA, cpu.rra, 3, 7}, {ModeAbsX, cpu.AbsX, NOP, cpu.nop, 3, 4}, {ModeAbsX, cpu.AbsX, ADC, cpu.adc, 3, 4}, {ModeAbsX, cpu.AbsX, ROR, cpu.ror, 3, 7}, {ModeAbsX, cpu.AbsX, RRA, cpu.rra, 3, 7},
+		{ModeImmd, cpu.Immd, NOP, cpu.nop, 2, 2}, {ModeXInd, cpu.XInd, STA, cpu.sta, 2, 6}, {ModeImmd, cpu.Immd, NOP, cpu.nop, 2, 2}, {ModeXInd, cpu.XInd, SAX, cpu.sax, 2, 6}, {ModeZpag, cpu.Zpag, STY, cpu.sty, 2, 3}, {ModeZpag, cpu.Zpag, STA, cpu.sta, 2, 3}, {ModeZpag, cpu.Zpag, STX, cpu.stx, 2, 3}, {ModeZpag, cpu.Zpag, SAX, cpu.sax, 2, 3}, {ModeImpl, cpu.Impl, DEY, cpu.dey, 1, 2}, {ModeImmd, cpu.Immd, NOP, cpu.nop, 2, 2}, {ModeImpl, cpu.Impl, TXA, cpu.txa, 1, 2}, {ModeImmd, cpu.Immd, XAA, cpu.xaa, 2, 2}, {ModeAbso, cpu.Abso, STY, cpu.sty, 3, 4}, {ModeAbso, cpu.Abso, STA, cpu.sta, 3, 4}, {ModeAbso, cpu.Abso, STX, cpu.stx, 3, 4}, {ModeAbso, cpu.Abso, SAX, cpu.sax, 3, 4},
+		{ModeRela, cpu.Rela, BCC, cpu.bcc, 2, 2}, {ModeIndY, cpu.IndY, STA, cpu.sta, 2, 6}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, SHA, cpu.sha, 2, 6}, {ModeZpgX, cpu.ZpgX, STY, cpu.sty, 2, 4}, {ModeZpgX, cpu.ZpgX, STA, cpu.sta, 2, 4}, {ModeZpgY, cpu.ZpgY, STX, cpu.stx, 2, 4}, {ModeZpgY, cpu.ZpgY, SAX, cpu.sax, 2, 4}, {ModeImpl, cpu.Impl, TYA, cpu.tya, 1, 2}, {ModeAbsY, cpu.AbsY, STA, cpu.sta, 3, 5}, {ModeImpl, cpu.Impl, TXS, cpu.txs, 1, 2}, {ModeAbsY, cpu.AbsY, TAS, cpu.tas, 3, 5}, {ModeAbsX, cpu.AbsX, SHY, cpu.shy, 3, 5}, {ModeAbsX, cpu.AbsX, STA, cpu.sta, 3, 5}, {ModeAbsY, cpu.AbsY, SHX, cpu.shx, 3, 5}, {ModeAbsY, cpu.AbsY, SHA, cpu.sha, 3, 5},
+		{ModeImmd, cpu.Immd, LDY, cpu.ldy, 2, 2}, {ModeXInd, cpu.XInd, LDA, cpu.lda, 2, 6}, {ModeImmd, cpu.Immd, LDX, cpu.ldx, 2, 2}, {ModeXInd, cpu.XInd, LAX, cpu.lax, 2, 6}, {ModeZpag, cpu.Zpag, LDY, cpu.ldy, 2, 3}, {ModeZpag, cpu.Zpag, LDA, cpu.lda, 2, 3}, {ModeZpag, cpu.Zpag, LDX, cpu.ldx, 2, 3}, {ModeZpag, cpu.Zpag, LAX, cpu.lax, 2, 3}, {ModeImpl, cpu.Impl, TAY, cpu.tay, 1, 2}, {ModeImmd, cpu.Immd, LDA, cpu.lda, 2, 2}, {ModeImpl, cpu.Impl, TAX, cpu.tax, 1, 2}, {ModeImmd, cpu.Immd, LXA, cpu.lxa, 2, 2}, {ModeAbso, cpu.Abso, LDY, cpu.ldy, 3, 4}, {ModeAbso, cpu.Abso, LDA, cpu.lda, 3, 4}, {ModeAbso, cpu.Abso, LDX, cpu.ldx, 3, 4}, {ModeAbso, cpu.Abso, LAX, cpu.lax, 3, 4},
+		{ModeRela, cpu.Rela, BCS, cpu.bcs, 2, 2}, {ModeIndY, cpu.IndY, LDA, cpu.lda, 2, 5}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, LAX, cpu.lax, 2, 5}, {ModeZpgX, cpu.ZpgX, LDY, cpu.ldy, 2, 4}, {ModeZpgX, cpu.ZpgX, LDA, cpu.lda, 2, 4}, {ModeZpgY, cpu.ZpgY, LDX, cpu.ldx, 2, 4}, {ModeZpgY, cpu.ZpgY, LAX, cpu.lax, 2, 4}, {ModeImpl, cpu.Impl, CLV, cpu.clv, 1, 2}, {ModeAbsY, cpu.AbsY, LDA, cpu.lda, 3, 4}, {ModeImpl, cpu.Impl, TSX, cpu.tsx, 1, 2}, {ModeAbsY, cpu.AbsY, LAS, cpu.las, 3, 4}, {ModeAbsX, cpu.AbsX, LDY, cpu.ldy, 3, 4}, {ModeAbsX, cpu.AbsX, LDA, cpu.lda, 3, 4}, {ModeAbsY, cpu.AbsY, LDX, cpu.ldx, 3, 4}, {ModeAbsY, cpu.AbsY, LAX, cpu.lax, 3, 4},
+		{ModeImmd, cpu.Immd, CPY, cpu.cpy, 2, 2}, {ModeXInd, cpu.XInd, CMP, cpu.cmp, 2, 6}, {ModeImmd, cpu.Immd, NOP, cpu.nop, 2, 2}, {ModeXInd, cpu.XInd, DCP, cpu.dcp, 2, 8}, {ModeZpag, cpu.Zpag, CPY, cpu.cpy, 2, 3}, {ModeZpag, cpu.Zpag, CMP, cpu.cmp, 2, 3}, {ModeZpag, cpu.Zpag, DEC, cpu.dec, 2, 5}, {ModeZpag, cpu.Zpag, DCP, cpu.dcp, 2, 5}, {ModeImpl, cpu.Impl, INY, cpu.iny, 1, 2}, {ModeImmd, cpu.Immd, CMP, cpu.cmp, 2, 2}, {ModeImpl, cpu.Impl, DEX, cpu.dex, 1, 2}, {ModeImmd, cpu.Immd, AXS, cpu.axs, 2, 2}, {ModeAbso, cpu.Abso, CPY, cpu.cpy, 3, 4}, {ModeAbso, cpu.Abso, CMP, cpu.cmp, 3, 4}, {ModeAbso, cpu.Abso, DEC, cpu.dec, 3, 6}, {ModeAbso, cpu.Abso, DCP, cpu.dcp, 3, 6},
+		{ModeRela, cpu.Rela, BNE, cpu.bne, 2, 2}, {ModeIndY, cpu.IndY, CMP, cpu.cmp, 2, 5}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, DCP, cpu.dcp, 2, 8}, {ModeZpgX, cpu.ZpgX, NOP, cpu.nop, 2, 4}, {ModeZpgX, cpu.ZpgX, CMP, cpu.cmp, 2, 4}, {ModeZpgX, cpu.ZpgX, DEC, cpu.dec, 2, 6}, {ModeZpgX, cpu.ZpgX, DCP, cpu.dcp, 2, 6}, {ModeImpl, cpu.Impl, CLD, cpu.cld, 1, 2}, {ModeAbsY, cpu.AbsY, CMP, cpu.cmp, 3, 4}, {ModeImpl, cpu.Impl, NOP, cpu.nop, 1, 2}, {ModeAbsY, cpu.AbsY, DCP, cpu.dcp, 3, 7}, {ModeAbsX, cpu.AbsX, NOP, cpu.nop, 3, 4}, {ModeAbsX, cpu.AbsX, CMP, cpu.cmp, 3, 4}, {ModeAbsX, cpu.AbsX, DEC, cpu.dec, 3, 7}, {ModeAbsX, cpu.AbsX, DCP, cpu.dcp, 3, 7},
+		{ModeImmd, cpu.Immd, CPX, cpu.cpx, 2, 2}, {ModeXInd, cpu.XInd, SBC, cpu.sbc, 2, 6}, {ModeImmd, cpu.Immd, NOP, cpu.nop, 2, 2}, {ModeXInd, cpu.XInd, ISC, cpu.isc, 2, 8}, {ModeZpag, cpu.Zpag, CPX, cpu.cpx, 2, 3}, {ModeZpag, cpu.Zpag, SBC, cpu.sbc, 2, 3}, {ModeZpag, cpu.Zpag, INC, cpu.inc, 2, 5}, {ModeZpag, cpu.Zpag, ISC, cpu.isc, 2, 5}, {ModeImpl, cpu.Impl, INX, cpu.inx, 1, 2}, {ModeImmd, cpu.Immd, SBC, cpu.sbc, 2, 2}, {ModeImpl, cpu.Impl, NOP, cpu.nop, 1, 2}, {ModeImmd, cpu.Immd, SBC, cpu.sbc, 2, 2}, {ModeAbso, cpu.Abso, CPX, cpu.cpx, 3, 4}, {ModeAbso, cpu.Abso, SBC, cpu.sbc, 3, 4}, {ModeAbso, cpu.Abso, INC, cpu.inc, 3, 6}, {ModeAbso, cpu.Abso, ISC, cpu.isc, 3, 6},
+		{ModeRela, cpu.Rela, BEQ, cpu.beq, 2, 2}, {ModeIndY, cpu.IndY, SBC, cpu.sbc, 2, 5}, {ModeImpl, cpu.Impl, KIL, cpu.kil, 1, 2}, {ModeIndY, cpu.IndY, ISC, cpu.isc, 2, 8}, {ModeZpgX, cpu.ZpgX, NOP, cpu.nop, 2, 4}, {ModeZpgX, cpu.ZpgX, SBC, cpu.sbc, 2, 4}, {ModeZpgX, cpu.ZpgX, INC, cpu.inc, 2, 6}, {ModeZpgX, cpu.ZpgX, ISC, cpu.isc, 2, 6}, {ModeImpl, cpu.Impl, SED, cpu.sed, 1, 2}, {ModeAbsY, cpu.AbsY, SBC, cpu.sbc, 3, 4}, {ModeImpl, cpu.Impl, NOP, cpu.nop, 1, 2}, {ModeAbsY, cpu.AbsY, ISC, cpu.isc, 3, 7}, {ModeAbsX, cpu.AbsX, NOP, cpu.nop, 3, 4}, {ModeAbsX, cpu.AbsX, SBC, cpu.sbc, 3, 4}, {ModeAbsX, cpu.AbsX, INC, cpu.inc, 3, 7}, {ModeAbsX, cpu.AbsX, ISC, cpu.isc, 3, 7},
+	}
+}
+
+// Opcode describes an entry of the opcode table, for tools such as disassemblers and debuggers.
+type Opcode struct {
+	Instruction Instruction
+	Mode        AddressMode
+	Size        uint8 // number of bytes, including the opcode
+	Cycles      uint8 // base number of cycles, without page crossing and taken branches
+	Unofficial  bool  // the opcode is not documented by MOS
+}
+
+// Opcode returns the description of the given opcode for the variant of the CPU.
+func (cpu *CPU) Opcode(opcode uint8) Opcode {
+	info := cpu.table[opcode]
+	return Opcode{
+		Instruction: info.inst,
+		Mode:        info.addrMode,
+		Size:        info.instSize,
+		Cycles:      info.instCycles,
+		Unofficial:  cpu.variant != CMOS65C02 && isUnofficial(opcode, info.inst),
+	}
+}
+
+// isUnofficial returns true for the opcodes of the NMOS 6502 that are not documented by MOS.
+func isUnofficial(opcode uint8, inst Instruction) bool {
+	switch {
+	case inst >= ALR && inst <= XAA:
+		return true
+	case inst == NOP:
+		return opcode != 0xEA
+	case inst == SBC:
+		return opcode == 0xEB
+	}
+	return false
+}
+
+// Mnemonic returns the assembler mnemonic of the given opcode.
+// The Rockwell bit instructions of the 65C02 carry the bit number, e.g. RMB3.
+func (cpu *CPU) Mnemonic(opcode uint8) string {
+	inst := cpu.table[opcode].inst
+	switch inst {
+	case RMB, SMB, BBR, BBS:
+		return inst.ToString() + string(rune('0'+opcode>>4&0x07))
+	}
+	return inst.ToString()
+}
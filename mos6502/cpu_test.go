@@ -0,0 +1,417 @@
+package mos6502
+
+import "testing"
+
+// run loads the program at $0200, resets the CPU to it and executes the given number of instructions.
+func run(variant Variant, program []uint8, instructions int) (*CPU, *Memory) {
+	mem := &Memory{}
+	mem.Load(0x0200, program)
+	mem.Load(0xFFFC, []uint8{0x00, 0x02})
+	cpu := NewCPU(mem, variant)
+	cpu.Reset()
+	for i := 0; i < instructions; i++ {
+		cpu.Clock()
+	}
+	return cpu, mem
+}
+
+// busAccess is a bus cycle seen by recordingBus.
+type busAccess struct {
+	addr  uint16
+	data  uint8
+	write bool
+}
+
+// recordingBus is a plain memory that records every bus cycle.
+type recordingBus struct {
+	Memory
+	accesses []busAccess
+}
+
+func (b *recordingBus) Read(addr uint16) uint8 {
+	data := b.Memory.Read(addr)
+	b.accesses = append(b.accesses, busAccess{addr, data, false})
+	return data
+}
+
+func (b *recordingBus) Write(addr uint16, data uint8) {
+	b.Memory.Write(addr, data)
+	b.accesses = append(b.accesses, busAccess{addr, data, true})
+}
+
+func TestDecimalMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		variant Variant
+		program []uint8
+		a       uint8
+		carry   bool
+	}{
+		// SED, CLC, LDA #$15, ADC #$27
+		{"adc 6502", NMOS6502, []uint8{0xF8, 0x18, 0xA9, 0x15, 0x69, 0x27}, 0x42, false},
+		{"adc 2A03", Ricoh2A03, []uint8{0xF8, 0x18, 0xA9, 0x15, 0x69, 0x27}, 0x3C, false},
+		{"adc 65C02", CMOS65C02, []uint8{0xF8, 0x18, 0xA9, 0x15, 0x69, 0x27}, 0x42, false},
+		// SED, CLC, LDA #$58, ADC #$46
+		{"adc carry", NMOS6502, []uint8{0xF8, 0x18, 0xA9, 0x58, 0x69, 0x46}, 0x04, true},
+		// SED, SEC, LDA #$42, SBC #$15
+		{"sbc 6502", NMOS6502, []uint8{0xF8, 0x38, 0xA9, 0x42, 0xE9, 0x15}, 0x27, true},
+		{"sbc 2A03", Ricoh2A03, []uint8{0xF8, 0x38, 0xA9, 0x42, 0xE9, 0x15}, 0x2D, true},
+		// SED, SEC, LDA #$12, SBC #$21
+		{"sbc borrow", CMOS65C02, []uint8{0xF8, 0x38, 0xA9, 0x12, 0xE9, 0x21}, 0x91, false},
+	}
+	for _, tt := range tests {
+		cpu, _ := run(tt.variant, tt.program, 4)
+		if r := cpu.Registers(); r.A != tt.a || (r.P&uint8(C) != 0) != tt.carry {
+			t.Errorf("%s: got A=%02X P=%02X, expected A=%02X carry=%v", tt.name, r.A, r.P, tt.a, tt.carry)
+		}
+	}
+}
+
+func TestDecimalModeOption(t *testing.T) {
+	mem := &Memory{}
+	mem.Load(0x0200, []uint8{0xF8, 0x18, 0xA9, 0x15, 0x69, 0x27})
+	mem.Load(0xFFFC, []uint8{0x00, 0x02})
+	cpu := NewCPU(mem, Ricoh2A03)
+	cpu.SetDecimalMode(true)
+	cpu.Reset()
+	for i := 0; i < 4; i++ {
+		cpu.Clock()
+	}
+	if a := cpu.Registers().A; a != 0x42 {
+		t.Errorf("got A=%02X, expected 42", a)
+	}
+}
+
+func TestCMOSInstructions(t *testing.T) {
+	// LDA #$0F, STA $10, STZ $10, LDX #$33, PHX, PLY, SMB3 $11, BBS3 $11,+2, LDA #$FF, BRA +0, TSB $11
+	program := []uint8{0xA9, 0x0F, 0x85, 0x10, 0x64, 0x10, 0xA2, 0x33, 0xDA, 0x7A, 0xB7, 0x11, 0xBF, 0x11, 0x02, 0xA9, 0xFF, 0x80, 0x00, 0x04, 0x11}
+	cpu, mem := run(CMOS65C02, program, 10)
+
+	r := cpu.Registers()
+	if r.A != 0x0F || r.Y != 0x33 || r.PC != 0x0215 {
+		t.Errorf("got A=%02X Y=%02X PC=%04X, expected A=0F Y=33 PC=0215", r.A, r.Y, r.PC)
+	}
+	if mem[0x10] != 0x00 {
+		t.Errorf("got $10=%02X, expected 00", mem[0x10])
+	}
+	if mem[0x11] != 0x0F {
+		t.Errorf("got $11=%02X, expected 0F", mem[0x11])
+	}
+}
+
+func TestCMOSUnusedOpcodesAreNOPs(t *testing.T) {
+	// an NMOS KIL, LAX and SLO in a row
+	cpu, _ := run(CMOS65C02, []uint8{0x02, 0x00, 0xA3, 0x03}, 3)
+	if cpu.Jammed() || cpu.Registers().PC != 0x0204 {
+		t.Errorf("got PC=%04X jammed=%v, expected PC=0204", cpu.Registers().PC, cpu.Jammed())
+	}
+}
+
+func TestResetAndPowerOn(t *testing.T) {
+	// LDA #$12, LDX #$34, LDY #$56
+	cpu, _ := run(NMOS6502, []uint8{0xA9, 0x12, 0xA2, 0x34, 0xA0, 0x56}, 3)
+	cpu.Reset()
+	if r := cpu.Registers(); r.A != 0x12 || r.X != 0x34 || r.Y != 0x56 || r.SP != 0xFA || r.P&uint8(I) == 0 || r.PC != 0x0200 {
+		t.Errorf("after reset got %+v, expected A, X and Y kept, SP=FA and I set", r)
+	}
+	cpu.PowerOn()
+	if r := cpu.Registers(); r.A != 0 || r.X != 0 || r.Y != 0 || r.SP != 0xFD || r.P != 0x24 {
+		t.Errorf("after power-on got %+v, expected A=X=Y=0, SP=FD and P=24", r)
+	}
+}
+
+func TestStepCycle(t *testing.T) {
+	// LDA #$12, JSR $0210, INC $20 ... $0210: RTS
+	program := []uint8{0xA9, 0x12, 0x20, 0x10, 0x02, 0xE6, 0x20}
+	bus := &recordingBus{}
+	bus.Load(0x0200, program)
+	bus.Load(0x0210, []uint8{0x60})
+	bus.Load(0xFFFC, []uint8{0x00, 0x02})
+	cpu := NewCPU(bus, NMOS6502)
+	cpu.Reset()
+
+	// LDA #, JSR, RTS and INC zp take 2, 6, 6 and 5 cycles
+	for _, cycles := range []int{2, 6, 6, 5} {
+		before := cpu.Registers()
+		start := cpu.Cycles()
+		for n := 1; ; n++ {
+			bus.accesses = bus.accesses[:0]
+			completed := cpu.StepCycle()
+			// the instruction is executed on its first cycle
+			accesses := 0
+			if n == 1 {
+				accesses = cycles
+			}
+			if len(bus.accesses) != accesses {
+				t.Fatalf("instruction at %04X: cycle %d made %d bus accesses, expected %d", before.PC, n, len(bus.accesses), accesses)
+			}
+			if cpu.Cycles() != start+n {
+				t.Errorf("instruction at %04X: got %d cycles after cycle %d, expected %d", before.PC, cpu.Cycles(), n, start+n)
+			}
+			if completed {
+				if n != cycles {
+					t.Errorf("instruction at %04X: completed after %d cycles, expected %d", before.PC, n, cycles)
+				}
+				break
+			}
+			if cpu.Registers() != before {
+				t.Errorf("instruction at %04X: registers changed to %+v during the instruction", before.PC, cpu.Registers())
+			}
+		}
+	}
+	if r := cpu.Registers(); r.A != 0x12 || r.PC != 0x0207 || bus.Memory[0x20] != 0x01 {
+		t.Errorf("got A=%02X PC=%04X $20=%02X, expected A=12 PC=0207 $20=01", r.A, r.PC, bus.Memory[0x20])
+	}
+
+	// an instruction that is started by StepCycle is finished by Clock, without being executed again
+	cpu.SetRegisters(Registers{PC: 0x0205, SP: 0xFD})
+	start := cpu.Cycles()
+	cpu.StepCycle()
+	cpu.Clock()
+	if r := cpu.Registers(); r.PC != 0x0207 || bus.Memory[0x20] != 0x02 || cpu.Cycles() != start+5 {
+		t.Errorf("got PC=%04X $20=%02X and %d cycles after Clock, expected PC=0207 $20=02 and %d cycles", r.PC, bus.Memory[0x20], cpu.Cycles(), start+5)
+	}
+
+	// a reset releases the remaining cycles, the instruction has been executed
+	cpu.SetRegisters(Registers{PC: 0x0205, SP: 0xFD})
+	cpu.StepCycle()
+	cpu.StepCycle()
+	cpu.Reset()
+	if r := cpu.Registers(); r.PC != 0x0200 || bus.Memory[0x20] != 0x03 || cpu.Cycles() != 7 {
+		t.Errorf("got PC=%04X $20=%02X and %d cycles after Reset, expected PC=0200 $20=03 and 7 cycles", r.PC, bus.Memory[0x20], cpu.Cycles())
+	}
+}
+
+func TestInstructionCycles(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []uint8
+		x, y, p uint8
+		cycles  int
+	}{
+		{"LDA #", []uint8{0xA9, 0x01}, 0, 0, 0, 2},
+		{"LDA zp", []uint8{0xA5, 0x10}, 0, 0, 0, 3},
+		{"LDA zp,X", []uint8{0xB5, 0x10}, 1, 0, 0, 4},
+		{"LDA abs", []uint8{0xAD, 0x00, 0x04}, 0, 0, 0, 4},
+		{"LDA abs,X", []uint8{0xBD, 0x00, 0x04}, 0xFF, 0, 0, 4},
+		{"LDA abs,X page cross", []uint8{0xBD, 0x01, 0x04}, 0xFF, 0, 0, 5},
+		{"LDA abs,Y page cross", []uint8{0xB9, 0x01, 0x04}, 0, 0xFF, 0, 5},
+		{"LDA (zp,X)", []uint8{0xA1, 0x10}, 1, 0, 0, 6},
+		{"LDA (zp),Y", []uint8{0xB1, 0x10}, 0, 0x01, 0, 5},
+		{"LDA (zp),Y page cross", []uint8{0xB1, 0x10}, 0, 0xFF, 0, 6},
+		{"STA abs,X", []uint8{0x9D, 0x00, 0x04}, 0, 0, 0, 5},
+		{"STA (zp),Y", []uint8{0x91, 0x10}, 0, 0, 0, 6},
+		{"INC zp", []uint8{0xE6, 0x10}, 0, 0, 0, 5},
+		{"INC abs", []uint8{0xEE, 0x00, 0x04}, 0, 0, 0, 6},
+		{"INC abs,X", []uint8{0xFE, 0x00, 0x04}, 0, 0, 0, 7},
+		{"ASL A", []uint8{0x0A}, 0, 0, 0, 2},
+		{"PHA", []uint8{0x48}, 0, 0, 0, 3},
+		{"PLA", []uint8{0x68}, 0, 0, 0, 4},
+		{"JSR", []uint8{0x20, 0x00, 0x04}, 0, 0, 0, 6},
+		{"RTS", []uint8{0x60}, 0, 0, 0, 6},
+		{"RTI", []uint8{0x40}, 0, 0, 0, 6},
+		{"JMP abs", []uint8{0x4C, 0x00, 0x04}, 0, 0, 0, 3},
+		{"JMP (abs)", []uint8{0x6C, 0x00, 0x04}, 0, 0, 0, 5},
+		{"BRK", []uint8{0x00}, 0, 0, 0, 7},
+		{"BNE not taken", []uint8{0xD0, 0x10}, 0, 0, uint8(Z), 2},
+		{"BNE taken", []uint8{0xD0, 0x10}, 0, 0, 0, 3},
+		{"BNE taken page cross", []uint8{0xD0, 0xF0}, 0, 0, 0, 4},
+	}
+	for _, tt := range tests {
+		mem := &Memory{}
+		mem.Load(0x0300, tt.program)
+		mem.Load(0x10, []uint8{0x01, 0x04, 0x04})
+		cpu := NewCPU(mem, NMOS6502)
+		cpu.SetRegisters(Registers{X: tt.x, Y: tt.y, P: tt.p | uint8(U), SP: 0xFD, PC: 0x0300})
+		start := cpu.Cycles()
+		cpu.Clock()
+		if got := cpu.Cycles() - start; got != tt.cycles {
+			t.Errorf("%s: took %d cycles, expected %d", tt.name, got, tt.cycles)
+		}
+	}
+}
+
+func TestUnofficialOpcodes(t *testing.T) {
+	const u = uint8(U)
+	tests := []struct {
+		name    string
+		program []uint8
+		regs    Registers        // PC is set to $0300, and SP to $FD when 0
+		mem     map[uint16]uint8 // memory before the instruction
+		want    Registers        // PC is expected after the instruction when 0, and SP at $FD when 0
+		wantMem map[uint16]uint8
+		cycles  int
+	}{
+		{"LAX zp", []uint8{0xA7, 0x20}, Registers{P: u}, map[uint16]uint8{0x20: 0x80},
+			Registers{A: 0x80, X: 0x80, P: u | uint8(N)}, nil, 3},
+		{"LAX (zp),Y", []uint8{0xB3, 0x10}, Registers{Y: 0x02, P: u}, map[uint16]uint8{0x10: 0x00, 0x11: 0x04, 0x0402: 0x00},
+			Registers{Y: 0x02, P: u | uint8(Z)}, nil, 5},
+		{"SAX zp", []uint8{0x87, 0x20}, Registers{A: 0xF0, X: 0x3C, P: u}, nil,
+			Registers{A: 0xF0, X: 0x3C, P: u}, map[uint16]uint8{0x20: 0x30}, 3},
+		{"DCP zp", []uint8{0xC7, 0x20}, Registers{A: 0x42, P: u}, map[uint16]uint8{0x20: 0x43},
+			Registers{A: 0x42, P: u | uint8(Z|C)}, map[uint16]uint8{0x20: 0x42}, 5},
+		{"DCP abs,X", []uint8{0xDF, 0x00, 0x04}, Registers{A: 0x10, X: 0x01, P: u}, map[uint16]uint8{0x0401: 0x00},
+			Registers{A: 0x10, X: 0x01, P: u}, map[uint16]uint8{0x0401: 0xFF}, 7},
+		{"ISC zp", []uint8{0xE7, 0x20}, Registers{A: 0x30, P: u | uint8(C)}, map[uint16]uint8{0x20: 0x0F},
+			Registers{A: 0x20, P: u | uint8(C)}, map[uint16]uint8{0x20: 0x10}, 5},
+		{"ISC (zp),Y overflow", []uint8{0xF3, 0x10}, Registers{A: 0x80, Y: 0x02, P: u}, map[uint16]uint8{0x10: 0x00, 0x11: 0x04, 0x0402: 0xFF},
+			Registers{A: 0x7F, Y: 0x02, P: u | uint8(V|C)}, map[uint16]uint8{0x0402: 0x00}, 8},
+		{"SLO zp", []uint8{0x07, 0x20}, Registers{A: 0x40, P: u}, map[uint16]uint8{0x20: 0x81},
+			Registers{A: 0x42, P: u | uint8(C)}, map[uint16]uint8{0x20: 0x02}, 5},
+		{"RLA zp", []uint8{0x27, 0x20}, Registers{A: 0xFF, P: u | uint8(C)}, map[uint16]uint8{0x20: 0x81},
+			Registers{A: 0x03, P: u | uint8(C)}, map[uint16]uint8{0x20: 0x03}, 5},
+		{"SRE zp", []uint8{0x47, 0x20}, Registers{A: 0x81, P: u}, map[uint16]uint8{0x20: 0x03},
+			Registers{A: 0x80, P: u | uint8(N|C)}, map[uint16]uint8{0x20: 0x01}, 5},
+		{"RRA zp", []uint8{0x67, 0x20}, Registers{A: 0x01, P: u | uint8(C)}, map[uint16]uint8{0x20: 0x02},
+			Registers{A: 0x82, P: u | uint8(N)}, map[uint16]uint8{0x20: 0x81}, 5},
+		{"SLO abs,Y", []uint8{0x1B, 0xFF, 0x04}, Registers{Y: 0x01, P: u}, map[uint16]uint8{0x0500: 0x40},
+			Registers{A: 0x80, Y: 0x01, P: u | uint8(N)}, map[uint16]uint8{0x0500: 0x80}, 7},
+		{"ANC #", []uint8{0x0B, 0x80}, Registers{A: 0xC0, P: u}, nil,
+			Registers{A: 0x80, P: u | uint8(N|C)}, nil, 2},
+		{"ALR #", []uint8{0x4B, 0x03}, Registers{A: 0x07, P: u}, nil,
+			Registers{A: 0x01, P: u | uint8(C)}, nil, 2},
+		{"ARR # carry", []uint8{0x6B, 0xFF}, Registers{A: 0xC0, P: u | uint8(C)}, nil,
+			Registers{A: 0xE0, P: u | uint8(N|C)}, nil, 2},
+		{"ARR # overflow", []uint8{0x6B, 0xFF}, Registers{A: 0x40, P: u}, nil,
+			Registers{A: 0x20, P: u | uint8(V)}, nil, 2},
+		{"AXS #", []uint8{0xCB, 0x02}, Registers{A: 0x0F, X: 0x05, P: u}, nil,
+			Registers{A: 0x0F, X: 0x03, P: u | uint8(C)}, nil, 2},
+		{"AXS # borrow", []uint8{0xCB, 0x06}, Registers{A: 0x0F, X: 0x05, P: u}, nil,
+			Registers{A: 0x0F, X: 0xFF, P: u | uint8(N)}, nil, 2},
+		{"LAS abs,Y", []uint8{0xBB, 0x00, 0x04}, Registers{SP: 0xF3, P: u}, map[uint16]uint8{0x0400: 0x7C},
+			Registers{A: 0x70, X: 0x70, SP: 0x70, P: u}, nil, 4},
+		{"LAS abs,Y page cross", []uint8{0xBB, 0xFF, 0x04}, Registers{Y: 0x01, P: u}, map[uint16]uint8{0x0500: 0x80},
+			Registers{A: 0x80, X: 0x80, Y: 0x01, SP: 0x80, P: u | uint8(N)}, nil, 5},
+		{"XAA #", []uint8{0x8B, 0xFF}, Registers{X: 0x0F, P: u}, nil,
+			Registers{A: magicConstant & 0x0F, X: 0x0F, P: u}, nil, 2},
+		{"LXA #", []uint8{0xAB, 0x8F}, Registers{P: u}, nil,
+			Registers{A: magicConstant & 0x8F, X: magicConstant & 0x8F, P: u | uint8(N)}, nil, 2},
+
+		// the stores AND the value with the hi-byte of the address + 1, which replaces the hi-byte on a page cross
+		{"SHA abs,Y", []uint8{0x9F, 0x00, 0x04}, Registers{A: 0xFF, X: 0x0F, Y: 0x01, P: u}, nil,
+			Registers{A: 0xFF, X: 0x0F, Y: 0x01, P: u}, map[uint16]uint8{0x0401: 0x05}, 5},
+		{"SHA (zp),Y", []uint8{0x93, 0x10}, Registers{A: 0xFF, X: 0x0F, Y: 0x01, P: u}, map[uint16]uint8{0x10: 0x00, 0x11: 0x04},
+			Registers{A: 0xFF, X: 0x0F, Y: 0x01, P: u}, map[uint16]uint8{0x0401: 0x05}, 6},
+		{"SHX abs,Y", []uint8{0x9E, 0x00, 0x04}, Registers{X: 0xFF, Y: 0x01, P: u}, nil,
+			Registers{X: 0xFF, Y: 0x01, P: u}, map[uint16]uint8{0x0401: 0x05}, 5},
+		{"SHX abs,Y page cross", []uint8{0x9E, 0xFF, 0x04}, Registers{X: 0x03, Y: 0x02, P: u}, nil,
+			Registers{X: 0x03, Y: 0x02, P: u}, map[uint16]uint8{0x0101: 0x01, 0x0501: 0x00}, 5},
+		{"SHY abs,X", []uint8{0x9C, 0x00, 0x04}, Registers{X: 0x01, Y: 0xFF, P: u}, nil,
+			Registers{X: 0x01, Y: 0xFF, P: u}, map[uint16]uint8{0x0401: 0x05}, 5},
+		{"TAS abs,Y", []uint8{0x9B, 0x00, 0x04}, Registers{A: 0xF7, X: 0xFE, Y: 0x01, P: u}, nil,
+			Registers{A: 0xF7, X: 0xFE, Y: 0x01, SP: 0xF6, P: u}, map[uint16]uint8{0x0401: 0x04}, 5},
+
+		{"NOP #", []uint8{0x80, 0xFF}, Registers{P: u}, nil, Registers{P: u}, nil, 2},
+		{"NOP zp", []uint8{0x04, 0x20}, Registers{P: u}, nil, Registers{P: u}, nil, 3},
+		{"NOP abs,X page cross", []uint8{0x1C, 0xFF, 0x04}, Registers{X: 0x01, P: u}, nil, Registers{X: 0x01, P: u}, nil, 5},
+		{"KIL", []uint8{0x02}, Registers{P: u}, nil, Registers{P: u, PC: 0x0300}, nil, 2},
+	}
+	for _, tt := range tests {
+		mem := &Memory{}
+		mem.Load(0x0300, tt.program)
+		for addr, data := range tt.mem {
+			mem[addr] = data
+		}
+		cpu := NewCPU(mem, NMOS6502)
+		regs := tt.regs
+		regs.PC = 0x0300
+		if regs.SP == 0 {
+			regs.SP = 0xFD
+		}
+		cpu.SetRegisters(regs)
+		cpu.Clock()
+
+		want := tt.want
+		if want.PC == 0 {
+			want.PC = 0x0300 + uint16(len(tt.program))
+		}
+		if want.SP == 0 {
+			want.SP = 0xFD
+		}
+		if got := cpu.Registers(); got != want {
+			t.Errorf("%s: got %+v, expected %+v", tt.name, got, want)
+		}
+		for addr, data := range tt.wantMem {
+			if mem[addr] != data {
+				t.Errorf("%s: got $%04X=$%02X, expected $%02X", tt.name, addr, mem[addr], data)
+			}
+		}
+		if got := cpu.Cycles(); got != tt.cycles {
+			t.Errorf("%s: took %d cycles, expected %d", tt.name, got, tt.cycles)
+		}
+	}
+}
+
+func TestKILJams(t *testing.T) {
+	// KIL, LDA #$01
+	cpu, _ := run(NMOS6502, []uint8{0x02, 0xA9, 0x01}, 1)
+	if !cpu.Jammed() {
+		t.Fatal("expected KIL to jam the CPU")
+	}
+	cycles := cpu.Cycles()
+	for i := 0; i < 10; i++ {
+		cpu.Clock()
+	}
+	if r := cpu.Registers(); r.PC != 0x0200 || r.A != 0x00 || cpu.Cycles() != cycles+10 {
+		t.Errorf("got PC=%04X A=%02X after %d cycles, expected the CPU to stay at the KIL, one cycle per Clock",
+			r.PC, r.A, cpu.Cycles()-cycles)
+	}
+	cpu.Reset()
+	if cpu.Jammed() {
+		t.Error("expected a reset to bring the CPU back")
+	}
+}
+
+func TestDummyAccesses(t *testing.T) {
+	r := func(addr uint16, data uint8) busAccess { return busAccess{addr, data, false} }
+	w := func(addr uint16, data uint8) busAccess { return busAccess{addr, data, true} }
+	tests := []struct {
+		name     string
+		program  []uint8
+		x        uint8
+		accesses []busAccess
+	}{
+		{"LDA abs,X", []uint8{0xBD, 0x00, 0x04}, 0x01, []busAccess{
+			r(0x0300, 0xBD), r(0x0301, 0x00), r(0x0302, 0x04), r(0x0401, 0x11),
+		}},
+		// the hi-byte is fixed up on an extra cycle, which reads from the address in the wrong page
+		{"LDA abs,X page cross", []uint8{0xBD, 0xFF, 0x04}, 0x01, []busAccess{
+			r(0x0300, 0xBD), r(0x0301, 0xFF), r(0x0302, 0x04), r(0x0400, 0x10), r(0x0500, 0x20),
+		}},
+		// stores always take the fix-up cycle
+		{"STA abs,X", []uint8{0x9D, 0x00, 0x04}, 0x01, []busAccess{
+			r(0x0300, 0x9D), r(0x0301, 0x00), r(0x0302, 0x04), r(0x0401, 0x11), w(0x0401, 0x42),
+		}},
+		// read-modify-write instructions write the unmodified value back first
+		{"INC abs", []uint8{0xEE, 0x00, 0x04}, 0x00, []busAccess{
+			r(0x0300, 0xEE), r(0x0301, 0x00), r(0x0302, 0x04), r(0x0400, 0x10), w(0x0400, 0x10), w(0x0400, 0x11),
+		}},
+		// the hi-byte of the target is read last, after the return address is pushed
+		{"JSR", []uint8{0x20, 0x10, 0x04}, 0x00, []busAccess{
+			r(0x0300, 0x20), r(0x0301, 0x10), r(0x01FD, 0x00), w(0x01FD, 0x03), w(0x01FC, 0x02), r(0x0302, 0x04),
+		}},
+		{"ASL A", []uint8{0x0A}, 0x00, []busAccess{
+			r(0x0300, 0x0A), r(0x0301, 0x00),
+		}},
+	}
+	for _, tt := range tests {
+		bus := &recordingBus{}
+		bus.Load(0x0300, tt.program)
+		bus.Load(0x0400, []uint8{0x10, 0x11})
+		bus.Load(0x0500, []uint8{0x20})
+		cpu := NewCPU(bus, NMOS6502)
+		cpu.SetRegisters(Registers{A: 0x42, X: tt.x, SP: 0xFD, P: uint8(U), PC: 0x0300})
+		cpu.Clock()
+
+		if len(bus.accesses) != len(tt.accesses) {
+			t.Errorf("%s: got accesses %v, expected %v", tt.name, bus.accesses, tt.accesses)
+			continue
+		}
+		for i := range tt.accesses {
+			if bus.accesses[i] != tt.accesses[i] {
+				t.Errorf("%s: got accesses %v, expected %v", tt.name, bus.accesses, tt.accesses)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Reference document: https://www.nesdev.org/wiki/CPU_unofficial_opcodes
+// and: https://www.masswerk.at/nowgobang/2021/6502-illegal-opcodes
+
+package mos6502
+
+// magicConstant is the value ORed into the accumulator by the unstable XAA and LXA instructions.
+// It differs between chips, and on some of them with temperature. 0xEE is the most common value.
+const magicConstant = 0xEE
+
+// ALR - AND Memory with Accumulator, then Shift Right One Bit
+//
+//	A AND M -> A, 0 -> [76543210] -> C
+//
+//	N Z C I D V
+//	+ + + - - -
+func (cpu *CPU) alr(mode AddressMode, addr uint16) {
+	cpu.a &= cpu.Read(addr)
+	cpu.SetFlag(C, cpu.a&0x01 == 1)
+	cpu.a >>= 1
+
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// ANC - AND Memory with Accumulator, then Move Negative Flag to Carry
+//
+//	A AND M -> A, N -> C
+//
+//	N Z C I D V
+//	+ + + - - -
+func (cpu *CPU) anc(mode AddressMode, addr uint16) {
+	cpu.a &= cpu.Read(addr)
+
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+	cpu.SetFlag(C, IsNegative(cpu.a))
+}
+
+// ARR - AND Memory with Accumulator, then Rotate Right One Bit
+//
+//	A AND M -> A, C -> [76543210] -> A
+//
+// The carry is taken from bit 6 of the result, and the overflow from bit 6 XOR bit 5.
+//
+//	N Z C I D V
+//	+ + + - - +
+func (cpu *CPU) arr(mode AddressMode, addr uint16) {
+	cpu.a &= cpu.Read(addr)
+	cpu.a = cpu.a>>1 | cpu.GetFlag(C)<<7
+
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+	cpu.SetFlag(C, cpu.a&0x40 != 0)
+	cpu.SetFlag(V, (cpu.a>>6^cpu.a>>5)&0x01 == 1)
+}
+
+// AXS - AND X with Accumulator, then Subtract Memory without Borrow
+//
+//	(A AND X) - M -> X
+//
+//	N Z C I D V
+//	+ + + - - -
+func (cpu *CPU) axs(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	R := cpu.a & cpu.x
+	cpu.compare(R, M)
+	cpu.x = R - M
+}
+
+// DCP - Decrement Memory by One, then Compare with Accumulator
+//
+//	M - 1 -> M, A - M
+//
+//	N Z C I D V
+//	+ + + - - -
+func (cpu *CPU) dcp(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	M--
+	cpu.Write(addr, M)
+
+	cpu.compare(cpu.a, M)
+}
+
+// ISC - Increment Memory by One, then Subtract from Accumulator with Borrow
+//
+//	M + 1 -> M, A - M - C -> A
+//
+//	N Z C I D V
+//	+ + + - - +
+func (cpu *CPU) isc(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	M++
+	cpu.Write(addr, M)
+
+	cpu.subtract(M)
+}
+
+// KIL - Halt the CPU
+//
+// The CPU stops fetching instructions, and only a reset brings it back.
+// PC is left pointing at the KIL instruction.
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) kil(mode AddressMode, addr uint16) {
+	cpu.pc--
+	cpu.jammed = true
+}
+
+// LAS - AND Memory with Stack Pointer, Transfer to Accumulator, X and Stack Pointer
+//
+//	M AND SP -> A, X, SP
+//
+//	N Z C I D V
+//	+ + - - - -
+func (cpu *CPU) las(mode AddressMode, addr uint16) {
+	cpu.sp &= cpu.Read(addr)
+	cpu.a = cpu.sp
+	cpu.x = cpu.sp
+
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// LAX - Load Accumulator and Index X with Memory
+//
+//	M -> A -> X
+//
+//	N Z C I D V
+//	+ + - - - -
+func (cpu *CPU) lax(mode AddressMode, addr uint16) {
+	cpu.a = cpu.Read(addr)
+	cpu.x = cpu.a
+
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// LXA - Load Accumulator and Index X with Immediate (unstable)
+//
+//	(A OR CONST) AND M -> A -> X
+//
+//	N Z C I D V
+//	+ + - - - -
+func (cpu *CPU) lxa(mode AddressMode, addr uint16) {
+	cpu.a = (cpu.a | magicConstant) & cpu.Read(addr)
+	cpu.x = cpu.a
+
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// RLA - Rotate One Bit Left in Memory, then AND with Accumulator
+//
+//	C <- [76543210] <- C, A AND M -> A
+//
+//	N Z C I D V
+//	+ + + - - -
+func (cpu *CPU) rla(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	carry := cpu.GetFlag(C)
+	cpu.SetFlag(C, M>>7 == 1)
+	M = M<<1 | carry
+	cpu.Write(addr, M)
+
+	cpu.a &= M
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// RRA - Rotate One Bit Right in Memory, then Add to Accumulator with Carry
+//
+//	C -> [76543210] -> C, A + M + C -> A, C
+//
+//	N Z C I D V
+//	+ + + - - +
+func (cpu *CPU) rra(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	carry := cpu.GetFlag(C)
+	cpu.SetFlag(C, M&0x01 == 1)
+	M = M>>1 | carry<<7
+	cpu.Write(addr, M)
+
+	cpu.add(M)
+}
+
+// SAX - Store Accumulator AND Index X in Memory
+//
+//	A AND X -> M
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) sax(mode AddressMode, addr uint16) {
+	cpu.Write(addr, cpu.a&cpu.x)
+}
+
+// SHA - Store Accumulator AND Index X AND High Byte + 1 in Memory (unstable)
+//
+//	A AND X AND (H + 1) -> M
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) sha(mode AddressMode, addr uint16) {
+	cpu.storeHighByteAnd(addr, cpu.y, cpu.a&cpu.x)
+}
+
+// SHX - Store Index X AND High Byte + 1 in Memory (unstable)
+//
+//	X AND (H + 1) -> M
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) shx(mode AddressMode, addr uint16) {
+	cpu.storeHighByteAnd(addr, cpu.y, cpu.x)
+}
+
+// SHY - Store Index Y AND High Byte + 1 in Memory (unstable)
+//
+//	Y AND (H + 1) -> M
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) shy(mode AddressMode, addr uint16) {
+	cpu.storeHighByteAnd(addr, cpu.x, cpu.y)
+}
+
+// SLO - Shift Left One Bit in Memory, then OR with Accumulator
+//
+//	C <- [76543210] <- 0, A OR M -> A
+//
+//	N Z C I D V
+//	+ + + - - -
+func (cpu *CPU) slo(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	cpu.SetFlag(C, M>>7 == 1)
+	M <<= 1
+	cpu.Write(addr, M)
+
+	cpu.a |= M
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// SRE - Shift One Bit Right in Memory, then EOR with Accumulator
+//
+//	0 -> [76543210] -> C, A EOR M -> A
+//
+//	N Z C I D V
+//	+ + + - - -
+func (cpu *CPU) sre(mode AddressMode, addr uint16) {
+	M := cpu.Read(addr)
+	cpu.modifyCycle(addr, M)
+	cpu.SetFlag(C, M&0x01 == 1)
+	M >>= 1
+	cpu.Write(addr, M)
+
+	cpu.a ^= M
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// TAS - Transfer Accumulator AND Index X to Stack Pointer, then Store Stack Pointer AND High Byte + 1 in Memory (unstable)
+//
+//	A AND X -> SP, SP AND (H + 1) -> M
+//
+//	N Z C I D V
+//	- - - - - -
+func (cpu *CPU) tas(mode AddressMode, addr uint16) {
+	cpu.sp = cpu.a & cpu.x
+	cpu.storeHighByteAnd(addr, cpu.y, cpu.sp)
+}
+
+// XAA - Transfer Index X to Accumulator, then AND with Memory (unstable)
+//
+//	(A OR CONST) AND X AND M -> A
+//
+//	N Z C I D V
+//	+ + - - - -
+func (cpu *CPU) xaa(mode AddressMode, addr uint16) {
+	cpu.a = (cpu.a | magicConstant) & cpu.x & cpu.Read(addr)
+
+	cpu.SetFlag(N, IsNegative(cpu.a))
+	cpu.SetFlag(Z, IsZero(cpu.a))
+}
+
+// storeHighByteAnd writes value AND (H + 1) for the SHA, SHX, SHY and TAS instructions,
+// H being the hi-byte of the address before it was indexed.
+// When indexing crosses a page, the hi-byte of the address gets replaced by the value written.
+func (cpu *CPU) storeHighByteAnd(addr uint16, index, value uint8) {
+	baseAddr := addr - uint16(index)
+	value &= uint8(baseAddr>>8) + 1
+	if IsCrossed(baseAddr, addr) {
+		addr = uint16(value)<<8 | addr&0x00FF
+	}
+	cpu.Write(addr, value)
+}
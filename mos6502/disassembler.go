@@ -0,0 +1,268 @@
+package mos6502
+
+import "fmt"
+
+// DecodedInstruction is an instruction decoded from memory by the disassembler.
+type DecodedInstruction struct {
+	Addr     uint16
+	Bytes    []uint8
+	Opcode   Opcode
+	Mnemonic string
+
+	// Operand as encoded, i.e. the byte or word following the opcode.
+	// For the zero-page relative mode of the 65C02, this is the zero-page address.
+	Operand uint16
+
+	// Branch target of the relative modes
+	Target uint16
+}
+
+// Syntax selects the details of the assembler syntax used to format instructions.
+type Syntax struct {
+	// Label names an address, or returns "" to leave it as a number
+	Label func(addr uint16) string
+
+	// ForceAbsolute marks absolute operands that fit in the zero page with "a:",
+	// as ca65 would otherwise assemble them with zero-page addressing
+	ForceAbsolute bool
+}
+
+// ToString formats the instruction in standard syntax, e.g. "LDA ($12),Y".
+func (i DecodedInstruction) ToString() string {
+	return i.Format(Syntax{})
+}
+
+// Format formats the instruction in standard syntax, with the given labels for the addresses.
+func (i DecodedInstruction) Format(syntax Syntax) string {
+	zeroPage := func(addr uint16) string {
+		if syntax.Label != nil {
+			if label := syntax.Label(addr); label != "" {
+				return label
+			}
+		}
+		return fmt.Sprintf("$%02X", addr)
+	}
+	absolute := func(addr uint16) string {
+		if syntax.Label != nil {
+			if label := syntax.Label(addr); label != "" {
+				return label
+			}
+		}
+		if syntax.ForceAbsolute && addr <= 0x00FF {
+			return fmt.Sprintf("a:$%04X", addr)
+		}
+		return fmt.Sprintf("$%04X", addr)
+	}
+	target := func(addr uint16) string {
+		if syntax.Label != nil {
+			if label := syntax.Label(addr); label != "" {
+				return label
+			}
+		}
+		return fmt.Sprintf("$%04X", addr)
+	}
+
+	var operand string
+	switch i.Opcode.Mode {
+	case ModeAccu:
+		operand = "A"
+	case ModeImmd:
+		operand = fmt.Sprintf("#$%02X", i.Operand)
+	case ModeZpag:
+		operand = zeroPage(i.Operand)
+	case ModeZpgX:
+		operand = zeroPage(i.Operand) + ",X"
+	case ModeZpgY:
+		operand = zeroPage(i.Operand) + ",Y"
+	case ModeAbso:
+		operand = absolute(i.Operand)
+	case ModeAbsX:
+		operand = absolute(i.Operand) + ",X"
+	case ModeAbsY:
+		operand = absolute(i.Operand) + ",Y"
+	case ModeIndi:
+		operand = "(" + absolute(i.Operand) + ")"
+	case ModeXInd:
+		operand = "(" + zeroPage(i.Operand) + ",X)"
+	case ModeIndY:
+		operand = "(" + zeroPage(i.Operand) + "),Y"
+	case ModeRela:
+		operand = target(i.Target)
+	case ModeZInd:
+		operand = "(" + zeroPage(i.Operand) + ")"
+	case ModeAInX:
+		operand = "(" + absolute(i.Operand) + ",X)"
+	case ModeZRel:
+		operand = zeroPage(i.Operand) + "," + target(i.Target)
+	}
+
+	if operand == "" {
+		return i.Mnemonic
+	}
+	return i.Mnemonic + " " + operand
+}
+
+// References returns the address the instruction refers to, other than through an index or a pointer,
+// e.g. the target of a jump or the address loaded from.
+func (i DecodedInstruction) References() (uint16, bool) {
+	switch i.Opcode.Mode {
+	case ModeZpag, ModeZpgX, ModeZpgY, ModeAbso, ModeAbsX, ModeAbsY, ModeIndi, ModeXInd, ModeIndY, ModeZInd, ModeAInX:
+		return i.Operand, true
+	case ModeRela, ModeZRel:
+		return i.Target, true
+	}
+	return 0, false
+}
+
+// endsFlow returns true if execution does not continue with the next instruction.
+func (i DecodedInstruction) endsFlow() bool {
+	switch i.Opcode.Instruction {
+	case JMP, RTS, RTI, BRK, KIL, STP, BRA:
+		return true
+	}
+	return false
+}
+
+// jumpTarget returns the address execution may continue at, other than the next instruction.
+func (i DecodedInstruction) jumpTarget() (uint16, bool) {
+	switch {
+	case i.Opcode.Instruction.IsBranch():
+		return i.Target, true
+	case (i.Opcode.Instruction == JMP || i.Opcode.Instruction == JSR) && i.Opcode.Mode == ModeAbso:
+		return i.Operand, true
+	}
+	return 0, false
+}
+
+// Disassembler decodes machine code from memory.
+// It follows the flow of the code from the given entry points, so that code can be told apart from data.
+type Disassembler struct {
+	cpu  *CPU // only used for its opcode table
+	read func(addr uint16) uint8
+
+	// Range of memory that is disassembled (inclusive)
+	start, end uint16
+
+	code       [0x10000]bool // an instruction starts at the address
+	covered    [0x10000]bool // the address is part of an instruction
+	referenced [0x10000]bool // an instruction refers to the address
+}
+
+// NewDisassembler creates a disassembler for the given variant, reading the memory between start and end (inclusive).
+func NewDisassembler(variant Variant, read func(addr uint16) uint8, start, end uint16) *Disassembler {
+	return &Disassembler{
+		cpu:   NewCPU(nil, variant),
+		read:  read,
+		start: start,
+		end:   end,
+	}
+}
+
+// Decode decodes the instruction at the given address, whether or not it is code.
+func (d *Disassembler) Decode(addr uint16) DecodedInstruction {
+	opcode := d.read(addr)
+	info := d.cpu.Opcode(opcode)
+
+	i := DecodedInstruction{
+		Addr:     addr,
+		Bytes:    make([]uint8, info.Size),
+		Opcode:   info,
+		Mnemonic: d.cpu.Mnemonic(opcode),
+	}
+	for n := range i.Bytes {
+		i.Bytes[n] = d.read(addr + uint16(n))
+	}
+	switch info.Size {
+	case 2:
+		i.Operand = uint16(i.Bytes[1])
+	case 3:
+		i.Operand = uint16(i.Bytes[2])<<8 | uint16(i.Bytes[1])
+	}
+
+	switch info.Mode {
+	case ModeRela:
+		i.Target = addr + 2 + uint16(int8(i.Bytes[1]))
+	case ModeZRel:
+		i.Operand = uint16(i.Bytes[1])
+		i.Target = addr + 3 + uint16(int8(i.Bytes[2]))
+	}
+	return i
+}
+
+func (d *Disassembler) inRange(addr uint16) bool {
+	return addr >= d.start && addr <= d.end
+}
+
+// Trace follows the code from the given entry points, through branches, jumps and subroutine calls.
+// Indirect jumps can not be followed, their targets need to be given as entry points.
+func (d *Disassembler) Trace(entries ...uint16) {
+	pending := append([]uint16{}, entries...)
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for d.inRange(addr) && !d.code[addr] {
+			i := d.Decode(addr)
+			last := addr + uint16(len(i.Bytes)) - 1
+			if last < addr || !d.inRange(last) || d.isCovered(addr, last) {
+				// the instruction does not fit, or overlaps another one
+				break
+			}
+			d.code[addr] = true
+			for a := addr; ; a++ {
+				d.covered[a] = true
+				if a == last {
+					break
+				}
+			}
+
+			if ref, ok := i.References(); ok {
+				d.referenced[ref] = true
+			}
+			if target, ok := i.jumpTarget(); ok {
+				pending = append(pending, target)
+			}
+			if i.endsFlow() {
+				break
+			}
+			addr = last + 1
+		}
+	}
+}
+
+func (d *Disassembler) isCovered(first, last uint16) bool {
+	for a := first; ; a++ {
+		if d.covered[a] {
+			return true
+		}
+		if a == last {
+			return false
+		}
+	}
+}
+
+// IsCode returns true if an instruction found by Trace starts at the given address.
+func (d *Disassembler) IsCode(addr uint16) bool {
+	return d.code[addr]
+}
+
+// IsCovered returns true if the given address is part of an instruction found by Trace.
+func (d *Disassembler) IsCovered(addr uint16) bool {
+	return d.covered[addr]
+}
+
+// IsReferenced returns true if an instruction found by Trace refers to the given address.
+func (d *Disassembler) IsReferenced(addr uint16) bool {
+	return d.referenced[addr]
+}
+
+// Instructions returns the instructions found by Trace, in the order of their addresses.
+func (d *Disassembler) Instructions() []DecodedInstruction {
+	var instructions []DecodedInstruction
+	for addr := int(d.start); addr <= int(d.end); addr++ {
+		if d.code[addr] {
+			instructions = append(instructions, d.Decode(uint16(addr)))
+		}
+	}
+	return instructions
+}
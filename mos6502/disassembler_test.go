@@ -0,0 +1,74 @@
+package mos6502
+
+import "testing"
+
+func TestDisassemblerFormat(t *testing.T) {
+	memory := &Memory{}
+	memory.Load(0x8000, []uint8{
+		0xB1, 0x12, // LDA ($12),Y
+		0xAD, 0x34, 0x00, // LDA $0034
+		0xF0, 0xFC, // BEQ $8003
+		0x6C, 0xFF, 0x10, // JMP ($10FF)
+	})
+	d := NewDisassembler(NMOS6502, memory.Read, 0x8000, 0xFFFF)
+
+	labels := Syntax{
+		Label: func(addr uint16) string {
+			if addr == 0x8003 {
+				return "loop"
+			}
+			return ""
+		},
+		ForceAbsolute: true,
+	}
+	for _, test := range []struct {
+		addr     uint16
+		standard string
+		ca65     string
+	}{
+		{0x8000, "LDA ($12),Y", "LDA ($12),Y"},
+		{0x8002, "LDA $0034", "LDA a:$0034"},
+		{0x8005, "BEQ $8003", "BEQ loop"},
+		{0x8007, "JMP ($10FF)", "JMP ($10FF)"},
+	} {
+		i := d.Decode(test.addr)
+		if i.ToString() != test.standard {
+			t.Errorf("0x%04X: got %q, expected %q", test.addr, i.ToString(), test.standard)
+		}
+		if i.Format(labels) != test.ca65 {
+			t.Errorf("0x%04X: got %q, expected %q", test.addr, i.Format(labels), test.ca65)
+		}
+	}
+}
+
+func TestDisassemblerTrace(t *testing.T) {
+	memory := &Memory{}
+	memory.Load(0x8000, []uint8{
+		0xA9, 0x01, // 8000: LDA #$01
+		0xD0, 0x03, // 8002: BNE $8007
+		0xAD, 0x0B, 0x80, // 8004: LDA $800B
+		0x20, 0x0C, 0x80, // 8007: JSR $800C
+		0x00, // 800A: BRK
+		0xFF, // 800B: data
+		0x60, // 800C: RTS
+		0xAD, // 800D: data
+	})
+	d := NewDisassembler(NMOS6502, memory.Read, 0x8000, 0x800D)
+	d.Trace(0x8000)
+
+	code := map[uint16]bool{0x8000: true, 0x8002: true, 0x8004: true, 0x8007: true, 0x800A: true, 0x800C: true}
+	for addr := uint16(0x8000); addr <= 0x800D; addr++ {
+		if d.IsCode(addr) != code[addr] {
+			t.Errorf("0x%04X: got code %v, expected %v", addr, d.IsCode(addr), code[addr])
+		}
+	}
+	if d.IsCovered(0x800B) || d.IsCovered(0x800D) || !d.IsCovered(0x8001) {
+		t.Errorf("data was covered by instructions")
+	}
+	if !d.IsReferenced(0x800B) || !d.IsReferenced(0x8007) || d.IsReferenced(0x8002) {
+		t.Errorf("unexpected references")
+	}
+	if n := len(d.Instructions()); n != len(code) {
+		t.Errorf("got %d instructions, expected %d", n, len(code))
+	}
+}
@@ -0,0 +1,17 @@
+package mos6502
+
+// Memory is a flat 64 KiB of RAM, the simplest bus to run the CPU against.
+type Memory [0x10000]uint8
+
+func (m *Memory) Read(addr uint16) uint8 {
+	return m[addr]
+}
+
+func (m *Memory) Write(addr uint16, data uint8) {
+	m[addr] = data
+}
+
+// Load copies the data into memory, starting at the given address.
+func (m *Memory) Load(addr uint16, data []uint8) {
+	copy(m[addr:], data)
+}
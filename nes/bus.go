@@ -1,46 +1,127 @@
 package nes
 
+import "go-nes/mos6502"
+
 type Bus struct {
 	// Devices on the bus
-	CPU        *CPU
-	PPU        *PPU
-	CpuRam     [2048]byte
-	Cartridge  *Cartridge
-	Controller uint8
+	CPU       *mos6502.CPU
+	PPU       *PPU
+	CpuRam    [2048]byte
+	Cartridge *Cartridge
+	Ports     [2]InputDevice // read through $4016 and $4017
+
+	// Controller states set by the host, see: VM.SetControllerState
+	input       [4]playerInput // by player
+	inputFilter InputFilter
+	movie       *movieSession // movie being recorded or played back, if any
 
 	// Internal
 	clockCounter uint64 // CPU only
 
-	// Internal controller snapshot
-	controllerState uint8
+	// Timing of the current region, the CPU and PPU are driven by dividing the master clock
+	region      Region
+	timing      regionTiming
+	masterClock uint64
+	ppuClock    uint64 // master clock the PPU has been run up to
+
+	// Checks the CPU accesses against the watchpoints, if set
+	debugger *Debugger
+
+	// Last value on the CPU data bus, which undriven bits read back as
+	openBus uint8
+
+	// Content of the memories at power-on, see: PowerCycle
+	powerOnState PowerOnState
 }
 
 func NewBus() *Bus {
 	bus := &Bus{}
-	bus.CPU = NewCPU(bus)
-	bus.CPU.Reset()
-	bus.CpuRam = [2048]byte{}
 	bus.PPU = NewPPU()
+	bus.Ports = [2]InputDevice{NewController(), NewController()}
+	bus.SetRegion(NTSC)
+	bus.CPU = mos6502.NewCPU(bus, mos6502.Ricoh2A03)
+	bus.powerOnState = DefaultPowerOnState()
+	bus.PowerCycle()
 	return bus
 }
 
+func (b *Bus) SetRegion(region Region) {
+	b.region = region
+	b.timing = region.timing()
+	b.PPU.SetRegion(region)
+}
+
+// Reset presses the reset button, which keeps the memories and applies the reset effects of the CPU and PPU.
 func (b *Bus) Reset() {
+	b.PPU.reset()
 	b.CPU.Reset()
 	b.clockCounter = 0
 }
 
+// Clock runs the CPU for one instruction.
+// The rest of the system is kept in step with the CPU on every bus cycle, see: startCpuCycle and endCpuCycle.
 func (b *Bus) Clock() {
-	b.PPU.Clock()
-	b.PPU.Clock()
-	b.PPU.Clock()
 	b.CPU.Clock()
+	b.clockCounter++
+}
+
+// startCpuCycle advances the master clock up to the point in a CPU cycle where the bus is accessed.
+// Reads happen a little earlier in the cycle than writes.
+func (b *Bus) startCpuCycle(read bool) {
+	b.masterClock += uint64(b.cpuCycleSplit(read))
+	b.runPPU()
+}
+
+// endCpuCycle advances the master clock to the end of a CPU cycle, and updates the CPU's NMI line.
+func (b *Bus) endCpuCycle(read bool) {
+	b.masterClock += uint64(b.timing.cpuDivider - b.cpuCycleSplit(read))
+	b.runPPU()
+	b.CPU.SetNMI(b.PPU.nmi)
+}
 
-	if b.PPU.nmi {
-		b.PPU.nmi = false
-		b.CPU.nmi()
+// cpuCycleSplit returns the number of master clocks in a CPU cycle before the bus is accessed.
+func (b *Bus) cpuCycleSplit(read bool) int {
+	if read {
+		return b.timing.cpuDivider/2 - 1
 	}
+	return b.timing.cpuDivider/2 + 1
+}
 
-	b.clockCounter++
+// runPPU catches the PPU up with the master clock, 3 dots per CPU cycle on NTSC and 3.2 dots on PAL.
+func (b *Bus) runPPU() {
+	for b.ppuClock+uint64(b.timing.ppuDivider) <= b.masterClock {
+		frame := b.PPU.frameCount
+		b.PPU.Clock()
+		if b.PPU.frameCount != frame {
+			b.inputFrame()
+		}
+		b.ppuClock += uint64(b.timing.ppuDivider)
+	}
+}
+
+// Read is the CPU's access to the bus, taking one CPU cycle.
+func (b *Bus) Read(addr uint16) uint8 {
+	b.startCpuCycle(true)
+	vramAddr := b.PPU.vramAddr
+	data := b.CpuRead(addr)
+	b.openBus = data
+	if b.debugger != nil {
+		b.debugger.access(addr, vramAddr, AccessRead, data)
+	}
+	b.endCpuCycle(true)
+	return data
+}
+
+// Write is the CPU's access to the bus, taking one CPU cycle.
+func (b *Bus) Write(addr uint16, data uint8) {
+	b.startCpuCycle(false)
+	vramAddr := b.PPU.vramAddr
+	b.CpuWrite(addr, data)
+	b.openBus = data
+	if b.debugger != nil {
+		b.debugger.access(addr, vramAddr, AccessWrite, data)
+	}
+	b.endCpuCycle(false)
 }
 
 func (b *Bus) CpuRead(addr uint16) uint8 {
@@ -55,15 +136,29 @@ func (b *Bus) CpuRead(addr uint16) uint8 {
 		} else if addr >= 0x2000 && addr <= 0x3FFF {
 			data = b.PPU.CpuRead(addr & 0x0007)
 		} else if addr >= 0x4016 && addr <= 0x4017 {
-			if b.controllerState&0x80 > 0 {
-				data = 1
-			}
-			b.controllerState <<= 1
+			// the devices drive bits 0-4 at most, the top bits are left over from the last bus access, usually $40
+			data = b.readPort(int(addr - 0x4016))
 		}
 	}
 	return data
 }
 
+// Peek reads from the given address like the CPU would, without the side effects of reading I/O registers.
+// The PPU registers read as the PPU's open bus, and the controllers as 0.
+func (b *Bus) Peek(addr uint16) uint8 {
+	if b.Cartridge != nil {
+		if data, ok := b.Cartridge.CpuRead(addr); ok {
+			return data
+		}
+	}
+	if addr <= 0x1FFF {
+		return b.CpuRam[addr&0x07FF]
+	} else if addr >= 0x2000 && addr <= 0x3FFF {
+		return b.PPU.openBus
+	}
+	return 0
+}
+
 func (b *Bus) CpuWrite(addr uint16, data uint8) {
 	ok := false
 	if b.Cartridge != nil {
@@ -76,15 +171,10 @@ func (b *Bus) CpuWrite(addr uint16, data uint8) {
 			b.PPU.CpuWrite(addr&0x0007, data)
 
 		} else if addr == 0x4014 {
-			for d := uint8(0x00); ; d++ {
-				targetAddr := uint16(data)<<8 | uint16(d)
-				b.PPU.PpuOamWrite(d, b.CpuRead(targetAddr))
-				if d == 0xFF {
-					break
-				}
-			}
-		} else if addr >= 0x4016 && addr <= 0x4017 {
-			b.controllerState = b.Controller
+			b.CPU.Halt(&oamDMA{page: data})
+		} else if addr == 0x4016 {
+			// the strobe goes to both ports, $4017 is the APU frame counter when written
+			b.strobePorts(data)
 		}
 	}
 }
@@ -93,3 +183,37 @@ func (b *Bus) InsertCartridge(cartridge *Cartridge) {
 	b.Cartridge = cartridge
 	b.PPU.ConnectCartridge(cartridge)
 }
+
+// oamDMA copies a page of CPU memory to OAM through $2004, with one read and one write every 2 cycles.
+// It takes 513 or 514 cycles: the CPU is halted for 1 cycle, plus 1 to align the reads on odd cycles,
+// see: https://www.nesdev.org/wiki/DMA#OAM_DMA
+type oamDMA struct {
+	page   uint8
+	halted bool  // the cycle halting the CPU has passed
+	index  int   // next byte of the page to copy
+	read   bool  // the byte has been read, and is written on the next cycle
+	data   uint8 // byte being copied
+}
+
+func (d *oamDMA) Cycle(bus mos6502.Bus, cycle int) bool {
+	if !d.halted {
+		d.halted = true
+		return false
+	}
+	if d.read {
+		bus.Write(0x2004, d.data)
+		d.read = false
+		d.index++
+		return true
+	}
+	if cycle%2 == 0 {
+		return false
+	}
+	d.data = bus.Read(uint16(d.page)<<8 | uint16(d.index))
+	d.read = true
+	return true
+}
+
+func (d *oamDMA) Done() bool {
+	return d.index == 0x100
+}
@@ -0,0 +1,134 @@
+package nes
+
+import "testing"
+
+// dots returns the position of the PPU in the frame, in dots.
+func (p *PPU) dots() int {
+	return p.scanline*341 + p.cycle
+}
+
+func TestCPUPPUInterleaving(t *testing.T) {
+	vm := NewVM()
+	// NOP, LDA $10, LDA ($10,X), JSR sub, ... sub: RTS
+	err := vm.LoadProgramSource(`
+		.org $0300
+		NOP
+		LDA $10
+		LDA ($10,X)
+		JSR sub
+		NOP
+sub:	RTS
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cycles := range []int{2, 3, 6, 6, 6} {
+		cycle, dots := vm.PeekCPU().Cycle, vm.bus.PPU.dots()
+		vm.Step()
+		if got := vm.PeekCPU().Cycle - cycle; got != cycles {
+			t.Errorf("got %d cycles, expected %d", got, cycles)
+		}
+		if got := vm.bus.PPU.dots() - dots; got != 3*cycles {
+			t.Errorf("the PPU advanced %d dots in %d cycles, expected %d", got, cycles, 3*cycles)
+		}
+	}
+}
+
+func TestOAMDMA(t *testing.T) {
+	// the DMA takes 513 cycles, or 514 when it starts on an odd cycle
+	lengths := make(map[int]bool)
+	for _, test := range []struct {
+		align string
+		sta   uint16 // address of the STA $4014
+	}{{"", 0x0307}, {"LDA $00", 0x0309}} {
+		align := test.align
+		vm := NewVM()
+		for i := range vm.bus.CpuRam[0x0200:0x0300] {
+			vm.bus.CpuRam[0x0200+i] = uint8(i)
+		}
+		err := vm.LoadProgramSource(`
+			.org $0300
+			LDA #$10
+			STA $2003
+			` + align + `
+			LDA #$02
+			STA $4014
+		`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for vm.PeekCPU().PC != test.sta {
+			vm.Step()
+		}
+		cycle := vm.PeekCPU().Cycle
+		vm.Step()
+		expected := 4 + 513 + (cycle+4)%2
+		if got := vm.PeekCPU().Cycle - cycle; got != expected {
+			t.Errorf("%q: STA $4014 took %d cycles, expected %d", align, got, expected)
+		}
+		lengths[expected] = true
+		// the bytes are written through $2004, starting at OAMADDR
+		for i := 0; i < 0x100; i++ {
+			if got := vm.bus.PPU.ppuOam[(i+0x10)&0xFF]; got != uint8(i) {
+				t.Fatalf("%q: got OAM[$%02X]=$%02X, expected $%02X", align, (i+0x10)&0xFF, got, i)
+			}
+		}
+	}
+
+	if len(lengths) != 2 {
+		t.Errorf("got DMAs of %v cycles, expected to test both alignments", lengths)
+	}
+
+	// the DMA reads go through the bus, so copying page $40 reads the controllers once
+	vm := NewVM()
+	vm.SetControllerState(0, ButtonB)
+	err := vm.LoadProgramSource(`
+		.org $0300
+		LDA #1
+		STA $4016
+		LDA #0
+		STA $4016
+		LDA #$40
+		STA $4014
+		LDA $4016
+		STA $20
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		vm.Step()
+	}
+	if got := vm.PeekRAM(0x20, 0x20)[0] & 0x01; got != 1 {
+		t.Errorf("got %d from $4016 after the DMA, expected the B button to be read as the DMA read A", got)
+	}
+}
+
+func TestRMWDoubleWrite(t *testing.T) {
+	vm := NewVM()
+	vm.bus.PPU.tableName[0][0x01] = 0x55
+	vm.bus.PPU.tableName[0][0x02] = 0x55
+	err := vm.LoadProgramSource(`
+		.org $0300
+		LDA #$20
+		STA $2006
+		LDA #$00
+		STA $2006
+		INC $2007
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		vm.Step()
+	}
+	// the read advances the address once, then each of the two writes advances it again:
+	// the unmodified value read from the buffer goes to $2001, and the incremented one to $2002
+	p := vm.bus.PPU
+	if p.vramAddr != 0x2003 {
+		t.Errorf("got the VRAM address at $%04X, expected $2003", p.vramAddr)
+	}
+	if p.tableName[0][0x01] != 0x00 || p.tableName[0][0x02] != 0x01 {
+		t.Errorf("got $%02X at $2001 and $%02X at $2002, expected $00 and $01", p.tableName[0][0x01], p.tableName[0][0x02])
+	}
+}
@@ -5,6 +5,8 @@ package nes
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type Cartridge struct {
@@ -16,6 +18,7 @@ type Cartridge struct {
 	prgRomBanks uint8
 	chrRomBanks uint8
 	mirrorMode  MirrorMode
+	region      Region
 
 	mapper Mapper
 }
@@ -47,7 +50,7 @@ func NewCartridge(filePath string) *Cartridge {
 		mapper         uint8    // Flags 6 - Mapper, mirroring, battery, trainer
 		mapper2        uint8    // Flags 7 - Mapper, VS/Playchoice, NES 2.0
 		prgRamSize     uint8    // Flags 8 - PRG-RAM size (rarely used extension)
-		tvSystem       uint8    // Flags 9 - TV system (rarely used extension)
+		tvSystem       uint8    // Flags 9 - TV system (rarely used extension), high bits of the ROM sizes in NES 2.0
 		tvSystemPrgRam uint8    // Flags 10 - TV system, PRG-RAM presence (unofficial, rarely used extension)
 		unused         [5]uint8 // Unused padding (should be filled with zero, but some rippers put their name across bytes 7-15)
 	}
@@ -63,19 +66,27 @@ func NewCartridge(filePath string) *Cartridge {
 		unused:         [5]uint8{headerData[11], headerData[12], headerData[13], headerData[14], headerData[15]},
 	}
 
+	// Parse the variant of the iNES file
+	cartridge.variant = parseVariant(headerData)
+
+	// Archaic headers may hold garbage from byte 7 on, such as the name of the ripper
+	if cartridge.variant == Archaic {
+		h.mapper2 = 0
+	}
+
 	// Parse the mapperId ID
 	cartridge.mapperId = (h.mapper2>>4)<<4 | h.mapper>>4
 
 	// Parse the mirroring mode
 	cartridge.mirrorMode = parseMirrorMode(h.mapper)
 
-	// Parse the variant of the iNES file
-	cartridge.variant = parseVariant(headerData)
-
 	switch cartridge.variant {
-	case Archaic, NES2:
-		panic("Variant not supported yet!")
-	case iNES:
+	case Archaic, iNES, NES2:
+		// NES 2.0 uses byte 9 for the high bits of the ROM sizes, only ROMs of up to 255 banks are supported,
+		// see: https://www.nesdev.org/wiki/NES_2.0#PRG-ROM_Area
+		if cartridge.variant == NES2 && h.tvSystem != 0 {
+			panic(fmt.Sprintf("NES 2.0 ROM sizes above 255 banks are not supported (byte 9 is $%02X)", h.tvSystem))
+		}
 		cartridge.prgRomBanks = h.prgRomSize
 		cartridge.chrRomBanks = h.chrRomSize
 
@@ -103,6 +114,8 @@ func NewCartridge(filePath string) *Cartridge {
 			panic(fmt.Sprintf("Expected CHR ROM size (%v) not equal to number of bytes read (%v)", chrRomSize, n))
 		}
 
+		cartridge.region = detectRegion(cartridge.variant, headerData, filePath)
+
 		// Load mapperId
 		switch cartridge.mapperId {
 		case 0:
@@ -115,6 +128,26 @@ func NewCartridge(filePath string) *Cartridge {
 	return cartridge
 }
 
+// prgBankAddress returns the CPU address at which the given 16 KiB PRG ROM bank is mapped.
+// When the bank is mirrored, the highest address is returned, where the interrupt vectors are.
+func (c *Cartridge) prgBankAddress(bank int) (uint16, bool) {
+	for _, addr := range []uint16{0xC000, 0x8000} {
+		if mapped, ok := c.mapper.CpuMapRead(addr); ok && int(mapped) == bank*0x4000 {
+			return addr, true
+		}
+	}
+	return 0, false
+}
+
+// prgRomOffset returns the offset into the PRG ROM of the given CPU address, as currently mapped.
+func (c *Cartridge) prgRomOffset(addr uint16) (int, bool) {
+	mapped, ok := c.mapper.CpuMapRead(addr)
+	if !ok || int(mapped) >= len(c.prgRomData) {
+		return 0, false
+	}
+	return int(mapped), true
+}
+
 type iNESVariant uint8
 
 const (
@@ -123,8 +156,65 @@ const (
 	NES2
 )
 
+// parseVariant recognises the format of the header, see: https://www.nesdev.org/wiki/NES_2.0#Identification
 func parseVariant(data []byte) iNESVariant {
-	return iNES
+	if data[7]&0x0C == 0x08 {
+		return NES2
+	}
+	if data[7]&0x0C == 0x00 && data[12] == 0 && data[13] == 0 && data[14] == 0 && data[15] == 0 {
+		return iNES
+	}
+	return Archaic
+}
+
+// detectRegion works out which region a ROM was made for.
+// In order of preference: the NES 2.0 timing field, the region tag in the file name,
+// and finally the (rarely set) TV system flag of iNES.
+func detectRegion(variant iNESVariant, header []byte, filePath string) Region {
+	if variant == NES2 {
+		switch header[12] & 0x03 {
+		case 0, 2: // NTSC, or multi-region
+			return NTSC
+		case 1:
+			return PAL
+		case 3:
+			return Dendy
+		}
+	}
+	if region, ok := regionFromFileName(filepath.Base(filePath)); ok {
+		return region
+	}
+	if variant == iNES && header[9]&0x01 == 1 {
+		return PAL
+	}
+	return NTSC
+}
+
+// regionFromFileName recognises the region tags of the No-Intro and GoodNES naming conventions,
+// e.g. "Super Mario Bros. (Europe).nes" or "Tetris (E) [!].nes".
+func regionFromFileName(name string) (Region, bool) {
+	tags := []struct {
+		tag    string
+		region Region
+	}{
+		{"(Europe)", PAL},
+		{"(Australia)", PAL},
+		{"(E)", PAL},
+		{"(A)", PAL},
+		{"(PAL)", PAL},
+		{"(Russia)", Dendy},
+		{"(R)", Dendy},
+		{"(USA)", NTSC},
+		{"(Japan)", NTSC},
+		{"(U)", NTSC},
+		{"(J)", NTSC},
+	}
+	for _, t := range tags {
+		if strings.Contains(name, t.tag) {
+			return t.region, true
+		}
+	}
+	return NTSC, false
 }
 
 type MirrorMode uint8
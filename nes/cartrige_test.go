@@ -0,0 +1,57 @@
+package nes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestROMHeader writes the test ROM with the given header bytes changed, and returns its path.
+func writeTestROMHeader(t *testing.T, header map[int]uint8) string {
+	rom, err := os.ReadFile(writeTestROM(t, []uint8{0x4C, 0x00, 0xC0}, 0xC000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range header {
+		rom[i] = b
+	}
+	path := filepath.Join(t.TempDir(), "header.nes")
+	if err := os.WriteFile(path, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestArchaicHeader(t *testing.T) {
+	// "DiskDude!" from byte 7 on, the mapper number is read from byte 6 only
+	header := map[int]uint8{}
+	for i, c := range "DiskDude!" {
+		header[7+i] = uint8(c)
+	}
+	cartridge := NewCartridge(writeTestROMHeader(t, header))
+	if cartridge.variant != Archaic {
+		t.Errorf("got variant %d, expected Archaic", cartridge.variant)
+	}
+	if cartridge.mapperId != 0 {
+		t.Errorf("got mapper %d, expected 0", cartridge.mapperId)
+	}
+}
+
+func TestNES2ROMSize(t *testing.T) {
+	// the high bits of the PRG and CHR ROM sizes
+	for _, size := range []uint8{0x01, 0x10} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("byte 9 $%02X: expected the ROM to be rejected", size)
+				}
+			}()
+			NewCartridge(writeTestROMHeader(t, map[int]uint8{7: 0x08, 9: size}))
+		}()
+	}
+
+	// the TV system flag of iNES is not a size
+	if cartridge := NewCartridge(writeTestROMHeader(t, map[int]uint8{9: 0x01})); cartridge.prgRomBanks != 1 {
+		t.Errorf("got %d PRG ROM banks, expected 1", cartridge.prgRomBanks)
+	}
+}
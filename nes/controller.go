@@ -0,0 +1,56 @@
+// Controller Reference: https://www.nesdev.org/wiki/Standard_controller
+
+package nes
+
+// Buttons of a standard controller, as bits of its state. The buttons are read in order from ButtonA down to ButtonRight.
+const (
+	ButtonRight uint8 = 1 << iota
+	ButtonLeft
+	ButtonDown
+	ButtonUp
+	ButtonStart
+	ButtonSelect
+	ButtonB
+	ButtonA
+)
+
+// Controller is a standard controller plugged into one of the two ports, read serially through $4016 or $4017.
+type Controller struct {
+	buttons uint8 // buttons held down, see: ButtonA
+	strobe  bool  // the shift register is reloaded from the buttons while the strobe is high
+	shift   uint8 // buttons latched by the strobe, read out from the top bit
+}
+
+func (c *Controller) SetButtons(buttons uint8) {
+	c.buttons = buttons
+}
+
+func (c *Controller) Buttons() uint8 {
+	return c.buttons
+}
+
+func NewController() *Controller {
+	return &Controller{}
+}
+
+// Strobe sets the strobe, from OUT0.
+func (c *Controller) Strobe(data uint8) {
+	c.strobe = data&0x01 != 0
+	if c.strobe {
+		c.shift = c.buttons
+	}
+}
+
+// Read returns the next button in D0. While the strobe is high this is always the A button,
+// and once all 8 buttons have been read an official controller returns 1.
+func (c *Controller) Read() uint8 {
+	if c.strobe {
+		c.shift = c.buttons
+		return c.buttons >> 7
+	}
+	data := c.shift >> 7
+	c.shift = c.shift<<1 | 0x01
+	return data
+}
+
+func (c *Controller) Frame() {}
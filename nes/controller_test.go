@@ -0,0 +1,57 @@
+package nes
+
+import "testing"
+
+func TestControllerPorts(t *testing.T) {
+	vm := NewVM()
+	err := vm.LoadProgramSource(`
+		.org $0300
+		LDA #1
+		STA $4016	; strobe high: reads return the A button
+		LDA $4016
+		STA $20
+		LDA $4016
+		STA $21
+		LDA #0
+		STA $4016	; strobe low: latch the buttons
+		LDX #0
+@loop:	LDA $4016
+		STA $30,X
+		LDA $4017
+		STA $40,X
+		INX
+		CPX #10
+		BNE @loop
+		STA $4017	; a write to $4017 does not strobe the controllers
+		LDA $4016
+		STA $22
+done:	JMP done
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.SetControllerState(0, ButtonA|ButtonStart|ButtonRight)
+	vm.SetControllerState(1, ButtonB|ButtonLeft)
+	for i := 0; i < 200; i++ {
+		vm.Step()
+	}
+
+	ram := vm.PeekRAM(0x0000, 0x07FF)
+	// the top bits are the open bus, the high byte of the address
+	if ram[0x20] != 0x41 || ram[0x21] != 0x41 {
+		t.Errorf("got $%02X $%02X while strobing, expected $41 $41", ram[0x20], ram[0x21])
+	}
+	port1 := []uint8{1, 0, 0, 1, 0, 0, 0, 1, 1, 1}
+	port2 := []uint8{0, 1, 0, 0, 0, 0, 1, 0, 1, 1}
+	for i := range port1 {
+		if ram[0x30+i] != 0x40|port1[i] {
+			t.Errorf("port 1, read %d: got $%02X, expected $%02X", i, ram[0x30+i], 0x40|port1[i])
+		}
+		if ram[0x40+i] != 0x40|port2[i] {
+			t.Errorf("port 2, read %d: got $%02X, expected $%02X", i, ram[0x40+i], 0x40|port2[i])
+		}
+	}
+	if ram[0x22] != 0x41 {
+		t.Errorf("got $%02X after writing $4017, expected $41", ram[0x22])
+	}
+}
@@ -0,0 +1,392 @@
+package nes
+
+import (
+	"fmt"
+
+	"go-nes/mos6502"
+)
+
+// BreakReason tells why the debugger stopped the VM.
+type BreakReason uint8
+
+const (
+	BreakNone       BreakReason = iota
+	BreakBreakpoint             // the next instruction is at a breakpoint
+	BreakWatchpoint             // the last instruction accessed a watched address
+	BreakCondition              // a break condition holds before the next instruction
+	BreakNMI                    // the last instruction was followed by an NMI
+	BreakIRQ                    // the last instruction was followed by an IRQ
+	BreakBRK                    // the last instruction was a BRK
+	BreakReturn                 // the last instruction returned from the subroutine, see: Debugger.RunUntilReturn
+)
+
+func (r BreakReason) ToString() string {
+	switch r {
+	case BreakBreakpoint:
+		return "breakpoint"
+	case BreakWatchpoint:
+		return "watchpoint"
+	case BreakCondition:
+		return "condition"
+	case BreakNMI:
+		return "NMI"
+	case BreakIRQ:
+		return "IRQ"
+	case BreakBRK:
+		return "BRK"
+	case BreakReturn:
+		return "return"
+	}
+	return ""
+}
+
+// BreakEvent selects the interrupts the debugger breaks on.
+type BreakEvent uint8
+
+const (
+	BreakOnNMI BreakEvent = 1 << iota
+	BreakOnIRQ
+	BreakOnBRK
+)
+
+// MemorySpace is the address space a watchpoint applies to.
+type MemorySpace uint8
+
+const (
+	CPUMemory MemorySpace = iota // the CPU address space
+	PPUMemory                    // the PPU address space, as accessed by the CPU through PPUDATA ($2007)
+)
+
+func (s MemorySpace) ToString() string {
+	switch s {
+	case CPUMemory:
+		return "CPU"
+	case PPUMemory:
+		return "PPU"
+	}
+	return ""
+}
+
+// Access is a kind of memory access, which can be combined.
+type Access uint8
+
+const (
+	AccessRead Access = 1 << iota
+	AccessWrite
+
+	AccessReadWrite = AccessRead | AccessWrite
+)
+
+// Watchpoint breaks on accesses to a range of addresses.
+type Watchpoint struct {
+	Space  MemorySpace
+	Start  uint16
+	End    uint16 // inclusive
+	Access Access
+}
+
+// Break describes why and where the VM was stopped.
+type Break struct {
+	Reason BreakReason
+	PC     uint16 // address of the next instruction
+
+	// Watchpoint accesses only
+	Watchpoint Watchpoint
+	Addr       uint16
+	Access     Access
+	Data       uint8
+
+	// Breakpoints and conditions only
+	Condition string
+}
+
+func (b Break) ToString() string {
+	switch b.Reason {
+	case BreakWatchpoint:
+		access := "read"
+		if b.Access == AccessWrite {
+			access = "write"
+		}
+		return fmt.Sprintf("%s %s $%04X = $%02X", b.Watchpoint.Space.ToString(), access, b.Addr, b.Data)
+	case BreakBreakpoint, BreakCondition:
+		if b.Condition != "" {
+			return fmt.Sprintf("%s: %s", b.Reason.ToString(), b.Condition)
+		}
+	}
+	return b.Reason.ToString()
+}
+
+// breakpoint is an execution breakpoint, or a break condition when it has no address.
+type breakpoint struct {
+	expression string
+	condition  *Condition // nil for an unconditional breakpoint
+}
+
+// Debugger stops the VM on breakpoints, watchpoints and interrupts.
+// The checks are made between instructions, by VM.Step, VM.StepCycle and VM.StepFrame, which stop early on a break.
+type Debugger struct {
+	bus     *Bus
+	symbols *Symbols
+
+	breakpoints    map[uint16]breakpoint
+	romBreakpoints map[int]breakpoint // by PRG ROM offset, so they only apply while their bank is mapped
+	conditions     []breakpoint
+	watchpoints    []Watchpoint
+	events         BreakEvent
+
+	// Stack pointer to return above, see: RunUntilReturn
+	returning bool
+	returnSP  uint8
+
+	hit Break
+}
+
+func NewDebugger(bus *Bus) *Debugger {
+	return &Debugger{
+		bus:            bus,
+		symbols:        NewSymbols(),
+		breakpoints:    map[uint16]breakpoint{},
+		romBreakpoints: map[int]breakpoint{},
+	}
+}
+
+// AddBreakpoint breaks before the instruction at the given address is executed.
+func (d *Debugger) AddBreakpoint(addr uint16) {
+	d.breakpoints[addr] = breakpoint{}
+}
+
+// AddConditionalBreakpoint breaks before the instruction at the given address is executed, if the condition holds.
+// See ParseCondition for the syntax of the condition.
+func (d *Debugger) AddConditionalBreakpoint(addr uint16, expression string) error {
+	condition, err := ParseCondition(expression)
+	if err != nil {
+		return err
+	}
+	d.breakpoints[addr] = breakpoint{expression: expression, condition: condition}
+	return nil
+}
+
+// AddSymbolBreakpoint breaks before the instruction at the symbol with the given name is executed.
+// Symbols in the PRG ROM only break while their bank is mapped. All symbols with the name are used.
+func (d *Debugger) AddSymbolBreakpoint(name string) error {
+	symbols := d.symbols.Lookup(name)
+	if len(symbols) == 0 {
+		return fmt.Errorf("unknown symbol %q", name)
+	}
+	for _, symbol := range symbols {
+		if symbol.InPRGROM {
+			d.romBreakpoints[symbol.Offset] = breakpoint{}
+		} else {
+			d.breakpoints[symbol.Addr] = breakpoint{}
+		}
+	}
+	return nil
+}
+
+func (d *Debugger) RemoveSymbolBreakpoint(name string) {
+	for _, symbol := range d.symbols.Lookup(name) {
+		if symbol.InPRGROM {
+			delete(d.romBreakpoints, symbol.Offset)
+		} else {
+			delete(d.breakpoints, symbol.Addr)
+		}
+	}
+}
+
+// RemoveBreakpoint removes the breakpoint at the given address, including a symbol breakpoint in the mapped bank.
+func (d *Debugger) RemoveBreakpoint(addr uint16) {
+	delete(d.breakpoints, addr)
+	if offset, ok := d.prgRomOffset(addr); ok {
+		delete(d.romBreakpoints, offset)
+	}
+}
+
+// ToggleBreakpoint adds or removes an unconditional breakpoint at the given address.
+func (d *Debugger) ToggleBreakpoint(addr uint16) {
+	if d.HasBreakpoint(addr) {
+		d.RemoveBreakpoint(addr)
+	} else {
+		d.AddBreakpoint(addr)
+	}
+}
+
+func (d *Debugger) HasBreakpoint(addr uint16) bool {
+	_, ok := d.breakpointAt(addr)
+	return ok
+}
+
+// breakpointAt returns the breakpoint at the given address, or at the PRG ROM offset currently mapped there.
+func (d *Debugger) breakpointAt(addr uint16) (breakpoint, bool) {
+	if bp, ok := d.breakpoints[addr]; ok {
+		return bp, true
+	}
+	if offset, ok := d.prgRomOffset(addr); ok {
+		if bp, ok := d.romBreakpoints[offset]; ok {
+			return bp, true
+		}
+	}
+	return breakpoint{}, false
+}
+
+func (d *Debugger) prgRomOffset(addr uint16) (int, bool) {
+	if d.bus.Cartridge == nil || len(d.romBreakpoints) == 0 {
+		return 0, false
+	}
+	return d.bus.Cartridge.prgRomOffset(addr)
+}
+
+// AddCondition breaks before any instruction for which the condition holds.
+// See ParseCondition for the syntax of the condition.
+func (d *Debugger) AddCondition(expression string) error {
+	condition, err := ParseCondition(expression)
+	if err != nil {
+		return err
+	}
+	d.conditions = append(d.conditions, breakpoint{expression: expression, condition: condition})
+	return nil
+}
+
+func (d *Debugger) RemoveCondition(expression string) {
+	for i, c := range d.conditions {
+		if c.expression == expression {
+			d.conditions = append(d.conditions[:i], d.conditions[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddWatchpoint breaks after an instruction accessed an address in the watched range.
+func (d *Debugger) AddWatchpoint(w Watchpoint) {
+	d.watchpoints = append(d.watchpoints, w)
+}
+
+func (d *Debugger) RemoveWatchpoint(w Watchpoint) {
+	for i, watchpoint := range d.watchpoints {
+		if watchpoint == w {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// BreakOn selects the interrupts to break on, once they have been entered.
+func (d *Debugger) BreakOn(events BreakEvent) {
+	d.events = events
+}
+
+// RunUntilReturn breaks once the current subroutine returns with an RTS or RTI.
+// Subroutines called in the meantime do not count, as they return above the current stack pointer.
+func (d *Debugger) RunUntilReturn() {
+	d.returning = true
+	d.returnSP = d.bus.CPU.Registers().SP
+}
+
+// Clear removes all breakpoints, conditions and watchpoints, and stops breaking on interrupts.
+func (d *Debugger) Clear() {
+	d.breakpoints = map[uint16]breakpoint{}
+	d.romBreakpoints = map[int]breakpoint{}
+	d.conditions = nil
+	d.watchpoints = nil
+	d.events = 0
+	d.returning = false
+}
+
+// Hit returns the break that stopped the VM during the last step, if any.
+func (d *Debugger) Hit() (Break, bool) {
+	return d.hit, d.hit.Reason != BreakNone
+}
+
+// resume clears the last break before the VM continues.
+func (d *Debugger) resume() {
+	d.hit = Break{}
+}
+
+// stop records a break, keeping the first one when several happen during the same instruction.
+func (d *Debugger) stop(b Break) {
+	if d.hit.Reason == BreakNone {
+		d.hit = b
+	}
+}
+
+// access checks a CPU bus access against the watchpoints.
+// Accesses to PPUDATA are also checked against the VRAM address they went to.
+func (d *Debugger) access(addr, vramAddr uint16, access Access, data uint8) {
+	if len(d.watchpoints) == 0 {
+		return
+	}
+	d.watch(CPUMemory, addr, access, data)
+	if addr >= 0x2000 && addr <= 0x3FFF && addr&0x0007 == 0x0007 {
+		d.watch(PPUMemory, vramAddr&0x3FFF, access, data)
+	}
+}
+
+func (d *Debugger) watch(space MemorySpace, addr uint16, access Access, data uint8) {
+	for _, w := range d.watchpoints {
+		if w.Space == space && w.Access&access != 0 && addr >= w.Start && addr <= w.End {
+			d.stop(Break{Reason: BreakWatchpoint, Watchpoint: w, Addr: addr, Access: access, Data: data})
+			return
+		}
+	}
+}
+
+// afterInstruction checks for a break once an instruction is complete, returning true if the VM should stop.
+func (d *Debugger) afterInstruction() bool {
+	cpu := d.bus.CPU
+
+	switch cpu.LastInterrupt() {
+	case mos6502.InterruptNMI:
+		if d.events&BreakOnNMI != 0 {
+			d.stop(Break{Reason: BreakNMI})
+		}
+	case mos6502.InterruptIRQ:
+		if d.events&BreakOnIRQ != 0 {
+			d.stop(Break{Reason: BreakIRQ})
+		}
+	case mos6502.InterruptBRK:
+		if d.events&BreakOnBRK != 0 {
+			d.stop(Break{Reason: BreakBRK})
+		}
+	}
+
+	registers := cpu.Registers()
+
+	if d.returning {
+		inst := cpu.Opcode(cpu.CurrentOpcode()).Instruction
+		sp := registers.SP
+		if cpu.LastInterrupt() != mos6502.InterruptNone {
+			sp += 3 // the interrupt following the instruction pushed the return address and status
+		}
+		if (inst == mos6502.RTS || inst == mos6502.RTI) && sp > d.returnSP {
+			d.returning = false
+			d.stop(Break{Reason: BreakReturn})
+		}
+	}
+
+	if bp, ok := d.breakpointAt(registers.PC); ok {
+		if bp.condition == nil || bp.condition.Eval(d.conditionEnv(registers)) {
+			d.stop(Break{Reason: BreakBreakpoint, Condition: bp.expression})
+		}
+	}
+
+	for _, c := range d.conditions {
+		if c.condition.Eval(d.conditionEnv(registers)) {
+			d.stop(Break{Reason: BreakCondition, Condition: c.expression})
+			break
+		}
+	}
+
+	if d.hit.Reason == BreakNone {
+		return false
+	}
+	d.hit.PC = registers.PC
+	return true
+}
+
+func (d *Debugger) conditionEnv(registers mos6502.Registers) ConditionEnv {
+	return ConditionEnv{
+		Registers: registers,
+		Cycle:     d.bus.CPU.Cycles(),
+		Scanline:  d.bus.PPU.scanline,
+		Dot:       d.bus.PPU.cycle,
+	}
+}
@@ -0,0 +1,269 @@
+package nes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go-nes/mos6502"
+)
+
+// ConditionEnv is the state of the VM that break conditions are evaluated against.
+type ConditionEnv struct {
+	Registers mos6502.Registers
+	Cycle     int
+	Scanline  int
+	Dot       int
+}
+
+// Condition is a parsed break condition, see: ParseCondition
+type Condition struct {
+	eval conditionFunc
+}
+
+type conditionFunc func(env *ConditionEnv) int
+
+// Eval returns true if the condition holds, i.e. its value is not zero.
+func (c *Condition) Eval(env ConditionEnv) bool {
+	return c.eval(&env) != 0
+}
+
+// conditionOperands are the values a condition can refer to, by their upper case name.
+var conditionOperands = map[string]conditionFunc{
+	"A":        func(env *ConditionEnv) int { return int(env.Registers.A) },
+	"X":        func(env *ConditionEnv) int { return int(env.Registers.X) },
+	"Y":        func(env *ConditionEnv) int { return int(env.Registers.Y) },
+	"P":        func(env *ConditionEnv) int { return int(env.Registers.P) },
+	"SP":       func(env *ConditionEnv) int { return int(env.Registers.SP) },
+	"PC":       func(env *ConditionEnv) int { return int(env.Registers.PC) },
+	"CYCLE":    func(env *ConditionEnv) int { return env.Cycle },
+	"SCANLINE": func(env *ConditionEnv) int { return env.Scanline },
+	"DOT":      func(env *ConditionEnv) int { return env.Dot },
+	"N":        conditionFlag(mos6502.N),
+	"V":        conditionFlag(mos6502.V),
+	"U":        conditionFlag(mos6502.U),
+	"B":        conditionFlag(mos6502.B),
+	"D":        conditionFlag(mos6502.D),
+	"I":        conditionFlag(mos6502.I),
+	"Z":        conditionFlag(mos6502.Z),
+	"C":        conditionFlag(mos6502.C),
+}
+
+func conditionFlag(flag mos6502.Flag) conditionFunc {
+	return func(env *ConditionEnv) int {
+		if env.Registers.P&uint8(flag) != 0 {
+			return 1
+		}
+		return 0
+	}
+}
+
+// conditionBinaryOperators lists the binary operators by increasing precedence.
+var conditionBinaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<=", ">=", "<", ">"},
+	{"|"},
+	{"&"},
+}
+
+// ParseCondition parses a break condition on the registers, flags and timing of the VM, e.g. "A == $10 && !Z".
+//
+// Operands are the registers A, X, Y, P, SP and PC, the flags N, V, U, B, D, I, Z and C which are 0 or 1,
+// CYCLE, SCANLINE and DOT, and numbers written in decimal, or hexadecimal with a $ or 0x prefix.
+// Operators are, by increasing precedence: ||, &&, the comparisons == != < <= > >=, the bitwise | and &,
+// and the unary !. Parentheses group sub-expressions.
+func ParseCondition(expression string) (*Condition, error) {
+	tokens, err := tokenizeCondition(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	p := &conditionParser{tokens: tokens}
+	eval, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in condition %q", p.tokens[p.pos], expression)
+	}
+	return &Condition{eval: eval}, nil
+}
+
+func tokenizeCondition(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			start := i
+			i++
+			for i < len(expression) && (unicode.IsLetter(rune(expression[i])) || unicode.IsDigit(rune(expression[i]))) {
+				i++
+			}
+			tokens = append(tokens, expression[start:i])
+		case strings.ContainsRune("=!<>&|", c):
+			if i+1 < len(expression) && isConditionOperator(expression[i:i+2]) {
+				tokens = append(tokens, expression[i:i+2])
+				i += 2
+			} else if isConditionOperator(expression[i:i+1]) || c == '!' {
+				tokens = append(tokens, expression[i:i+1])
+				i++
+			} else {
+				return nil, fmt.Errorf("unexpected %q in condition %q", c, expression)
+			}
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q in condition %q", c, expression)
+		}
+	}
+	return tokens, nil
+}
+
+func isConditionOperator(token string) bool {
+	for _, operators := range conditionBinaryOperators {
+		for _, operator := range operators {
+			if token == operator {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type conditionParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *conditionParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// parseBinary parses the operators from the given precedence level upwards, which are all left-associative.
+func (p *conditionParser) parseBinary(level int) (conditionFunc, error) {
+	if level == len(conditionBinaryOperators) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := p.peek()
+		found := false
+		for _, o := range conditionBinaryOperators[level] {
+			if operator == o {
+				found = true
+			}
+		}
+		if !found {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = conditionBinary(operator, left, right)
+	}
+}
+
+func conditionBinary(operator string, left, right conditionFunc) conditionFunc {
+	boolean := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	switch operator {
+	case "||":
+		return func(env *ConditionEnv) int { return boolean(left(env) != 0 || right(env) != 0) }
+	case "&&":
+		return func(env *ConditionEnv) int { return boolean(left(env) != 0 && right(env) != 0) }
+	case "==":
+		return func(env *ConditionEnv) int { return boolean(left(env) == right(env)) }
+	case "!=":
+		return func(env *ConditionEnv) int { return boolean(left(env) != right(env)) }
+	case "<":
+		return func(env *ConditionEnv) int { return boolean(left(env) < right(env)) }
+	case "<=":
+		return func(env *ConditionEnv) int { return boolean(left(env) <= right(env)) }
+	case ">":
+		return func(env *ConditionEnv) int { return boolean(left(env) > right(env)) }
+	case ">=":
+		return func(env *ConditionEnv) int { return boolean(left(env) >= right(env)) }
+	case "|":
+		return func(env *ConditionEnv) int { return left(env) | right(env) }
+	case "&":
+		return func(env *ConditionEnv) int { return left(env) & right(env) }
+	}
+	panic("unknown condition operator " + operator)
+}
+
+func (p *conditionParser) parseUnary() (conditionFunc, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of condition")
+	case token == "!":
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *ConditionEnv) int {
+			if operand(env) == 0 {
+				return 1
+			}
+			return 0
+		}, nil
+	case token == "(":
+		inner, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in condition")
+		}
+		return inner, nil
+	}
+
+	if operand, ok := conditionOperands[strings.ToUpper(token)]; ok {
+		return operand, nil
+	}
+	value, err := parseConditionNumber(token)
+	if err != nil {
+		return nil, fmt.Errorf("unknown operand %q in condition", token)
+	}
+	return func(env *ConditionEnv) int { return value }, nil
+}
+
+func parseConditionNumber(token string) (int, error) {
+	var value int64
+	var err error
+	switch {
+	case strings.HasPrefix(token, "$"):
+		value, err = strconv.ParseInt(token[1:], 16, 32)
+	case strings.HasPrefix(strings.ToLower(token), "0x"):
+		value, err = strconv.ParseInt(token[2:], 16, 32)
+	default:
+		value, err = strconv.ParseInt(token, 10, 32)
+	}
+	return int(value), err
+}
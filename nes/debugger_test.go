@@ -0,0 +1,130 @@
+package nes
+
+import (
+	"testing"
+
+	"go-nes/mos6502"
+)
+
+// newDebugVM returns a VM running the given program from $0300 in RAM.
+func newDebugVM(program []uint8) *VM {
+	vm := NewVM()
+	copy(vm.bus.CpuRam[0x0300:], program)
+	vm.bus.CPU.SetRegisters(mos6502.Registers{SP: 0xFD, P: 0x24, PC: 0x0300})
+	return vm
+}
+
+// LDX #$05, JSR $0310, STA $20, NOP, ..., $0310: LDA #$42, JSR $0320, RTS, ..., $0320: RTS
+var debugProgram = []uint8{
+	0xA2, 0x05, 0x20, 0x10, 0x03, 0x85, 0x20, 0xEA, 0, 0, 0, 0, 0, 0, 0, 0,
+	0xA9, 0x42, 0x20, 0x20, 0x03, 0x60, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0x60,
+}
+
+func TestDebuggerBreakpoint(t *testing.T) {
+	vm := newDebugVM(debugProgram)
+	vm.Debugger().AddBreakpoint(0x0305)
+	vm.StepFrame()
+
+	hit, ok := vm.Debugger().Hit()
+	if !ok || hit.Reason != BreakBreakpoint || hit.PC != 0x0305 || vm.PeekCPU().PC != 0x0305 {
+		t.Errorf("got %+v, expected a breakpoint at 0x0305", hit)
+	}
+
+	// resuming does not stop at the same breakpoint again
+	vm.Step()
+	if _, ok := vm.Debugger().Hit(); ok {
+		t.Errorf("got a break after resuming")
+	}
+}
+
+func TestDebuggerConditionalBreakpoint(t *testing.T) {
+	vm := newDebugVM(debugProgram)
+	if err := vm.Debugger().AddConditionalBreakpoint(0x0310, "X == 6"); err != nil {
+		t.Fatal(err)
+	}
+	vm.StepFrame()
+	if hit, ok := vm.Debugger().Hit(); ok {
+		t.Errorf("got %+v, expected no break", hit)
+	}
+
+	vm = newDebugVM(debugProgram)
+	if err := vm.Debugger().AddConditionalBreakpoint(0x0310, "X == $05 && !Z"); err != nil {
+		t.Fatal(err)
+	}
+	vm.StepFrame()
+	if hit, ok := vm.Debugger().Hit(); !ok || hit.PC != 0x0310 {
+		t.Errorf("got %+v, expected a breakpoint at 0x0310", hit)
+	}
+}
+
+func TestDebuggerWatchpoint(t *testing.T) {
+	vm := newDebugVM(debugProgram)
+	vm.Debugger().AddWatchpoint(Watchpoint{Space: CPUMemory, Start: 0x0010, End: 0x002F, Access: AccessWrite})
+	vm.StepFrame()
+
+	hit, ok := vm.Debugger().Hit()
+	if !ok || hit.Reason != BreakWatchpoint || hit.Addr != 0x0020 || hit.Data != 0x42 || hit.PC != 0x0307 {
+		t.Errorf("got %+v, expected a write of 0x42 to 0x0020", hit)
+	}
+}
+
+func TestDebuggerRunUntilReturn(t *testing.T) {
+	vm := newDebugVM(debugProgram)
+	vm.Step()
+	vm.Step()
+	vm.Debugger().RunUntilReturn()
+	vm.StepFrame()
+
+	hit, ok := vm.Debugger().Hit()
+	if !ok || hit.Reason != BreakReturn || hit.PC != 0x0305 {
+		t.Errorf("got %+v, expected a return to 0x0305", hit)
+	}
+}
+
+func TestDebuggerBreakOnBRK(t *testing.T) {
+	vm := newDebugVM([]uint8{0xEA, 0x00})
+	vm.Debugger().BreakOn(BreakOnBRK)
+	vm.StepFrame()
+
+	if hit, ok := vm.Debugger().Hit(); !ok || hit.Reason != BreakBRK {
+		t.Errorf("got %+v, expected a BRK", hit)
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	env := ConditionEnv{
+		Registers: mos6502.Registers{A: 0x10, X: 0x20, Y: 0x30, P: 0xA3, SP: 0xFD, PC: 0xC000},
+		Scanline:  241,
+	}
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{"A == $10", true},
+		{"a == 16", true},
+		{"X != 0x20", false},
+		{"Y > A && SP >= $FD", true},
+		{"PC < $8000 || C", true},
+		{"!(N && Z) || D", false},
+		{"P & $80", true},
+		{"(A | X) == $30", true},
+		{"SCANLINE == 241 && !V", true},
+	}
+	for _, tt := range tests {
+		condition, err := ParseCondition(tt.expression)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expression, err)
+			continue
+		}
+		if got := condition.Eval(env); got != tt.expected {
+			t.Errorf("%q: got %v, expected %v", tt.expression, got, tt.expected)
+		}
+	}
+
+	for _, expression := range []string{"", "A ==", "Q == 1", "(A == 1", "A = 1", "A == 1)"} {
+		if _, err := ParseCondition(expression); err == nil {
+			t.Errorf("%q: expected an error", expression)
+		}
+	}
+}
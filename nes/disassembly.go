@@ -0,0 +1,161 @@
+package nes
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go-nes/mos6502"
+)
+
+// interruptVectors are the addresses of the NMI, reset and IRQ vectors, in the order they appear in memory.
+var interruptVectors = []uint16{0xFFFA, 0xFFFC, 0xFFFE}
+
+// PeekDisassembly disassembles the code reachable from the interrupt vectors and the current instruction, by address.
+func (v *VM) PeekDisassembly() map[uint16]string {
+	d := mos6502.NewDisassembler(v.bus.CPU.Variant(), v.bus.Peek, 0x0000, 0xFFFF)
+	entries := []uint16{v.bus.CPU.Registers().PC}
+	for _, vector := range interruptVectors {
+		entries = append(entries, uint16(v.bus.Peek(vector+1))<<8|uint16(v.bus.Peek(vector)))
+	}
+	d.Trace(entries...)
+
+	// the loaded symbols name the operands, and label and comment the instructions
+	symbols := v.debugger.symbols
+	syntax := mos6502.Syntax{Label: func(addr uint16) string { return symbols.label(v.bus, addr) }}
+	disassembly := map[uint16]string{}
+	for _, i := range d.Instructions() {
+		line := i.Format(syntax)
+		if annotation := symbols.annotation(v.bus, i.Addr); annotation != "" {
+			line += " ; " + annotation
+		}
+		disassembly[i.Addr] = line
+	}
+	return disassembly
+}
+
+// ExportCA65 writes the given 16 KiB PRG ROM bank as ca65 source, which assembles back to the same bytes.
+// The code is found by following it from the interrupt vectors through the whole CPU address space, as currently mapped,
+// so that the bank's code called from other banks is found too. The rest of the bank is written as data.
+// Unofficial opcodes are written as data too, as assemblers do not agree on their mnemonics.
+func (v *VM) ExportCA65(w io.Writer, bank int) error {
+	c := v.bus.Cartridge
+	if c == nil {
+		return fmt.Errorf("no cartridge inserted")
+	}
+	if bank < 0 || bank >= int(c.prgRomBanks) {
+		return fmt.Errorf("PRG bank %d does not exist, the cartridge has %d", bank, c.prgRomBanks)
+	}
+	base, ok := c.prgBankAddress(bank)
+	if !ok {
+		return fmt.Errorf("PRG bank %d is not mapped into the CPU address space", bank)
+	}
+	end := base + 0x3FFF
+	data := c.prgRomData[bank*0x4000 : (bank+1)*0x4000]
+	// the other banks are read as mapped, RAM and I/O are unknown and read as 0
+	read := func(addr uint16) uint8 {
+		if addr >= base && addr <= end {
+			return data[addr-base]
+		}
+		if offset, ok := c.prgRomOffset(addr); ok && addr >= 0x8000 {
+			return c.prgRomData[offset]
+		}
+		return 0
+	}
+
+	d := mos6502.NewDisassembler(v.bus.CPU.Variant(), read, 0x0000, 0xFFFF)
+	var vectorTargets []uint16
+	for _, vector := range interruptVectors {
+		vectorTargets = append(vectorTargets, uint16(read(vector+1))<<8|uint16(read(vector)))
+	}
+	d.Trace(vectorTargets...)
+
+	// the vectors at the end of the last bank are written as a .word of their own
+	hasVectors := end == 0xFFFF
+	dataEnd := int(end)
+	if hasVectors {
+		dataEnd = int(interruptVectors[0]) - 1
+	}
+
+	// label the referenced addresses that start an instruction or data, on the lines written before the vectors
+	labels := map[uint16]string{}
+	for addr := int(base); addr <= dataEnd; addr++ {
+		a := uint16(addr)
+		if d.IsReferenced(a) && (d.IsCode(a) || !d.IsCovered(a)) {
+			labels[a] = fmt.Sprintf("L%04X", a)
+		}
+	}
+	for _, target := range vectorTargets {
+		if target >= base && int(target) <= dataEnd && d.IsCode(target) {
+			labels[target] = fmt.Sprintf("L%04X", target)
+		}
+	}
+	syntax := mos6502.Syntax{
+		Label:         func(addr uint16) string { return labels[addr] },
+		ForceAbsolute: true,
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "; PRG bank %d of %d, mapped at $%04X-$%04X\n\n", bank, c.prgRomBanks, base, end)
+	fmt.Fprintf(&out, ".setcpu \"%s\"\n.org $%04X\n", ca65CPU(v.bus.CPU.Variant()), base)
+	for addr := int(base); addr <= dataEnd; {
+		a := uint16(addr)
+		if label, ok := labels[a]; ok {
+			fmt.Fprintf(&out, "\n%s:\n", label)
+		}
+
+		// an instruction running past the end of the bank is written as data
+		if i := d.Decode(a); d.IsCode(a) && addr+len(i.Bytes)-1 <= dataEnd {
+			if i.Opcode.Unofficial {
+				fmt.Fprintf(&out, "\t.byte %s ; %s\n", formatCA65Bytes(i.Bytes), i.Format(syntax))
+			} else {
+				fmt.Fprintf(&out, "\t%s\n", i.Format(syntax))
+			}
+			addr += len(i.Bytes)
+			continue
+		}
+
+		// data runs up to 16 bytes, stopping at labels and code
+		var bytes []uint8
+		for addr <= dataEnd && len(bytes) < 16 {
+			a := uint16(addr)
+			if len(bytes) > 0 && (labels[a] != "" || d.IsCode(a)) {
+				break
+			}
+			bytes = append(bytes, read(a))
+			addr++
+		}
+		fmt.Fprintf(&out, "\t.byte %s\n", formatCA65Bytes(bytes))
+	}
+
+	if hasVectors {
+		targets := make([]string, len(vectorTargets))
+		for n, target := range vectorTargets {
+			targets[n] = fmt.Sprintf("$%04X", target)
+			if label, ok := labels[target]; ok {
+				targets[n] = label
+			}
+		}
+		fmt.Fprintf(&out, "\n; NMI, reset and IRQ vectors\n\t.word %s\n", strings.Join(targets, ", "))
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// ca65CPU returns the name of the given variant for the .setcpu directive of ca65.
+// The 2A03 is written as a 6502, its unofficial opcodes are written as bytes.
+func ca65CPU(variant mos6502.Variant) string {
+	if variant == mos6502.CMOS65C02 {
+		return "65C02"
+	}
+	return "6502"
+}
+
+func formatCA65Bytes(bytes []uint8) string {
+	values := make([]string, len(bytes))
+	for n, b := range bytes {
+		values[n] = fmt.Sprintf("$%02X", b)
+	}
+	return strings.Join(values, ", ")
+}
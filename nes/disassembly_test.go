@@ -0,0 +1,181 @@
+package nes
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-nes/assembler"
+	"go-nes/mos6502"
+)
+
+// writeTestROM writes a 1-bank NROM image with the given program at $C000, and returns its path.
+// The reset vector points to $C000, and the NMI and IRQ vectors to the given address.
+func writeTestROM(t *testing.T, program []uint8, interruptHandler uint16) string {
+	rom := make([]uint8, 16+16384+8192)
+	copy(rom, []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0, 0})
+	copy(rom[16:], program)
+	copy(rom[16+0x3FFA:], []uint8{uint8(interruptHandler), uint8(interruptHandler >> 8), 0x00, 0xC0, uint8(interruptHandler), uint8(interruptHandler >> 8)})
+
+	path := filepath.Join(t.TempDir(), "test.nes")
+	if err := os.WriteFile(path, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var disassemblyProgram = []uint8{
+	0x78,       // C000: SEI
+	0xA2, 0xFF, // C001: LDX #$FF
+	0x9A,             // C003: TXS
+	0xAD, 0x12, 0x00, // C004: LDA $0012
+	0xD0, 0x03, // C007: BNE $C00C
+	0x20, 0x10, 0xC0, // C009: JSR $C010
+	0x4C, 0x04, 0xC0, // C00C: JMP $C004
+	0xFF,             // C00F: data
+	0xBD, 0x20, 0xC0, // C010: LDA $C020,X
+	0x07, 0x10, // C013: SLO $10
+	0x60,                      // C015: RTS
+	0x40,                      // C016: RTI
+	0, 0, 0, 0, 0, 0, 0, 0, 0, // C017: data
+	0x01, 0x02, 0x03, // C020: data
+}
+
+func TestPeekDisassembly(t *testing.T) {
+	vm := NewVM()
+	vm.LoadROM(writeTestROM(t, disassemblyProgram, 0xC016))
+	vm.PowerCycle()
+
+	disassembly := vm.PeekDisassembly()
+	expected := map[uint16]string{
+		0xC000: "SEI",
+		0xC004: "LDA $0012",
+		0xC007: "BNE $C00C",
+		0xC010: "LDA $C020,X",
+		0xC013: "SLO $10",
+		0xC016: "RTI",
+	}
+	for addr, line := range expected {
+		if disassembly[addr] != line {
+			t.Errorf("0x%04X: got %q, expected %q", addr, disassembly[addr], line)
+		}
+	}
+	for _, addr := range []uint16{0xC00F, 0xC017, 0xC020} {
+		if line, ok := disassembly[addr]; ok {
+			t.Errorf("0x%04X: got %q, expected data", addr, line)
+		}
+	}
+}
+
+func TestExportCA65(t *testing.T) {
+	vm := NewVM()
+	vm.LoadROM(writeTestROM(t, disassemblyProgram, 0xC016))
+
+	var out strings.Builder
+	if err := vm.ExportCA65(&out, 0); err != nil {
+		t.Fatal(err)
+	}
+	source := out.String()
+
+	for _, line := range []string{
+		".org $C000",
+		"\nLC000:\n\tSEI\n",
+		"\nLC004:\n\tLDA a:$0012\n\tBNE LC00C\n\tJSR LC010\n",
+		"\tJMP LC004\n\t.byte $FF\n",
+		"\tLDA LC020,X\n\t.byte $07, $10 ; SLO $10\n\tRTS\n",
+		"\nLC020:\n\t.byte $01, $02, $03, $00,",
+		"\t.word LC016, LC000, LC016\n",
+	} {
+		if !strings.Contains(source, line) {
+			t.Errorf("expected the source to contain %q", line)
+		}
+	}
+
+	// the source assembles back to the bank
+	program, err := assembler.Assemble(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.Segments) != 1 || !bytes.Equal(program.Segments[0].Data, vm.bus.Cartridge.prgRomData) {
+		t.Errorf("expected the source to assemble back to the PRG ROM")
+	}
+
+	if err := vm.ExportCA65(&out, 1); err == nil {
+		t.Errorf("expected an error for a bank that does not exist")
+	}
+}
+
+func TestExportCA65OtherBank(t *testing.T) {
+	// an NROM-256 game, whose code in bank 0 is only called from bank 1
+	program, err := assembler.Assemble(`
+		.org $8000
+sub:	LDA #$01
+		STA $00
+		RTS
+		.org $C000
+reset:	JSR sub
+		JMP reset
+nmi:	RTI
+		.org $FFFA
+		.word nmi, reset, nmi
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rom := make([]uint8, 16+2*16384+8192)
+	copy(rom, []uint8{'N', 'E', 'S', 0x1A, 2, 1, 0, 0})
+	for _, segment := range program.Segments {
+		copy(rom[16+int(segment.Addr)-0x8000:], segment.Data)
+	}
+	path := filepath.Join(t.TempDir(), "test.nes")
+	if err := os.WriteFile(path, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	vm := NewVM()
+	vm.LoadROM(path)
+
+	var out strings.Builder
+	if err := vm.ExportCA65(&out, 0); err != nil {
+		t.Fatal(err)
+	}
+	if line := "\nL8000:\n\tLDA #$01\n\tSTA $00\n\tRTS\n"; !strings.Contains(out.String(), line) {
+		t.Errorf("expected bank 0 to contain %q, got:\n%s", line, out.String())
+	}
+	if strings.Contains(out.String(), ".word") {
+		t.Errorf("expected no vectors in bank 0")
+	}
+}
+
+func TestExportCA65Vectors(t *testing.T) {
+	vm := NewVM()
+	// LDA $FFFC, JMP $C000
+	vm.LoadROM(writeTestROM(t, []uint8{0xAD, 0xFC, 0xFF, 0x4C, 0x00, 0xC0}, 0xC000))
+
+	var out strings.Builder
+	if err := vm.ExportCA65(&out, 0); err != nil {
+		t.Fatal(err)
+	}
+	// the vectors are written as a .word without a line of their own, so they are not labelled
+	if line := "\tLDA $FFFC\n"; !strings.Contains(out.String(), line) {
+		t.Errorf("expected the source to contain %q, got:\n%s", line, out.String())
+	}
+	program, err := assembler.Assemble(out.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.Segments) != 1 || !bytes.Equal(program.Segments[0].Data, vm.bus.Cartridge.prgRomData) {
+		t.Errorf("expected the source to assemble back to the PRG ROM")
+	}
+
+	// the source of a 65C02 is assembled for the 65C02
+	vm.bus.CPU = mos6502.NewCPU(vm.bus, mos6502.CMOS65C02)
+	out.Reset()
+	if err := vm.ExportCA65(&out, 0); err != nil {
+		t.Fatal(err)
+	}
+	if line := ".setcpu \"65C02\"\n"; !strings.Contains(out.String(), line) {
+		t.Errorf("expected the source to contain %q", line)
+	}
+}
@@ -0,0 +1,88 @@
+// Input Reference: https://www.nesdev.org/wiki/Input_devices
+
+package nes
+
+// InputDevice is a peripheral plugged into one of the two controller ports.
+// Devices of the Famicom expansion port are plugged into the port whose register they are read through.
+type InputDevice interface {
+	// Strobe receives the OUT0-OUT2 bits (0-2) of a write to $4016, which go to both ports.
+	Strobe(data uint8)
+
+	// Read returns the D0-D4 bits (0-4) of a read of the port's register, $4016 or $4017.
+	// The other bits are open bus.
+	Read() uint8
+
+	// Frame is called at the end of every frame.
+	Frame()
+}
+
+// videoDevice is an input device that looks at the picture, such as the Zapper.
+type videoDevice interface {
+	connectPPU(ppu *PPU)
+}
+
+// ButtonDevice is an input device with buttons that can be set as a whole, see: VM.SetControllerState
+type ButtonDevice interface {
+	InputDevice
+	SetButtons(buttons uint8)
+}
+
+// pairDevice is an input device with two controllers behind its port, such as a side of the FourPlayerAdapter.
+// The second controller is that of player 3 or 4.
+type pairDevice interface {
+	InputDevice
+	setPair(first, second uint8)
+}
+
+// ConnectInputDevice plugs the device into the given port (0 or 1), or unplugs the port when the device is nil.
+// Standard controllers are plugged into both ports by default.
+func (v *VM) ConnectInputDevice(port int, device InputDevice) {
+	if device, ok := device.(videoDevice); ok {
+		device.connectPPU(v.bus.PPU)
+	}
+	v.bus.Ports[port] = device
+	v.bus.updatePort(port)
+}
+
+func (v *VM) InputDevice(port int) InputDevice {
+	return v.bus.Ports[port]
+}
+
+// SetControllerState sets the buttons held down by the given player (0-3), see: ButtonA
+// Players 1 and 2 are the devices in ports 0 and 1, players 3 and 4 the second controllers of a FourPlayerAdapter.
+// The state goes through the InputFilter, devices without buttons, and empty ports, ignore it.
+func (v *VM) SetControllerState(player int, buttons uint8) {
+	v.bus.input[player].buttons = buttons
+	v.bus.updatePort(player % 2)
+}
+
+// readPort reads the register of the given port, the bits not driven by the device are open bus.
+func (b *Bus) readPort(port int) uint8 {
+	if b.Ports[port] == nil {
+		return b.openBus & 0xE0
+	}
+	return b.openBus&0xE0 | b.Ports[port].Read()&0x1F
+}
+
+// strobePorts sends a write to $4016 to the devices in both ports.
+func (b *Bus) strobePorts(data uint8) {
+	for _, device := range b.Ports {
+		if device != nil {
+			device.Strobe(data & 0x07)
+		}
+	}
+}
+
+// inputFrame ends the frame of the devices in both ports, and moves the turbo buttons on to the next frame.
+func (b *Bus) inputFrame() {
+	for _, device := range b.Ports {
+		if device != nil {
+			device.Frame()
+		}
+	}
+	if b.movie != nil {
+		b.startMovieFrame()
+		return
+	}
+	b.updatePorts()
+}
@@ -0,0 +1,39 @@
+// Arkanoid Controller Reference: https://www.nesdev.org/wiki/Arkanoid_controller
+
+package nes
+
+// ArkanoidController is the Vaus paddle that comes with the NES version of Arkanoid, plugged into port 2.
+// Its knob is read as 8 bits on D4, inverted and from the top bit, and its fire button on D3.
+type ArkanoidController struct {
+	Position uint8 // position of the knob, from about $62 (left) to $F2 (right) on a typical paddle
+	Fire     bool
+
+	strobe bool
+	shift  uint8
+}
+
+func NewArkanoidController() *ArkanoidController {
+	return &ArkanoidController{Position: 0x62}
+}
+
+// Strobe latches the position of the knob, while OUT0 is high.
+func (a *ArkanoidController) Strobe(data uint8) {
+	a.strobe = data&0x01 != 0
+	if a.strobe {
+		a.shift = ^a.Position
+	}
+}
+
+func (a *ArkanoidController) Read() uint8 {
+	if a.strobe {
+		a.shift = ^a.Position
+	}
+	data := a.shift >> 7 << 4
+	a.shift <<= 1
+	if a.Fire {
+		data |= 0x08
+	}
+	return data
+}
+
+func (a *ArkanoidController) Frame() {}
@@ -0,0 +1,80 @@
+package nes
+
+// InputFilter changes the buttons set with VM.SetControllerState before they reach the devices in the ports,
+// so that the filtered state is what the game reads, whether it comes from the host, a movie or a test.
+type InputFilter struct {
+	// TurboRate is the length in frames of a turbo cycle, the turbo buttons are held down for the first half of it.
+	// Turbo is off below 2.
+	TurboRate int
+
+	// AllowOpposite lets Up+Down and Left+Right be held down together, which some games do not expect.
+	// Otherwise both buttons of such a pair are released.
+	AllowOpposite bool
+}
+
+// playerInput is the state set for a player, before it is filtered.
+type playerInput struct {
+	buttons uint8
+	turbo   uint8 // buttons held down every other half of the turbo cycle
+}
+
+// apply returns the buttons held down in the given frame.
+func (f InputFilter) apply(input playerInput, frame uint64) uint8 {
+	buttons := input.buttons
+	if f.TurboRate >= 2 && frame%uint64(f.TurboRate) < uint64(f.TurboRate+1)/2 {
+		buttons |= input.turbo
+	}
+	if !f.AllowOpposite {
+		if buttons&(ButtonUp|ButtonDown) == ButtonUp|ButtonDown {
+			buttons &^= ButtonUp | ButtonDown
+		}
+		if buttons&(ButtonLeft|ButtonRight) == ButtonLeft|ButtonRight {
+			buttons &^= ButtonLeft | ButtonRight
+		}
+	}
+	return buttons
+}
+
+// SetInputFilter changes the filter applied to the controller states, see: InputFilter
+func (v *VM) SetInputFilter(filter InputFilter) {
+	v.bus.inputFilter = filter
+	v.bus.updatePorts()
+}
+
+func (v *VM) InputFilter() InputFilter {
+	return v.bus.inputFilter
+}
+
+// SetTurboState sets the buttons of the given player (0-3) that are held down with turbo,
+// which are pressed and released every turbo cycle, see: InputFilter.TurboRate and VM.SetControllerState
+func (v *VM) SetTurboState(player int, buttons uint8) {
+	v.bus.input[player].turbo = buttons
+	v.bus.updatePort(player % 2)
+}
+
+// updatePort passes the filtered state of the given port on to its device.
+// While a movie is active, the state is only passed on at the start of a frame, see: startMovieFrame
+func (b *Bus) updatePort(port int) {
+	if b.movie != nil {
+		return
+	}
+	frame := b.PPU.frameCount
+	b.setPortButtons(port, b.inputFilter.apply(b.input[port], frame), b.inputFilter.apply(b.input[port+2], frame))
+}
+
+// setPortButtons passes the buttons of the players of the given port on to its device,
+// the second player only reaches a pairDevice.
+func (b *Bus) setPortButtons(port int, first, second uint8) {
+	switch device := b.Ports[port].(type) {
+	case pairDevice:
+		device.setPair(first, second)
+	case ButtonDevice:
+		device.SetButtons(first)
+	}
+}
+
+func (b *Bus) updatePorts() {
+	for port := range b.Ports {
+		b.updatePort(port)
+	}
+}
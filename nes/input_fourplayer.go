@@ -0,0 +1,83 @@
+// Four Score Reference: https://www.nesdev.org/wiki/Four_player_adapters
+
+package nes
+
+// FourPlayerAdapter connects four standard controllers to both ports, see: FourPlayerAdapter.Port
+//
+// The NES Four Score reads controllers 1 and 3 through $4016, and 2 and 4 through $4017, one after the other on D0,
+// followed by a signature telling it apart from a single controller. The Famicom adapters read controllers 3 and 4
+// on D1 instead, alongside controllers 1 and 2.
+type FourPlayerAdapter struct {
+	Famicom bool
+
+	buttons [4]uint8
+	ports   [2]fourPlayerPort
+}
+
+// fourPlayerSignatures are read on D0 after the controllers of the NES Four Score, by port.
+var fourPlayerSignatures = [2]uint8{0x10, 0x20}
+
+type fourPlayerPort struct {
+	adapter *FourPlayerAdapter
+	index   int
+	strobe  bool
+	shift   uint32 // D0: first controller, second controller and signature, read out from bit 23
+	shiftD1 uint8  // D1 of the Famicom adapters: second controller, read out from the top bit
+}
+
+func NewFourPlayerAdapter(famicom bool) *FourPlayerAdapter {
+	a := &FourPlayerAdapter{Famicom: famicom}
+	for i := range a.ports {
+		a.ports[i] = fourPlayerPort{adapter: a, index: i}
+	}
+	return a
+}
+
+// Port returns the side of the adapter to plug into the given port (0 or 1).
+func (a *FourPlayerAdapter) Port(port int) InputDevice {
+	return &a.ports[port]
+}
+
+// SetButtons sets the buttons held down on the controller of the given player (0-3), see: ButtonA
+func (a *FourPlayerAdapter) SetButtons(player int, buttons uint8) {
+	a.buttons[player] = buttons
+}
+
+// setPair sets the buttons of both controllers on this side of the adapter, see: VM.SetControllerState
+func (p *fourPlayerPort) setPair(first, second uint8) {
+	p.adapter.buttons[p.index], p.adapter.buttons[p.index+2] = first, second
+}
+
+func (p *fourPlayerPort) latch() {
+	first, second := p.adapter.buttons[p.index], p.adapter.buttons[p.index+2]
+	if p.adapter.Famicom {
+		// the shift registers of the Famicom adapters are only 8 bits long
+		p.shift = uint32(first)<<16 | 0xFFFF
+		p.shiftD1 = second
+	} else {
+		p.shift = uint32(first)<<16 | uint32(second)<<8 | uint32(fourPlayerSignatures[p.index])
+	}
+}
+
+func (p *fourPlayerPort) Strobe(data uint8) {
+	p.strobe = data&0x01 != 0
+	if p.strobe {
+		p.latch()
+	}
+}
+
+// Read returns the next bits, which are 1 once everything has been read.
+func (p *fourPlayerPort) Read() uint8 {
+	if p.strobe {
+		p.latch()
+	}
+	data := uint8(p.shift>>23) & 0x01
+	p.shift = p.shift<<1 | 0x01
+	if p.adapter.Famicom {
+		data |= p.shiftD1 >> 7 << 1
+		p.shiftD1 = p.shiftD1<<1 | 0x01
+	}
+	return data
+}
+
+func (p *fourPlayerPort) Frame() {}
@@ -0,0 +1,58 @@
+// Family BASIC Keyboard Reference: https://www.nesdev.org/wiki/Family_BASIC_Keyboard
+
+package nes
+
+// FamicomKeyboard is the Family BASIC keyboard of the Famicom expansion port, read through $4017 (port 2).
+//
+// Its 72 keys form a matrix of 9 rows of 8 keys, selected by writes to $4016: OUT0 returns to the first row,
+// OUT1 selects the first or last 4 keys of the row, moving to the next row when it goes low, and OUT2 enables
+// the keyboard. The selected keys are read on D1-D4, 0 for a key that is pressed.
+type FamicomKeyboard struct {
+	keys [9]uint8 // bits 0-3 are the keys of the first column, bits 4-7 those of the second
+
+	enabled bool
+	row     int
+	column  int
+}
+
+func NewFamicomKeyboard() *FamicomKeyboard {
+	return &FamicomKeyboard{}
+}
+
+// SetKey presses or releases the given key (0-7) of the given row (0-8) of the matrix.
+func (k *FamicomKeyboard) SetKey(row, key int, pressed bool) {
+	if pressed {
+		k.keys[row] |= 1 << key
+	} else {
+		k.keys[row] &^= 1 << key
+	}
+}
+
+func (k *FamicomKeyboard) Strobe(data uint8) {
+	k.enabled = data&0x04 != 0
+	if !k.enabled {
+		return
+	}
+	column := int(data>>1) & 0x01
+	if k.column == 1 && column == 0 {
+		k.row++
+	}
+	k.column = column
+	if data&0x01 != 0 {
+		k.row = 0
+	}
+}
+
+// Read returns the selected keys on D1-D4, past the last row no key is pressed.
+func (k *FamicomKeyboard) Read() uint8 {
+	if !k.enabled {
+		return 0
+	}
+	if k.row >= len(k.keys) {
+		return 0x1E
+	}
+	keys := k.keys[k.row] >> (4 * k.column) & 0x0F
+	return ^keys << 1 & 0x1E
+}
+
+func (k *FamicomKeyboard) Frame() {}
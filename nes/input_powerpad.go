@@ -0,0 +1,63 @@
+// Power Pad Reference: https://www.nesdev.org/wiki/Power_Pad
+
+package nes
+
+// PowerPad is the Power Pad mat (Family Trainer on the Famicom), with 12 buttons, usually plugged into port 2.
+// Its buttons are read 8 at a time on D3, and 4 at a time on D4.
+type PowerPad struct {
+	buttons uint16 // bit n-1 is set while button n is pressed, numbered as on side B of the mat
+
+	strobe         bool
+	shiftD3        uint8
+	shiftD4        uint8
+	latchedButtons uint16
+}
+
+// powerPadOrder lists the buttons in the order they are read on D3 and D4.
+var powerPadOrder = [2][]int{
+	{2, 1, 5, 9, 6, 10, 11, 7},
+	{4, 3, 12, 8},
+}
+
+func NewPowerPad() *PowerPad {
+	return &PowerPad{}
+}
+
+// SetPressed sets the buttons stood on, bit n-1 for button n (1-12).
+func (p *PowerPad) SetPressed(buttons uint16) {
+	p.buttons = buttons
+}
+
+func (p *PowerPad) latch() {
+	p.shiftD3, p.shiftD4 = 0, 0xFF
+	for i, button := range powerPadOrder[0] {
+		if p.buttons>>(button-1)&0x01 != 0 {
+			p.shiftD3 |= 0x80 >> i
+		}
+	}
+	for i, button := range powerPadOrder[1] {
+		if p.buttons>>(button-1)&0x01 == 0 {
+			p.shiftD4 &^= 0x80 >> i
+		}
+	}
+}
+
+func (p *PowerPad) Strobe(data uint8) {
+	p.strobe = data&0x01 != 0
+	if p.strobe {
+		p.latch()
+	}
+}
+
+// Read returns the next buttons on D3 and D4, which read 1 once all buttons have been read.
+func (p *PowerPad) Read() uint8 {
+	if p.strobe {
+		p.latch()
+	}
+	data := p.shiftD3>>7<<3 | p.shiftD4>>7<<4
+	p.shiftD3 = p.shiftD3<<1 | 0x01
+	p.shiftD4 = p.shiftD4<<1 | 0x01
+	return data
+}
+
+func (p *PowerPad) Frame() {}
@@ -0,0 +1,247 @@
+package nes
+
+import "testing"
+
+// readBits strobes the device, then reads it the given number of times.
+func readBits(device InputDevice, n int) []uint8 {
+	device.Strobe(1)
+	device.Strobe(0)
+	bits := make([]uint8, n)
+	for i := range bits {
+		bits[i] = device.Read()
+	}
+	return bits
+}
+
+func expectBits(t *testing.T, name string, got []uint8, expected ...uint8) {
+	t.Helper()
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("%s: got %v, expected %v", name, got, expected)
+			return
+		}
+	}
+}
+
+func TestFourPlayerAdapter(t *testing.T) {
+	adapter := NewFourPlayerAdapter(false)
+	adapter.SetButtons(0, ButtonA)
+	adapter.SetButtons(1, ButtonB)
+	adapter.SetButtons(2, ButtonRight)
+	adapter.SetButtons(3, ButtonSelect)
+
+	port1 := readBits(adapter.Port(0), 25)
+	expectBits(t, "port 1", port1,
+		1, 0, 0, 0, 0, 0, 0, 0, // controller 1
+		0, 0, 0, 0, 0, 0, 0, 1, // controller 3
+		0, 0, 0, 1, 0, 0, 0, 0, // signature
+		1)
+	port2 := readBits(adapter.Port(1), 25)
+	expectBits(t, "port 2", port2,
+		0, 1, 0, 0, 0, 0, 0, 0, // controller 2
+		0, 0, 1, 0, 0, 0, 0, 0, // controller 4
+		0, 0, 1, 0, 0, 0, 0, 0, // signature
+		1)
+
+	adapter.Famicom = true
+	famicom := readBits(adapter.Port(0), 9)
+	expectBits(t, "famicom port 1", famicom, 1, 0, 0, 0, 0, 0, 0, 2, 3)
+}
+
+func TestArkanoidController(t *testing.T) {
+	vaus := NewArkanoidController()
+	vaus.Position = 0xA5
+	vaus.Fire = true
+	// the position is inverted on D4, the fire button is on D3
+	expectBits(t, "vaus", readBits(vaus, 8), 0x08, 0x18, 0x08, 0x18, 0x18, 0x08, 0x18, 0x08)
+}
+
+func TestPowerPad(t *testing.T) {
+	pad := NewPowerPad()
+	pad.SetPressed(1<<(1-1) | 1<<(7-1) | 1<<(3-1) | 1<<(12-1))
+	// D3: 2, 1, 5, 9, 6, 10, 11, 7, D4: 4, 3, 12, 8, then 1s
+	expectBits(t, "power pad", readBits(pad, 9), 0x00, 0x18, 0x10, 0x00, 0x10, 0x10, 0x10, 0x18, 0x18)
+}
+
+func TestFamicomKeyboard(t *testing.T) {
+	keyboard := NewFamicomKeyboard()
+	keyboard.SetKey(0, 1, true)
+	keyboard.SetKey(1, 6, true)
+
+	if data := keyboard.Read(); data != 0 {
+		t.Errorf("got $%02X while disabled, expected $00", data)
+	}
+	var got []uint8
+	keyboard.Strobe(0x05) // enable, first row
+	for row := 0; row < 10; row++ {
+		keyboard.Strobe(0x04)
+		got = append(got, keyboard.Read())
+		keyboard.Strobe(0x06)
+		got = append(got, keyboard.Read())
+	}
+	expectBits(t, "keyboard", got, 0x1A, 0x1E, 0x1E, 0x16, 0x1E, 0x1E)
+	if got[18] != 0x1E || got[19] != 0x1E {
+		t.Errorf("got $%02X $%02X past the last row, expected $1E $1E", got[18], got[19])
+	}
+}
+
+// frameCounter counts the frames it is told about.
+type frameCounter struct {
+	Controller
+	frames int
+}
+
+func (f *frameCounter) Frame() {
+	f.frames++
+}
+
+func TestInputDevicePorts(t *testing.T) {
+	vm := NewVM()
+	err := vm.LoadProgramSource(`
+		.org $0300
+		LDA #1
+		STA $4016
+		LDA #0
+		STA $4016
+		LDA $4016
+		STA $20
+		LDA $4017
+		STA $21
+done:	JMP done
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &frameCounter{}
+	vm.ConnectInputDevice(0, counter)
+	vm.ConnectInputDevice(1, nil)
+	vm.SetControllerState(0, ButtonA)
+	vm.SetControllerState(1, ButtonA)
+
+	vm.StepFrame()
+	vm.StepFrame()
+	ram := vm.PeekRAM(0x0020, 0x0021)
+	if ram[0] != 0x41 || ram[1] != 0x40 {
+		t.Errorf("got $%02X $%02X, expected $41 from the controller and $40 from the empty port", ram[0], ram[1])
+	}
+	if counter.frames != 2 {
+		t.Errorf("got %d frames, expected 2", counter.frames)
+	}
+}
+
+func TestZapper(t *testing.T) {
+	vm := NewVM()
+	zapper := NewZapper()
+	vm.ConnectInputDevice(1, zapper)
+	ppu := vm.bus.PPU
+	for i := range ppu.frame {
+		ppu.frame[i] = 0x0F // black
+	}
+	ppu.frame[100*ScreenWidth+101] = 0x30 // white
+
+	for _, test := range []struct {
+		name          string
+		x, y          int
+		scanline, dot int
+		light         bool
+	}{
+		{"lit", 100, 100, 105, 0, true},
+		{"near the aimed pixel", 99, 102, 105, 0, true},
+		{"out of range", 96, 100, 105, 0, false},
+		{"not drawn yet", 100, 100, 99, 300, false},
+		{"drawn on the current scanline", 100, 100, 100, 102, true},
+		{"not yet drawn on the current scanline", 100, 100, 100, 101, false},
+		{"decayed", 100, 100, 125, 0, false},
+		{"off the screen", -1, -1, 105, 0, false},
+	} {
+		zapper.Aim(test.x, test.y)
+		ppu.scanline, ppu.cycle = test.scanline, test.dot
+		if light := zapper.Read()&0x08 == 0; light != test.light {
+			t.Errorf("%s: got light %v, expected %v", test.name, light, test.light)
+		}
+	}
+
+	if zapper.Read()&0x10 != 0 {
+		t.Errorf("expected the trigger to be released")
+	}
+	zapper.SetTrigger(true)
+	if zapper.Read()&0x10 == 0 {
+		t.Errorf("expected the trigger to be pulled")
+	}
+}
+
+func TestInputFilter(t *testing.T) {
+	vm := NewVM()
+	if err := vm.LoadProgramSource("loop: JMP loop"); err != nil {
+		t.Fatal(err)
+	}
+	controller := vm.InputDevice(0).(*Controller)
+
+	vm.SetControllerState(0, ButtonUp|ButtonDown|ButtonLeft|ButtonA)
+	if got := controller.Buttons(); got != ButtonLeft|ButtonA {
+		t.Errorf("got buttons %08b, expected Up+Down to be released", got)
+	}
+	vm.SetInputFilter(InputFilter{AllowOpposite: true})
+	if got := controller.Buttons(); got != ButtonUp|ButtonDown|ButtonLeft|ButtonA {
+		t.Errorf("got buttons %08b, expected Up+Down to be allowed", got)
+	}
+
+	vm.SetControllerState(0, ButtonStart)
+	vm.SetTurboState(0, ButtonA|ButtonB)
+	vm.SetInputFilter(InputFilter{TurboRate: 3})
+	var pressed []bool
+	for i := 0; i < 6; i++ {
+		vm.StepFrame()
+		buttons := controller.Buttons()
+		if buttons&ButtonStart == 0 {
+			t.Fatalf("frame %d: Start was released by the turbo", i)
+		}
+		pressed = append(pressed, buttons&(ButtonA|ButtonB) == ButtonA|ButtonB)
+	}
+	// 2 frames down, 1 frame up
+	on := 0
+	for i, p := range pressed {
+		if p {
+			on++
+		}
+		if i > 0 && i < 5 && p && pressed[i-1] && pressed[i+1] {
+			t.Errorf("turbo held for 3 frames in a row: %v", pressed)
+		}
+	}
+	if on != 4 {
+		t.Errorf("turbo pressed on %d of 6 frames, expected 4: %v", on, pressed)
+	}
+}
+
+func TestFourPlayerInput(t *testing.T) {
+	vm := NewVM()
+	if err := vm.LoadProgramSource("loop: JMP loop"); err != nil {
+		t.Fatal(err)
+	}
+	adapter := NewFourPlayerAdapter(false)
+	vm.ConnectInputDevice(0, adapter.Port(0))
+	vm.ConnectInputDevice(1, adapter.Port(1))
+
+	vm.SetControllerState(0, ButtonA)
+	vm.SetControllerState(3, ButtonUp|ButtonDown|ButtonSelect)
+	vm.SetTurboState(2, ButtonB)
+	vm.SetInputFilter(InputFilter{TurboRate: 2})
+	if got := adapter.buttons; got != [4]uint8{ButtonA, 0, ButtonB, ButtonSelect} {
+		t.Errorf("got buttons %v, expected the turbo of player 3 and the filtered buttons of player 4", got)
+	}
+
+	movie := &Movie{}
+	vm.RecordMovie(movie)
+	vm.StepFrame()
+	vm.StopMovie()
+	if !movie.FourScore || movie.Frames[0].Buttons != [4]uint8{ButtonA, 0, ButtonB, ButtonSelect} {
+		t.Errorf("got four score %v, buttons %v", movie.FourScore, movie.Frames[0].Buttons)
+	}
+
+	vm.SetControllerState(2, 0)
+	vm.SetControllerState(3, 0)
+	vm.PlayMovie(movie)
+	if got := adapter.buttons; got != movie.Frames[0].Buttons {
+		t.Errorf("got buttons %v while playing back, expected %v", got, movie.Frames[0].Buttons)
+	}
+}
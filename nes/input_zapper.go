@@ -0,0 +1,81 @@
+// Zapper Reference: https://www.nesdev.org/wiki/Zapper
+
+package nes
+
+const (
+	// zapperRadius is the distance in pixels around the aimed pixel that the light sensor sees
+	zapperRadius = 2
+
+	// zapperDecay is the number of scanlines a pixel stays lit for, after the beam drew it
+	zapperDecay = 20
+
+	// zapperBrightness is the average of the RGB components of the dimmest colour the sensor reacts to
+	zapperBrightness = 0x55
+)
+
+// Zapper is the light gun, usually plugged into port 2.
+// Its trigger is read on D4, and its light sensor on D3, which reads 0 while the sensor sees light.
+//
+// The sensor sees the pixels around the aimed pixel, which have been drawn by the PPU during the last few scanlines.
+type Zapper struct {
+	x, y    int // aimed pixel, or off the screen
+	trigger bool
+
+	ppu *PPU
+}
+
+func NewZapper() *Zapper {
+	return &Zapper{x: -1, y: -1}
+}
+
+// Aim points the Zapper at the given pixel of the screen, coordinates outside of it point away from the screen.
+func (z *Zapper) Aim(x, y int) {
+	z.x, z.y = x, y
+}
+
+func (z *Zapper) SetTrigger(pulled bool) {
+	z.trigger = pulled
+}
+
+func (z *Zapper) connectPPU(ppu *PPU) {
+	z.ppu = ppu
+}
+
+func (z *Zapper) Strobe(data uint8) {}
+
+func (z *Zapper) Read() uint8 {
+	var data uint8
+	if z.trigger {
+		data |= 0x10
+	}
+	if !z.sensesLight() {
+		data |= 0x08
+	}
+	return data
+}
+
+func (z *Zapper) Frame() {}
+
+// sensesLight returns true if a bright pixel near the aimed one was drawn recently.
+func (z *Zapper) sensesLight() bool {
+	if z.ppu == nil || z.x < 0 || z.x >= ScreenWidth || z.y < 0 || z.y >= ScreenHeight {
+		return false
+	}
+	scanline, dot := z.ppu.scanline, z.ppu.cycle
+	for y := z.y - zapperRadius; y <= z.y+zapperRadius; y++ {
+		if y < 0 || y >= ScreenHeight || y > scanline || scanline-y > zapperDecay {
+			continue
+		}
+		for x := z.x - zapperRadius; x <= z.x+zapperRadius; x++ {
+			// pixel x is drawn on dot x+1
+			if x < 0 || x >= ScreenWidth || y == scanline && x >= dot {
+				continue
+			}
+			rgba := z.ppu.colorLookup[z.ppu.frame[y*ScreenWidth+x]]
+			if (int(rgba[0])+int(rgba[1])+int(rgba[2]))/3 >= zapperBrightness {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,386 @@
+// FM2 Reference: https://fceux.com/web/FM2.html
+
+package nes
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MovieCommand is a command of the console recorded in a frame of a Movie.
+type MovieCommand uint8
+
+const (
+	MovieSoftReset MovieCommand = 1 << iota // the reset button
+	MovieHardReset                          // the power button
+)
+
+// MovieFrame holds the input of one frame of a Movie.
+type MovieFrame struct {
+	Commands MovieCommand // carried out at the start of the frame
+	Buttons  [4]uint8     // the buttons of the controllers of the players, see: VM.SetControllerState
+}
+
+// Movie is a recording of the input of the standard controllers, frame by frame from power-on,
+// see: VM.RecordMovie and VM.PlayMovie. Players 3 and 4 are only recorded with a FourPlayerAdapter, see: FourScore
+type Movie struct {
+	ROMName       string
+	ROMChecksum   string // base64 encoded MD5 of the PRG and CHR ROM, as in FM2 files
+	GUID          string
+	PAL           bool
+	FourScore     bool          // recorded with a FourPlayerAdapter in the ports
+	PowerOn       *PowerOnState // content of the memories at power-on, or nil to keep that of the VM
+	RerecordCount int
+	Comments      []string
+	Frames        []MovieFrame
+}
+
+// movieSession records or plays back a movie. While a movie is active, the buttons of the controllers are only
+// changed at the start of a frame, so that a recording plays back exactly.
+type movieSession struct {
+	movie     *Movie
+	recording bool
+	frame     int          // next frame to play back
+	commands  MovieCommand // commands to carry out before the next instruction
+
+	// settings of the VM before playback, restored when it ends, see: endMovie
+	region  Region
+	powerOn PowerOnState
+}
+
+// RecordMovie powers on the console, then records the input into the given movie until StopMovie.
+// The PowerOnState of the VM is recorded along with the input, so that a movie made with random memories plays back.
+// The buttons set with SetControllerState are recorded after the InputFilter, once per frame,
+// and take effect from the start of the next frame. A Reset is recorded as a soft reset at the start of the current frame,
+// so resets should be made between frames, as the emulator does.
+func (v *VM) RecordMovie(movie *Movie) {
+	movie.Frames = movie.Frames[:0]
+	movie.PAL = v.Region() == PAL
+	_, movie.FourScore = v.bus.Ports[0].(pairDevice)
+	powerOn := v.PowerOnState()
+	movie.PowerOn = &powerOn
+	if v.bus.Cartridge != nil {
+		movie.ROMChecksum = v.bus.Cartridge.md5Checksum()
+	}
+	if movie.GUID == "" {
+		movie.GUID = newGUID()
+	}
+	v.bus.endMovie()
+	v.PowerCycle()
+	v.bus.movie = &movieSession{movie: movie, recording: true}
+	v.bus.startMovieFrame()
+}
+
+// PlayMovie powers on the console, then plays back the input of the given movie, ignoring SetControllerState.
+// The input goes to the devices in the ports, a FourScore movie needs a FourPlayerAdapter to play back.
+// Once all frames are played back, the controllers go back to the state set with SetControllerState.
+// The region and the PowerOnState of the VM are switched to those of the movie until the playback ends.
+func (v *VM) PlayMovie(movie *Movie) {
+	v.bus.endMovie()
+	session := &movieSession{movie: movie, region: v.Region(), powerOn: v.PowerOnState()}
+	region := NTSC
+	if movie.PAL {
+		region = PAL
+	}
+	if v.Region() != region {
+		v.SetRegion(region)
+	}
+	if movie.PowerOn != nil {
+		v.SetPowerOnState(*movie.PowerOn)
+	}
+	v.PowerCycle()
+	v.bus.movie = session
+	v.bus.startMovieFrame()
+}
+
+// StopMovie stops recording or playing back the current movie.
+func (v *VM) StopMovie() {
+	v.bus.endMovie()
+}
+
+func (v *VM) MovieRecording() bool {
+	return v.bus.movie != nil && v.bus.movie.recording
+}
+
+func (v *VM) MoviePlaying() bool {
+	return v.bus.movie != nil && !v.bus.movie.recording
+}
+
+// movieCommands carries out the commands of the movie frame that just started, before its first instruction.
+func (v *VM) movieCommands() {
+	session := v.bus.movie
+	if session == nil || session.recording || session.commands == 0 {
+		return
+	}
+	commands := session.commands
+	session.commands = 0
+	if commands&MovieHardReset != 0 {
+		v.bus.PowerCycle()
+	} else {
+		v.bus.Reset()
+	}
+}
+
+// startMovieFrame records the buttons of the controllers for the frame that starts, or sets them from the movie.
+func (b *Bus) startMovieFrame() {
+	session := b.movie
+	if session.recording {
+		var frame MovieFrame
+		for player := range b.input {
+			frame.Buttons[player] = b.inputFilter.apply(b.input[player], b.PPU.frameCount)
+		}
+		session.movie.Frames = append(session.movie.Frames, frame)
+		b.setButtons(frame.Buttons)
+		return
+	}
+
+	if session.frame >= len(session.movie.Frames) {
+		b.endMovie()
+		return
+	}
+	frame := session.movie.Frames[session.frame]
+	session.frame++
+	session.commands = frame.Commands
+	b.setButtons(frame.Buttons)
+}
+
+// endMovie ends the current movie, if any, and gives the controllers back to SetControllerState.
+// The region and the PowerOnState changed for a playback are restored.
+func (b *Bus) endMovie() {
+	session := b.movie
+	if session == nil {
+		return
+	}
+	b.movie = nil
+	if !session.recording {
+		if b.region != session.region {
+			b.SetRegion(session.region)
+		}
+		b.powerOnState = session.powerOn
+	}
+	b.updatePorts()
+}
+
+func (b *Bus) setButtons(buttons [4]uint8) {
+	for port := range b.Ports {
+		b.setPortButtons(port, buttons[port], buttons[port+2])
+	}
+}
+
+// md5Checksum returns the checksum of the PRG and CHR ROM, as FCEUX writes it in FM2 files.
+func (c *Cartridge) md5Checksum() string {
+	hash := md5.New()
+	hash.Write(c.prgRomData)
+	hash.Write(c.chrRomData)
+	return "base64:" + base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+func newGUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "00000000-0000-0000-0000-000000000000"
+	}
+	b[6] = b[6]&0x0F | 0x40
+	b[8] = b[8]&0x3F | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// fm2Buttons are the buttons in the order of the FM2 input log, from ButtonRight up to ButtonA.
+const fm2Buttons = "RLDUTSBA"
+
+// LoadFM2 reads the FCEUX movie at the given path.
+func LoadFM2(filePath string) (*Movie, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading movie file: %v", err)
+	}
+	defer f.Close()
+	return ReadFM2(f)
+}
+
+// ReadFM2 reads an FCEUX movie in the text format. Movies of the standard controllers and the Four Score are supported,
+// movies that start from a save state, or use other devices, are not.
+func ReadFM2(r io.Reader) (*Movie, error) {
+	movie := &Movie{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if text[0] == '|' {
+			frame, err := parseFM2Frame(text, movie.FourScore)
+			if err != nil {
+				return nil, fmt.Errorf("fm2 line %d: %v", line, err)
+			}
+			movie.Frames = append(movie.Frames, frame)
+			continue
+		}
+
+		key, value, _ := strings.Cut(text, " ")
+		switch key {
+		case "version":
+			if value != "3" {
+				return nil, fmt.Errorf("fm2 line %d: unsupported version %s", line, value)
+			}
+		case "rerecordCount":
+			movie.RerecordCount, _ = strconv.Atoi(value)
+		case "palFlag":
+			movie.PAL = value == "1"
+		case "romFilename":
+			movie.ROMName = value
+		case "romChecksum":
+			movie.ROMChecksum = value
+		case "guid":
+			movie.GUID = value
+		case "powerOn":
+			powerOn, err := parsePowerOn(value)
+			if err != nil {
+				return nil, fmt.Errorf("fm2 line %d: %v", line, err)
+			}
+			movie.PowerOn = &powerOn
+		case "fourscore":
+			movie.FourScore = value == "1"
+		case "comment":
+			movie.Comments = append(movie.Comments, value)
+		case "binary", "FDS":
+			if value != "0" {
+				return nil, fmt.Errorf("fm2 line %d: %s movies are not supported", line, key)
+			}
+		case "port0", "port1":
+			// 0 is an empty port, 1 a standard controller
+			if value != "0" && value != "1" {
+				return nil, fmt.Errorf("fm2 line %d: unsupported device %s in %s", line, value, key)
+			}
+		case "savestate":
+			return nil, fmt.Errorf("fm2 line %d: movies starting from a save state are not supported", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error while reading fm2 movie: %v", err)
+	}
+	return movie, nil
+}
+
+// parsePowerOn parses the powerOn key, which is not part of FM2 and is ignored by FCEUX,
+// e.g. "ram:random vram:zeros oam:zeros palette:zeros seed:42"
+func parsePowerOn(value string) (PowerOnState, error) {
+	state := DefaultPowerOnState()
+	fills := map[string]*MemoryFill{"ram": &state.RAM, "vram": &state.VRAM, "oam": &state.OAM, "palette": &state.Palette}
+	for _, field := range strings.Fields(value) {
+		name, setting, _ := strings.Cut(field, ":")
+		if name == "seed" {
+			seed, err := strconv.ParseInt(setting, 10, 64)
+			if err != nil {
+				return state, fmt.Errorf("invalid power-on seed %q", setting)
+			}
+			state.Seed = seed
+			continue
+		}
+		fill, ok := fills[name]
+		if !ok {
+			return state, fmt.Errorf("unknown power-on memory %q", name)
+		}
+		switch MemoryFill(setting) {
+		case "", FillZeros, FillOnes, FillPattern, FillRandom:
+			*fill = MemoryFill(setting)
+		default:
+			return state, fmt.Errorf("unknown power-on fill %q", setting)
+		}
+	}
+	return state, nil
+}
+
+// parseFM2Frame parses a line of the input log, e.g. |0|R......A|........||
+// Four Score movies have the fields of the four controllers instead of those of the two ports.
+func parseFM2Frame(text string, fourScore bool) (MovieFrame, error) {
+	var frame MovieFrame
+	players := 2
+	if fourScore {
+		players = 4
+	}
+	fields := strings.Split(text, "|")
+	if len(fields) < 2+players {
+		return frame, fmt.Errorf("invalid input %q", text)
+	}
+	commands, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return frame, fmt.Errorf("invalid commands %q", fields[1])
+	}
+	frame.Commands = MovieCommand(commands) & (MovieSoftReset | MovieHardReset)
+	for player := 0; player < players; player++ {
+		field := fields[2+player]
+		if field == "" {
+			continue
+		}
+		if len(field) != len(fm2Buttons) {
+			return frame, fmt.Errorf("invalid buttons %q", field)
+		}
+		for i := 0; i < len(field); i++ {
+			if field[i] != ' ' && field[i] != '.' {
+				frame.Buttons[player] |= 1 << i
+			}
+		}
+	}
+	return frame, nil
+}
+
+// SaveFM2 writes the movie to the given path, see: WriteFM2
+func (m *Movie) SaveFM2(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error while writing movie file: %v", err)
+	}
+	if err := m.WriteFM2(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteFM2 writes the movie in the FCEUX text format, with standard controllers in both ports, or the Four Score.
+// The PowerOnState is written to the extra powerOn key, see: parsePowerOn
+func (m *Movie) WriteFM2(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	palFlag := 0
+	if m.PAL {
+		palFlag = 1
+	}
+	fmt.Fprintf(out, "version 3\nemuVersion 22020\nrerecordCount %d\npalFlag %d\n", m.RerecordCount, palFlag)
+	fmt.Fprintf(out, "romFilename %s\nromChecksum %s\nguid %s\n", m.ROMName, m.ROMChecksum, m.GUID)
+	if p := m.PowerOn; p != nil {
+		fmt.Fprintf(out, "powerOn ram:%s vram:%s oam:%s palette:%s seed:%d\n", p.RAM, p.VRAM, p.OAM, p.Palette, p.Seed)
+	}
+	players, fourScore := 2, 0
+	if m.FourScore {
+		players, fourScore = 4, 1
+	}
+	fmt.Fprintf(out, "fourscore %d\nmicrophone 0\nport0 1\nport1 1\nport2 0\nFDS 0\nNewPPU 1\n", fourScore)
+	for _, comment := range m.Comments {
+		fmt.Fprintf(out, "comment %s\n", comment)
+	}
+
+	for _, frame := range m.Frames {
+		fmt.Fprintf(out, "|%d", frame.Commands)
+		for _, buttons := range frame.Buttons[:players] {
+			field := []byte(fm2Buttons)
+			for i := range field {
+				if buttons&(1<<i) == 0 {
+					field[i] = '.'
+				}
+			}
+			fmt.Fprintf(out, "|%s", field)
+		}
+		fmt.Fprintf(out, "||\n")
+	}
+	return out.Flush()
+}
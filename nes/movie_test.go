@@ -0,0 +1,270 @@
+package nes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go-nes/assembler"
+)
+
+// movieProgram reads the first controller every frame, and shows the buttons, and a sum of them, as backdrop colours.
+const movieProgram = `
+	.org $C000
+reset:	SEI
+	LDX #$FF
+	TXS
+vblank:	BIT $2002
+	BPL vblank
+frame:	BIT $2002
+	BPL frame
+	LDA #1
+	STA $4016
+	LDA #0
+	STA $4016
+	LDX #8
+read:	LDA $4016
+	LSR A
+	ROL $00
+	DEX
+	BNE read
+	LDA $00
+	CLC
+	ADC $01
+	STA $01
+	LDA #$3F
+	STA $2006
+	LDA #$00
+	STA $2006
+	LDA $00
+	AND #$3F
+	STA $2007
+	LDA $01
+	AND #$3F
+	STA $2007
+	LDA #$0A
+	STA $2001
+	JMP frame
+nmi:	RTI
+`
+
+func writeMovieROM(t *testing.T) string {
+	t.Helper()
+	program, err := assembler.Assemble(movieProgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeTestROM(t, program.Segments[0].Data, program.Labels["nmi"])
+}
+
+func movieFrameHashes(vm *VM, frames int) []uint64 {
+	hashes := make([]uint64, frames)
+	for i := range hashes {
+		vm.StepFrame()
+		hashes[i] = vm.FrameHash()
+	}
+	return hashes
+}
+
+func TestMovieRecordAndPlayback(t *testing.T) {
+	rom := writeMovieROM(t)
+	const frames = 40
+
+	recorder := NewVM()
+	recorder.LoadROM(rom)
+	movie := &Movie{ROMName: "test.nes"}
+	recorder.RecordMovie(movie)
+	recorded := make([]uint64, frames)
+	for i := range recorded {
+		recorder.SetControllerState(0, uint8(i*37))
+		if i == 25 {
+			recorder.Reset()
+		}
+		recorder.StepFrame()
+		recorded[i] = recorder.FrameHash()
+	}
+	recorder.StopMovie()
+
+	if len(movie.Frames) != frames+1 {
+		t.Fatalf("recorded %d frames, expected %d", len(movie.Frames), frames+1)
+	}
+	if movie.Frames[25].Commands != MovieSoftReset {
+		t.Errorf("got commands %d on frame 25, expected the soft reset", movie.Frames[25].Commands)
+	}
+	distinct := map[uint64]bool{}
+	for _, hash := range recorded {
+		distinct[hash] = true
+	}
+	if len(distinct) < frames/2 {
+		t.Fatalf("only %d distinct frames out of %d, the input does not show on screen", len(distinct), frames)
+	}
+
+	var fm2 bytes.Buffer
+	if err := movie.WriteFM2(&fm2); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ReadFM2(&fm2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run, m := range []*Movie{movie, imported, imported} {
+		player := NewVM()
+		player.LoadROM(rom)
+		player.PlayMovie(m)
+		played := movieFrameHashes(player, frames)
+		for i := range played {
+			if played[i] != recorded[i] {
+				t.Fatalf("run %d: frame %d has hash %016X, expected %016X as recorded", run, i, played[i], recorded[i])
+			}
+		}
+	}
+}
+
+func TestMoviePowerOnState(t *testing.T) {
+	rom := writeMovieROM(t)
+	const frames = 10
+	random := PowerOnState{RAM: FillRandom, VRAM: FillRandom, OAM: FillRandom, Palette: FillRandom, Seed: 42}
+
+	recorder := NewVM()
+	recorder.LoadROM(rom)
+	recorder.SetPowerOnState(random)
+	movie := &Movie{}
+	recorder.RecordMovie(movie)
+	recorded := movieFrameHashes(recorder, frames)
+	recordedRAM := recorder.PeekRAM(0x0000, 0x07FF)
+	recorder.StopMovie()
+
+	var fm2 bytes.Buffer
+	if err := movie.WriteFM2(&fm2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fm2.String(), "\npowerOn ram:random vram:random oam:random palette:random seed:42\n") {
+		t.Errorf("the power-on state is missing from the movie:\n%s", fm2.String())
+	}
+	imported, err := ReadFM2(&fm2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.PowerOn == nil || *imported.PowerOn != random {
+		t.Fatalf("got power-on state %+v, expected %+v", imported.PowerOn, random)
+	}
+
+	// the player starts with zeroed memories, unless the movie sets them
+	player := NewVM()
+	player.LoadROM(rom)
+	player.PlayMovie(imported)
+	played := movieFrameHashes(player, frames)
+	for i := range played {
+		if played[i] != recorded[i] {
+			t.Fatalf("frame %d has hash %016X, expected %016X as recorded", i, played[i], recorded[i])
+		}
+	}
+	if !bytes.Equal(player.PeekRAM(0x0000, 0x07FF), recordedRAM) {
+		t.Error("the RAM differs from the recording")
+	}
+
+	zeroed := NewVM()
+	zeroed.LoadROM(rom)
+	imported.PowerOn = nil
+	zeroed.PlayMovie(imported)
+	movieFrameHashes(zeroed, frames)
+	if bytes.Equal(zeroed.PeekRAM(0x0000, 0x07FF), recordedRAM) {
+		t.Error("the RAM is the same as recorded without the power-on state of the movie")
+	}
+
+	for _, value := range []string{"ram:bogus", "cpu:zeros", "seed:x"} {
+		if _, err := ReadFM2(strings.NewReader("version 3\npowerOn " + value + "\n")); err == nil {
+			t.Errorf("powerOn %s: expected an error", value)
+		}
+	}
+}
+
+func TestMovieRestoresSettings(t *testing.T) {
+	vm := NewVM()
+	if err := vm.LoadProgramSource("loop: JMP loop"); err != nil {
+		t.Fatal(err)
+	}
+	random := PowerOnState{RAM: FillRandom, Seed: 7}
+	vm.SetRegion(Dendy)
+	vm.SetPowerOnState(random)
+
+	for _, test := range []struct {
+		name   string
+		pal    bool
+		region Region
+		stop   bool
+	}{
+		{"NTSC movie played back", false, NTSC, false},
+		{"PAL movie stopped", true, PAL, true},
+	} {
+		vm.PlayMovie(&Movie{PAL: test.pal, PowerOn: &PowerOnState{}, Frames: make([]MovieFrame, 2)})
+		if vm.Region() != test.region || vm.PowerOnState() != (PowerOnState{}) {
+			t.Errorf("%s: got region %s and power-on state %+v during playback", test.name, vm.Region().ToString(), vm.PowerOnState())
+		}
+		if test.stop {
+			vm.StopMovie()
+		}
+		for vm.MoviePlaying() {
+			vm.StepFrame()
+		}
+		if vm.Region() != Dendy || vm.PowerOnState() != random {
+			t.Errorf("%s: got region %s and power-on state %+v, expected those before playback", test.name, vm.Region().ToString(), vm.PowerOnState())
+		}
+	}
+}
+
+func TestFM2(t *testing.T) {
+	const fm2 = "version 3\nemuVersion 22020\nrerecordCount 5\npalFlag 0\nromFilename smb\n" +
+		"romChecksum base64:jjYwGG411HcjG/j9UOVM3Q==\nguid 452DE2C3-EF43-2FA9-77AC-0677FC51543B\n" +
+		"fourscore 0\nmicrophone 0\nport0 1\nport1 1\nport2 0\nFDS 0\nNewPPU 1\ncomment author someone\n" +
+		"|1|........|........||\n|0|R......A|.L..T.B.||\n|0|...U.S..|||\n"
+
+	movie, err := ReadFM2(strings.NewReader(fm2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.ROMName != "smb" || movie.RerecordCount != 5 || len(movie.Comments) != 1 || movie.Comments[0] != "author someone" {
+		t.Errorf("got header %+v", movie)
+	}
+	expected := []MovieFrame{
+		{Commands: MovieSoftReset},
+		{Buttons: [4]uint8{ButtonRight | ButtonA, ButtonLeft | ButtonStart | ButtonB}},
+		{Buttons: [4]uint8{ButtonUp | ButtonSelect, 0}},
+	}
+	if len(movie.Frames) != len(expected) {
+		t.Fatalf("got %d frames, expected %d", len(movie.Frames), len(expected))
+	}
+	for i := range expected {
+		if movie.Frames[i] != expected[i] {
+			t.Errorf("frame %d: got %+v, expected %+v", i, movie.Frames[i], expected[i])
+		}
+	}
+
+	var written bytes.Buffer
+	if err := movie.WriteFM2(&written); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(written.String(), "|1|........|........||\n|0|R......A|.L..T.B.||\n|0|...U.S..|........||\n") {
+		t.Errorf("got input log:\n%s", written.String())
+	}
+
+	fourScore, err := ReadFM2(strings.NewReader("version 3\nfourscore 1\n|0|R.......|........|.......A|...U....||\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fourScore.FourScore || fourScore.Frames[0].Buttons != [4]uint8{ButtonRight, 0, ButtonA, ButtonUp} {
+		t.Errorf("got four score movie %+v", fourScore)
+	}
+	written.Reset()
+	if err := fourScore.WriteFM2(&written); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(written.String(), "fourscore 1\n") || !strings.HasSuffix(written.String(), "|0|R.......|........|.......A|...U....||\n") {
+		t.Errorf("got four score movie:\n%s", written.String())
+	}
+
+	if _, err := ReadFM2(strings.NewReader("version 3\nsavestate base64:AAAA\n")); err == nil {
+		t.Error("expected an error for a movie starting from a save state")
+	}
+}
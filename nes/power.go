@@ -0,0 +1,122 @@
+// Power-up state Reference: https://www.nesdev.org/wiki/CPU_power_up_state and https://www.nesdev.org/wiki/PPU_power_up_state
+
+package nes
+
+import "math/rand"
+
+// MemoryFill is the content of a memory at power-on, which is undefined on real hardware.
+type MemoryFill string
+
+const (
+	FillZeros   MemoryFill = "zeros"
+	FillOnes    MemoryFill = "ones"    // $FF
+	FillPattern MemoryFill = "pattern" // $00 $00 $00 $00 $FF $FF $FF $FF repeated, as left by many consoles and FCEUX
+	FillRandom  MemoryFill = "random"  // random, from PowerOnState.Seed
+)
+
+// PowerOnState sets the content of the memories at power-on, see: VM.PowerCycle
+// Some games depend on it, e.g. to seed their random number generator.
+type PowerOnState struct {
+	RAM     MemoryFill `json:"ram"`     // CPU RAM
+	VRAM    MemoryFill `json:"vram"`    // nametables
+	OAM     MemoryFill `json:"oam"`     // sprites
+	Palette MemoryFill `json:"palette"` // palette RAM, only the low 6 bits are kept
+	Seed    int64      `json:"seed"`    // seed of the memories filled with FillRandom
+}
+
+func DefaultPowerOnState() PowerOnState {
+	return PowerOnState{RAM: FillZeros, VRAM: FillZeros, OAM: FillZeros, Palette: FillZeros}
+}
+
+// fill fills the memory with the given pattern, random bytes are taken from the given source.
+func (f MemoryFill) fill(memory []uint8, random *rand.Rand) {
+	for i := range memory {
+		switch f {
+		case FillOnes:
+			memory[i] = 0xFF
+		case FillPattern:
+			if i&0x04 == 0 {
+				memory[i] = 0x00
+			} else {
+				memory[i] = 0xFF
+			}
+		case FillRandom:
+			memory[i] = uint8(random.Intn(0x100))
+		default:
+			memory[i] = 0x00
+		}
+	}
+}
+
+// SetPowerOnState changes the content of the memories at the next PowerCycle.
+func (v *VM) SetPowerOnState(state PowerOnState) {
+	v.bus.powerOnState = state
+}
+
+func (v *VM) PowerOnState() PowerOnState {
+	return v.bus.powerOnState
+}
+
+// PowerCycle turns the console off and on again: the memories are filled as set with SetPowerOnState,
+// and the CPU and PPU start from their power-up state. The cartridge and input devices stay connected.
+// While a movie is recorded, it is recorded as a hard reset in the current frame, see: Reset
+func (v *VM) PowerCycle() {
+	if v.MovieRecording() {
+		frames := v.bus.movie.movie.Frames
+		frames[len(frames)-1].Commands |= MovieHardReset
+	}
+	v.cycleStepping = false
+	v.bus.PowerCycle()
+}
+
+// PowerCycle fills the memories and starts the CPU and PPU from their power-up state.
+// The memories are filled in a fixed order, so that a random fill is the same for the same seed.
+func (b *Bus) PowerCycle() {
+	random := rand.New(rand.NewSource(b.powerOnState.Seed))
+	b.powerOnState.RAM.fill(b.CpuRam[:], random)
+	b.PPU.powerOn(b.powerOnState, random)
+
+	b.masterClock = 0
+	b.ppuClock = 0
+	b.clockCounter = 0
+	b.openBus = 0
+	b.CPU.PowerOn()
+}
+
+// powerOn fills the memories of the PPU, and clears its registers and timing.
+func (p *PPU) powerOn(state PowerOnState, random *rand.Rand) {
+	*p = PPU{
+		Cartridge:    p.Cartridge,
+		timing:       p.timing,
+		colorPalette: p.colorPalette,
+		colorLookup:  p.colorLookup,
+		tablePattern: p.tablePattern,
+	}
+	state.VRAM.fill(p.tableName[0][:], random)
+	state.VRAM.fill(p.tableName[1][:], random)
+	state.OAM.fill(p.ppuOam[:], random)
+	state.Palette.fill(p.tablePalette[:], random)
+	for i := range p.tablePalette {
+		p.tablePalette[i] &= 0x3F
+	}
+
+	// the screen is black until the first frame is drawn
+	for i := range p.frame {
+		p.frame[i] = 0x0F
+	}
+}
+
+// reset applies the effects of the reset button: PPUCTRL, PPUMASK, the scroll and the write latch are cleared,
+// and writes to PPUCTRL, PPUMASK, PPUSCROLL and PPUADDR are ignored until the end of the next vertical blank.
+// The memories, PPUSTATUS, OAMADDR and PPUADDR are kept.
+func (p *PPU) reset() {
+	p.ppuCtrl = 0
+	p.ppuMask = 0
+	p.addressLatch = 0
+	p.tramAddr = 0
+	p.fineScrollX = 0
+	p.ppuDataBuffer = 0
+	p.oddFrame = false
+	p.nmi = false
+	p.ignoreWrites = true
+}
@@ -0,0 +1,97 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPowerOnState(t *testing.T) {
+	vm := NewVM()
+	vm.SetPowerOnState(PowerOnState{RAM: FillPattern, VRAM: FillOnes, OAM: FillOnes, Palette: FillOnes})
+	vm.PowerCycle()
+
+	ram := vm.PeekRAM(0x0000, 0x000F)
+	expected := []uint8{0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF}
+	if !bytes.Equal(ram, expected) {
+		t.Errorf("got RAM % X, expected % X", ram, expected)
+	}
+	if vram := vm.GetPPUNametable(1)[0x3FF]; vram != 0xFF {
+		t.Errorf("got VRAM $%02X, expected $FF", vram)
+	}
+	if oam := vm.bus.PPU.ppuOam[0x80]; oam != 0xFF {
+		t.Errorf("got OAM $%02X, expected $FF", oam)
+	}
+	if palette := vm.bus.PPU.tablePalette[0x1F]; palette != 0x3F {
+		t.Errorf("got palette $%02X, expected $3F", palette)
+	}
+
+	random := PowerOnState{RAM: FillRandom, VRAM: FillRandom, OAM: FillRandom, Palette: FillRandom, Seed: 42}
+	vm.SetPowerOnState(random)
+	vm.PowerCycle()
+	first := vm.PeekRAM(0x0000, 0x07FF)
+	vm.PowerCycle()
+	if second := vm.PeekRAM(0x0000, 0x07FF); !bytes.Equal(first, second) {
+		t.Error("random fill differs between power cycles with the same seed")
+	}
+	random.Seed = 43
+	vm.SetPowerOnState(random)
+	vm.PowerCycle()
+	if third := vm.PeekRAM(0x0000, 0x07FF); bytes.Equal(first, third) {
+		t.Error("random fill is the same for different seeds")
+	}
+}
+
+func TestResetAndPowerCycle(t *testing.T) {
+	vm := NewVM()
+	if err := vm.LoadProgramSource(`
+		.org $0300
+		LDA #$12
+		LDX #$34
+		LDY #$56
+		STA $10
+		LDA #$80
+		STA $2000
+		LDA #$1E
+		STA $2001
+		LDA #$12
+	`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		vm.Step()
+	}
+
+	vm.Reset()
+	cpu := vm.PeekCPU()
+	if cpu.A != 0x12 || cpu.X != 0x34 || cpu.Y != 0x56 || cpu.StackPtr != 0xFA || cpu.P&0x04 == 0 {
+		t.Errorf("after reset got %+v, expected A, X and Y kept, SP $FA and I set", cpu)
+	}
+	if ram := vm.PeekRAM(0x0010, 0x0010)[0]; ram != 0x12 {
+		t.Errorf("after reset got RAM $%02X, expected it to be kept", ram)
+	}
+	ppu := vm.bus.PPU
+	if ppu.ppuCtrl != 0 || ppu.ppuMask != 0 {
+		t.Errorf("after reset got PPUCTRL $%02X PPUMASK $%02X, expected both cleared", uint8(ppu.ppuCtrl), uint8(ppu.ppuMask))
+	}
+	ppu.CpuWrite(0x0001, 0x1E)
+	if ppu.ppuMask != 0 {
+		t.Error("PPUMASK was written before the end of the first vertical blank after reset")
+	}
+	ppu.CpuWrite(0x0003, 0x40)
+	if ppu.oamAddr != 0x40 {
+		t.Error("OAMADDR write was ignored after reset")
+	}
+
+	vm.PowerCycle()
+	cpu = vm.PeekCPU()
+	if cpu.A != 0 || cpu.X != 0 || cpu.Y != 0 || cpu.StackPtr != 0xFD {
+		t.Errorf("after power cycle got %+v, expected cleared registers and SP $FD", cpu)
+	}
+	if ram := vm.PeekRAM(0x0010, 0x0010)[0]; ram != 0x00 {
+		t.Errorf("after power cycle got RAM $%02X, expected it to be cleared", ram)
+	}
+	ppu.CpuWrite(0x0001, 0x1E)
+	if ppu.ppuMask != 0x1E {
+		t.Error("PPUMASK write was ignored after power cycle")
+	}
+}
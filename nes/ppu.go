@@ -57,7 +57,7 @@ type PPU struct {
 
 	// PPU registers
 	ppuCtrl   PpuCtrl   // 0x2000
-	ppuMask   uint8     // 0x2001
+	ppuMask   PpuMask   // 0x2001
 	ppuStatus PpuStatus // 0x2002
 	oamAddr   uint8     // 0x2003
 	oamData   uint8     // 0x2004
@@ -74,128 +74,260 @@ type PPU struct {
 	// PPU helper variables
 	addressLatch  uint8
 	ppuDataBuffer uint8
-	ppuAddress    uint16
-	nmi           bool
-
-	// more PPU helpers
-	vramAddr     uint16
-	tramAddr     uint16
-	fineScrollX  uint8
-	camPositionX uint8
-	camPositionY uint8
-
-	patternShiftHi uint8
-	patternShiftLo uint8
-	paletteShiftHi uint8
-	paletteShiftLo uint8
-
-	spriteActivated   [8]bool
-	spriteShiftHi     [8]uint8
-	spriteShiftLo     [8]uint8
-	spriteAttrInfo    [8]uint8
-	spriteCounters    [8]uint8
-	spriteRenderCount [8]uint8
+	nmi           bool // level of the NMI output, high while in vertical blank with NMI enabled
+
+	// PPU I/O data latch, see: https://www.nesdev.org/wiki/Open_bus_behavior#PPU_open_bus
+	// Each bit decays back to 0 if it has not been refreshed for a while.
+	openBus      uint8
+	openBusStamp [8]uint64 // frame in which each bit was last refreshed
+
+	// Set when PPUSTATUS is read one dot before vertical blank would be set,
+	// which suppresses both the flag and the NMI for that frame.
+	suppressVbl bool
+
+	// Set by a reset until the end of the next vertical blank, while some register writes are ignored, see: reset
+	ignoreWrites bool
+
+	// Loopy registers, see: https://www.nesdev.org/wiki/PPU_scrolling
+	//
+	// yyy NN YYYYY XXXXX
+	// ||| || ||||| +++++-- coarse X scroll
+	// ||| || +++++-------- coarse Y scroll
+	// ||| ++-------------- nametable select
+	// +++----------------- fine Y scroll
+	vramAddr    uint16 // v - current VRAM address
+	tramAddr    uint16 // t - temporary VRAM address, the address of the top left onscreen tile
+	fineScrollX uint8  // x - fine X scroll
+
+	// Background tile data latched during fetches, loaded into the shifters every 8 cycles
+	nextTileId     uint8
+	nextTileAttrib uint8
+	nextTileLsb    uint8
+	nextTileMsb    uint8
+
+	// Background shifters, the hi-byte holds the tile currently being drawn
+	patternShiftHi uint16
+	patternShiftLo uint16
+	paletteShiftHi uint16
+	paletteShiftLo uint16
+
+	// Sprites to be drawn on the current scanline
+	spriteScanline [8]spriteEntry
+	spriteCount    int
+	spriteShiftHi  [8]uint8
+	spriteShiftLo  [8]uint8
+
+	spriteZeroHitPossible   bool
+	spriteZeroBeingRendered bool
+
+	// Frame timing of the current region
+	timing regionTiming
 
 	// ???
 	scanline      int
 	cycle         int
 	frameComplete bool
+	frameCount    uint64
+	oddFrame      bool
+	dotSkipped    bool // whether the odd frame dot skip happened in the current frame
+	burstPhase    int  // colour burst phase (0-2) of the current frame, see: NTSCFilter
 
-	// All possible colors the NES can display
-	colorPalette [0x40]color.Color
+	// All possible colors the NES can display, including the emphasis variants
+	colorPalette Palette
 
-	// Temporary variable??
-	screen [256][240]color.Color
+	// colorLookup maps every frame index to the RGBA bytes of its colour
+	colorLookup [0x200][4]uint8
+
+	// The output frame as row-major frame indices, see: FrameIndices
+	frame [ScreenWidth * ScreenHeight]uint16
+}
+
+const (
+	ScreenWidth  = 256
+	ScreenHeight = 240
+)
+
+// spriteEntry is a single sprite as laid out in OAM, see: https://www.nesdev.org/wiki/PPU_OAM
+type spriteEntry struct {
+	y         uint8 // Y position of top of sprite, minus one
+	id        uint8 // Tile index number
+	attribute uint8 // Palette, priority and flipping
+	x         uint8 // X position of left side of sprite
 }
 
 func NewPPU() *PPU {
-	screen := [256][240]color.Color{}
-	for i := 0; i < 256; i++ {
-		screen[i] = [240]color.Color{}
-		for j := 0; j < 240; j++ {
-			screen[i][j] = color.RGBA{255, 0, 0, 255}
-		}
+	p := &PPU{
+		timing: NTSC.timing(),
 	}
-	return &PPU{
-		screen:       screen,
-		colorPalette: nesColorPalette,
+	p.SetPalette(DefaultPalette())
+	p.powerOn(DefaultPowerOnState(), nil)
+	return p
+}
+
+// SetRegion changes the frame timing of the PPU.
+func (p *PPU) SetRegion(region Region) {
+	p.timing = region.timing()
+	if p.scanline >= p.timing.scanlines {
+		p.scanline = 0
+	}
+}
+
+// prerenderScanline returns the last scanline of the frame, which prepares the first visible scanline.
+func (p *PPU) prerenderScanline() int {
+	return p.timing.scanlines - 1
+}
+
+// SetPalette changes the colours used to convert the frame indices to RGB.
+func (p *PPU) SetPalette(palette *Palette) {
+	p.colorPalette = *palette
+	for i, c := range p.colorPalette {
+		p.colorLookup[i] = [4]uint8{c.R, c.G, c.B, c.A}
 	}
 }
 
 func (p *PPU) CpuRead(addr uint16) uint8 {
-	data := uint8(0x00)
+	// write-only registers return the contents of the I/O latch
+	data := p.openBus
 
 	switch addr {
 	case 0x0000: // Control
 	case 0x0001: // Mask
 	case 0x0002: // Status
-		data = (uint8(p.ppuStatus & 0xE0)) | (p.ppuDataBuffer & 0x1F) // quirk
+		// only the top 3 bits are driven, the rest comes from the latch
+		data = uint8(p.ppuStatus&0xE0) | p.openBus&0x1F
+		p.refreshOpenBus(0xE0, data)
+
+		// race condition with the vertical blank flag being set, see: https://www.nesdev.org/wiki/PPU_frame_timing#VBL_Flag_Timing
+		if p.scanline == p.timing.vblankScanline {
+			if p.cycle == 1 {
+				// reading one dot before the flag is set reads it as clear, and never sets it for this frame
+				p.suppressVbl = true
+			}
+		}
 
-		// reset vertical blank
+		// reset vertical blank, reading on the same dot the flag got set or one later
+		// drops the NMI line before the CPU has seen it go high, suppressing the NMI
 		p.SetVerticalBlank(0)
+		p.nmi = false
 
 		// reset address latch
 		p.addressLatch = 0
 
 	case 0x0003: // OAM Address
 	case 0x0004: // OAM Data
+		data = p.ppuOam[p.oamAddr]
+		if p.oamAddr&0x03 == 0x02 {
+			// unimplemented bits of the sprite attribute byte always read back as 0
+			data &= 0xE3
+		}
+		p.refreshOpenBus(0xFF, data)
 	case 0x0005: // Scroll
 	case 0x0006: // PPU Address
 	case 0x0007: // PPU Data
 		data = p.ppuDataBuffer
-		p.ppuDataBuffer = p.PpuRead(p.ppuAddress)
-
-		// for palette data, don't need to buffer one cycle
-		if p.ppuAddress > 0x3F00 {
-			data = p.ppuDataBuffer
-		}
-
-		// auto-increment based on ctrl address increment flag
-		if p.GetVramAddrIncrement() == 1 {
-			p.ppuAddress += 32
+		p.ppuDataBuffer = p.PpuRead(p.vramAddr)
+
+		// for palette data, don't need to buffer one cycle,
+		// the buffer is instead filled with the nametable data "underneath" the palette
+		if p.vramAddr&0x3FFF >= 0x3F00 {
+			// palette entries are 6 bits wide, the top 2 bits come from the latch
+			data = p.ppuDataBuffer&0x3F | p.openBus&0xC0
+			p.refreshOpenBus(0x3F, data)
+			p.ppuDataBuffer = p.PpuRead(p.vramAddr - 0x1000)
 		} else {
-			p.ppuAddress += 1
+			p.refreshOpenBus(0xFF, data)
 		}
+
+		p.incrementVramAddr()
 	}
 
 	return data
 }
 
+// refreshOpenBus updates the bits of the I/O latch selected by mask with the given data.
+func (p *PPU) refreshOpenBus(mask, data uint8) {
+	p.openBus = p.openBus&^mask | data&mask
+	for i := 0; i < 8; i++ {
+		if mask&(1<<i) > 0 {
+			p.openBusStamp[i] = p.frameCount
+		}
+	}
+}
+
+// openBusDecayFrames is the number of frames (roughly 600ms) after which a bit of the I/O latch decays to 0.
+const openBusDecayFrames = 36
+
+func (p *PPU) decayOpenBus() {
+	for i := 0; i < 8; i++ {
+		if p.frameCount-p.openBusStamp[i] >= openBusDecayFrames {
+			p.openBus &^= 1 << i
+		}
+	}
+}
+
 func (p *PPU) CpuWrite(addr uint16, data uint8) {
+	// any write fills the whole I/O latch
+	p.refreshOpenBus(0xFF, data)
+
+	if p.ignoreWrites && (addr == 0x0000 || addr == 0x0001 || addr == 0x0005 || addr == 0x0006) {
+		return
+	}
+
 	switch addr {
 	case 0x0000: // Control
 		p.ppuCtrl = PpuCtrl(data)
+
+		// enabling NMI while in vertical blank immediately generates an NMI, disabling it drops the line
+		p.nmi = p.GetNmiIndicator() == 1 && p.GetVerticalBlank() == 1
+
+		// t: ...GH.. ........ <- d: ......GH
+		p.tramAddr = p.tramAddr&0xF3FF | uint16(data&0x03)<<10
 	case 0x0001: // Mask
-		p.ppuMask = data
+		p.ppuMask = PpuMask(data)
 	case 0x0002: // Status
 		// you can't write to this register
 	case 0x0003: // OAM Address
+		p.oamAddr = data
 	case 0x0004: // OAM Data
+		p.ppuOam[p.oamAddr] = data
+		p.oamAddr++
 	case 0x0005: // Scroll
 		if p.addressLatch == 0 {
-			p.camPositionX = data
+			// t: ....... ...ABCDE <- d: ABCDE...
+			// x:              FGH <- d: .....FGH
+			p.tramAddr = p.tramAddr&0xFFE0 | uint16(data>>3)
+			p.fineScrollX = data & 0x07
 			p.addressLatch = 1
 		} else {
-			p.camPositionY = data
+			// t: FGH..AB CDE..... <- d: ABCDEFGH
+			p.tramAddr = p.tramAddr&0x8C1F | uint16(data&0x07)<<12 | uint16(data>>3)<<5
 			p.addressLatch = 0
 		}
 	case 0x0006: // PPU Address
 		if p.addressLatch == 0 {
-			p.ppuAddress = p.ppuAddress&0x00FF | (uint16(data) << 8)
+			// t: .CDEFGH ........ <- d: ..CDEFGH
+			p.tramAddr = p.tramAddr&0x00FF | uint16(data&0x3F)<<8
 			p.addressLatch = 1
 		} else {
-			p.ppuAddress = p.ppuAddress&0xFF00 | uint16(data)
+			// t: ....... ABCDEFGH <- d: ABCDEFGH
+			// v: <...all bits...> <- t: <...all bits...>
+			p.tramAddr = p.tramAddr&0xFF00 | uint16(data)
+			p.vramAddr = p.tramAddr
 			p.addressLatch = 0
 		}
 	case 0x0007: // PPU Data
-		p.PpuWrite(p.ppuAddress, data)
+		p.PpuWrite(p.vramAddr, data)
 
-		// auto-increment based on ctrl address increment flag
-		if p.GetVramAddrIncrement() == 1 {
-			p.ppuAddress += 32
-		} else {
-			p.ppuAddress += 1
-		}
+		p.incrementVramAddr()
+	}
+}
+
+// incrementVramAddr auto-increments the VRAM address after a PPUDATA access, based on the ctrl address increment flag.
+func (p *PPU) incrementVramAddr() {
+	if p.GetVramAddrIncrement() == 1 {
+		p.vramAddr += 32
+	} else {
+		p.vramAddr += 1
 	}
 }
 
@@ -203,8 +335,31 @@ func (p *PPU) PpuOamWrite(offset, data uint8) {
 	p.ppuOam[offset] = data
 }
 
+// mirrorNametable returns the index of the physical nametable that the given nametable address maps to.
+func (p *PPU) mirrorNametable(addr uint16) int {
+	quadrant := (addr & 0x0FFF) / 0x0400
+	if p.Cartridge == nil {
+		return int(quadrant & 0x01)
+	}
+	switch p.Cartridge.mirrorMode {
+	case Vertical:
+		// $2000 and $2800 share a table, as do $2400 and $2C00
+		return int(quadrant & 0x01)
+	case Horizontal:
+		// $2000 and $2400 share a table, as do $2800 and $2C00
+		return int(quadrant >> 1)
+	}
+	return 0
+}
+
 func (p *PPU) PpuRead(addr uint16) uint8 {
-	data, ok := p.Cartridge.PpuRead(addr)
+	addr &= 0x3FFF
+
+	var data uint8
+	ok := false
+	if p.Cartridge != nil {
+		data, ok = p.Cartridge.PpuRead(addr)
+	}
 	if !ok {
 		if addr <= 0x0FFF {
 			// pattern table 0
@@ -218,27 +373,7 @@ func (p *PPU) PpuRead(addr uint16) uint8 {
 
 		} else if addr >= 0x2000 && addr <= 0x3EFF {
 			// name tables
-			if p.Cartridge.mirrorMode == Vertical {
-				if addr >= 0x2000 && addr <= 0x23FF {
-					data = p.tableName[0][addr&0x03FF]
-				} else if addr >= 0x2400 && addr <= 0x27FF {
-					data = p.tableName[1][addr&0x03FF]
-				} else if addr >= 0x2800 && addr <= 0x3BFF {
-					data = p.tableName[0][addr&0x03FF]
-				} else if addr >= 0x3C00 && addr <= 0x3EFF {
-					data = p.tableName[1][addr&0x03FF]
-				}
-			} else if p.Cartridge.mirrorMode == Horizontal {
-				if addr >= 0x2000 && addr <= 0x23FF {
-					data = p.tableName[0][addr&0x03FF]
-				} else if addr >= 0x2400 && addr <= 0x27FF {
-					data = p.tableName[0][addr&0x03FF]
-				} else if addr >= 0x2800 && addr <= 0x3BFF {
-					data = p.tableName[1][addr&0x03FF]
-				} else if addr >= 0x3C00 && addr <= 0x3EFF {
-					data = p.tableName[1][addr&0x03FF]
-				}
-			}
+			data = p.tableName[p.mirrorNametable(addr)][addr&0x03FF]
 
 		} else if addr >= 0x3F00 && addr <= 0x3FFF {
 			addr &= 0x001F
@@ -261,7 +396,12 @@ func (p *PPU) PpuRead(addr uint16) uint8 {
 }
 
 func (p *PPU) PpuWrite(addr uint16, data uint8) {
-	ok := p.Cartridge.PpuWrite(addr, data)
+	addr &= 0x3FFF
+
+	ok := false
+	if p.Cartridge != nil {
+		ok = p.Cartridge.PpuWrite(addr, data)
+	}
 	if !ok {
 		if addr <= 0x0FFF {
 			// pattern table 0
@@ -275,27 +415,7 @@ func (p *PPU) PpuWrite(addr uint16, data uint8) {
 
 		} else if addr >= 0x2000 && addr <= 0x3EFF {
 			// name tables
-			if p.Cartridge.mirrorMode == Vertical {
-				if addr >= 0x2000 && addr <= 0x23FF {
-					p.tableName[0][addr&0x03FF] = data
-				} else if addr >= 0x2400 && addr <= 0x27FF {
-					p.tableName[1][addr&0x03FF] = data
-				} else if addr >= 0x2800 && addr <= 0x3BFF {
-					p.tableName[0][addr&0x03FF] = data
-				} else if addr >= 0x3C00 && addr <= 0x3EFF {
-					p.tableName[1][addr&0x03FF] = data
-				}
-			} else if p.Cartridge.mirrorMode == Horizontal {
-				if addr >= 0x2000 && addr <= 0x23FF {
-					p.tableName[0][addr&0x03FF] = data
-				} else if addr >= 0x2400 && addr <= 0x27FF {
-					p.tableName[0][addr&0x03FF] = data
-				} else if addr >= 0x2800 && addr <= 0x3BFF {
-					p.tableName[1][addr&0x03FF] = data
-				} else if addr >= 0x3C00 && addr <= 0x3EFF {
-					p.tableName[1][addr&0x03FF] = data
-				}
-			}
+			p.tableName[p.mirrorNametable(addr)][addr&0x03FF] = data
 
 		} else if addr >= 0x3F00 && addr <= 0x3FFF {
 			addr &= 0x001F
@@ -320,32 +440,92 @@ func (p *PPU) ConnectCartridge(cartridge *Cartridge) {
 	p.Cartridge = cartridge
 }
 
-func (p *PPU) GetScreen() [256][240]color.Color {
-	return p.screen
+// FrameIndices returns the frame buffer, see: VM.FrameIndices
+func (p *PPU) FrameIndices() []uint16 {
+	return p.frame[:]
+}
+
+// FrameRGBA converts the frame buffer into row-major RGBA bytes, see: VM.FrameRGBA
+func (p *PPU) FrameRGBA(dst []byte) {
+	_ = dst[len(p.frame)*4-1] // bounds check hint
+	for i, index := range p.frame {
+		copy(dst[i*4:i*4+4], p.colorLookup[index][:])
+	}
 }
 
 func (p *PPU) GetAttributeTable(tableIndex int) []uint8 {
 	return p.tableName[tableIndex][960:]
 }
 
+// Clock advances the PPU by one dot.
+// Reference: https://www.nesdev.org/wiki/PPU_rendering
 func (p *PPU) Clock() {
 
-	if p.scanline == 261 && p.cycle == 1 {
-		// set vertical blank
-		p.SetVerticalBlank(0)
-	}
+	prerender := p.prerenderScanline()
 
-	if p.scanline == 261 && p.cycle >= 329 && p.cycle <= 336 {
-		p.fetchNextTileData()
-	}
+	if p.scanline == prerender || p.scanline <= 239 {
+
+		if p.scanline == prerender && p.cycle == 1 {
+			// clear vertical blank, sprite zero hit and sprite overflow
+			p.SetVerticalBlank(0)
+			p.SetSpriteZeroHit(0)
+			p.SetSpriteOverflow(0)
+			p.nmi = false
+			p.ignoreWrites = false
+
+			// no sprites are evaluated on the pre-render scanline, so none can show up on scanline 0
+			p.spriteCount = 0
+			p.spriteShiftLo = [8]uint8{}
+			p.spriteShiftHi = [8]uint8{}
+		}
+
+		if (p.cycle >= 2 && p.cycle <= 257) || (p.cycle >= 321 && p.cycle <= 337) {
+			p.updateShifters()
+
+			switch (p.cycle - 1) % 8 {
+			case 0:
+				p.loadBackgroundShifters()
+				p.fetchNametableByte()
+			case 2:
+				p.fetchAttributeByte()
+			case 4:
+				p.nextTileLsb = p.fetchPatternByte(0)
+			case 6:
+				p.nextTileMsb = p.fetchPatternByte(8)
+			case 7:
+				p.incrementScrollX()
+			}
+		}
+
+		if p.cycle == 256 {
+			p.incrementScrollY()
+		}
+
+		if p.cycle == 257 {
+			p.loadBackgroundShifters()
+			p.transferAddressX()
+		}
+
+		// unused nametable fetches at the end of the scanline
+		if p.cycle == 338 || p.cycle == 340 {
+			p.fetchNametableByte()
+		}
+
+		if p.scanline == prerender && p.cycle >= 280 && p.cycle <= 304 {
+			p.transferAddressY()
+		}
 
-	if p.scanline >= 0 && p.scanline <= 239 {
-		if (p.cycle >= 1 && p.cycle <= 256) || (p.cycle >= 329 && p.cycle <= 336) {
-			p.fetchNextTileData()
+		// sprite evaluation and fetches for the next scanline
+		if p.cycle == 257 && p.scanline <= 239 {
+			p.evaluateSprites()
+		}
+
+		if p.cycle == 340 {
+			p.fetchSprites()
 		}
 	}
 
-	if p.scanline == 241 && p.cycle == 1 {
+	if p.scanline == p.timing.vblankScanline && p.cycle == 1 && !p.suppressVbl {
 		// set vertical blank
 		p.SetVerticalBlank(1)
 
@@ -355,202 +535,360 @@ func (p *PPU) Clock() {
 		}
 	}
 
+	if p.scanline <= 239 && p.cycle >= 1 && p.cycle <= 256 {
+		p.frame[p.scanline*ScreenWidth+p.cycle-1] = p.getFrameIndex(p.renderPixel())
+	}
+
 	p.cycle++
+
+	// with rendering enabled, odd NTSC frames skip the last dot of the pre-render scanline
+	if p.scanline == prerender && p.cycle == 340 && p.oddFrame && p.isRenderingEnabled() && p.timing.oddFrameSkip {
+		p.cycle++
+		p.dotSkipped = true
+	}
+
 	if p.cycle > 340 {
 		p.cycle = 0
 		p.scanline++
-		if p.scanline > 261 {
+		if p.scanline > prerender {
 			p.scanline = 0
 			p.frameComplete = true
+			p.frameCount++
+			p.updateBurstPhase()
+			p.oddFrame = !p.oddFrame
+			p.suppressVbl = false
+			p.decayOpenBus()
 		}
 	}
 }
 
-func (p *PPU) fetchNextTileData() {
+// updateBurstPhase advances the colour burst phase by the length of the frame that just ended.
+// Every dot is 8 of the 12 colour phases, so an NTSC frame of 341 * 262 dots shifts the phase by 4 phases (one step),
+// or by 8 phases (two steps) when a dot was skipped.
+func (p *PPU) updateBurstPhase() {
+	frameDots := 341 * p.timing.scanlines
+	if p.dotSkipped {
+		frameDots--
+	}
+	p.burstPhase = (p.burstPhase + frameDots*2) % 3
+	p.dotSkipped = false
+}
+
+// renderPixel combines the background and sprite pixels at the current dot and returns the resulting palette RAM address.
+// It also takes care of detecting sprite zero hits.
+func (p *PPU) renderPixel() uint16 {
 	x := p.cycle - 1
-	y := p.scanline
-	nextX := x + 1
-	nextY := y
 
-	if nextX > 255 {
-		nextX = 0
-		nextY += 1
-		if nextY == 262 {
-			nextY = 0
+	// when rendering is disabled, the backdrop colour is shown, unless the VRAM address
+	// points into palette RAM, in which case that colour is shown instead
+	if !p.isRenderingEnabled() {
+		if p.vramAddr&0x3F00 == 0x3F00 {
+			return p.vramAddr & 0x3FFF
 		}
-		if nextY > 239 {
-			return
-		}
-	}
-
-	if nextX > 255 || nextY > 239 {
-		panic("invalid nextX or nextY")
+		return 0x3F00
 	}
 
-	nextTileX := nextX / 8
-	nextTileY := nextY / 8
+	bgPixel := uint8(0)
+	bgPalette := uint8(0)
+	if p.GetShowBackground() == 1 && (p.GetShowBackgroundLeft() == 1 || x >= 8) {
+		bit := uint16(0x8000) >> p.fineScrollX
 
-	//mustAssertInt(nextTileX, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31)
-	//mustAssertInt(nextTileY, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29)
+		pixelLo := uint8(0)
+		if p.patternShiftLo&bit > 0 {
+			pixelLo = 1
+		}
+		pixelHi := uint8(0)
+		if p.patternShiftHi&bit > 0 {
+			pixelHi = 1
+		}
+		bgPixel = pixelHi<<1 | pixelLo
 
-	nextTilePixelOffsetX := nextX % 8
-	nextTilePixelOffsetY := nextY % 8
+		paletteLo := uint8(0)
+		if p.paletteShiftLo&bit > 0 {
+			paletteLo = 1
+		}
+		paletteHi := uint8(0)
+		if p.paletteShiftHi&bit > 0 {
+			paletteHi = 1
+		}
+		bgPalette = paletteHi<<1 | paletteLo
+	}
 
-	//mustAssertInt(nextTilePixelOffsetX, 0, 1, 2, 3, 4, 5, 6, 7)
-	//mustAssertInt(nextTilePixelOffsetY, 0, 1, 2, 3, 4, 5, 6, 7)
+	fgPixel := uint8(0)
+	fgPalette := uint8(0)
+	fgPriority := false
+	p.spriteZeroBeingRendered = false
+	if p.GetShowSprites() == 1 && (p.GetShowSpritesLeft() == 1 || x >= 8) {
+		// the first non-transparent sprite pixel wins, sprites earlier in OAM have priority
+		for i := 0; i < p.spriteCount; i++ {
+			if p.spriteScanline[i].x != 0 {
+				continue
+			}
 
-	nextTileNametableIndex := uint16(nextTileX) + uint16(nextTileY)*32
-	nextTileNametableByte := p.PpuRead(0x2000 + (0x400 * uint16(p.GetBaseNametable())) + nextTileNametableIndex)
+			pixelLo := (p.spriteShiftLo[i] & 0x80) >> 7
+			pixelHi := (p.spriteShiftHi[i] & 0x80) >> 7
+			fgPixel = pixelHi<<1 | pixelLo
 
-	nextTilePatternTableByteOffset := uint16(nextTileNametableByte) * 16
+			fgPalette = (p.spriteScanline[i].attribute & 0x03) + 0x04
+			fgPriority = p.spriteScanline[i].attribute&0x20 == 0
 
-	if nextTilePatternTableByteOffset > 4080 {
-		panic("invalid nextTilePatternTableByteOffset")
+			if fgPixel != 0 {
+				if i == 0 {
+					p.spriteZeroBeingRendered = true
+				}
+				break
+			}
+		}
 	}
 
-	nextTilePixelByteOffsetLsb := nextTilePatternTableByteOffset + uint16(nextTilePixelOffsetY)
-	nextTilePixelByteOffsetMsb := nextTilePatternTableByteOffset + uint16(nextTilePixelOffsetY) + 8
+	pixel := uint8(0)
+	palette := uint8(0)
+	if bgPixel == 0 && fgPixel == 0 {
+		// backdrop
+	} else if bgPixel == 0 && fgPixel > 0 {
+		pixel, palette = fgPixel, fgPalette
+	} else if bgPixel > 0 && fgPixel == 0 {
+		pixel, palette = bgPixel, bgPalette
+	} else {
+		if fgPriority {
+			pixel, palette = fgPixel, fgPalette
+		} else {
+			pixel, palette = bgPixel, bgPalette
+		}
 
-	if nextTilePixelByteOffsetLsb >= 0x1000 || nextTilePixelByteOffsetMsb >= 0x1000 {
-		panic("invalid next pixel byte offset")
+		// sprite zero hit never happens at x=255, nor in the left 8 pixels if they are being clipped
+		if p.spriteZeroHitPossible && p.spriteZeroBeingRendered && x != 255 {
+			if (p.GetShowBackgroundLeft() == 1 && p.GetShowSpritesLeft() == 1) || x >= 8 {
+				p.SetSpriteZeroHit(1)
+			}
+		}
 	}
 
-	nextTilePixelByteLsb := p.PpuRead(0x1000*uint16(p.GetBackgroundPatternTableAddr()) + nextTilePixelByteOffsetLsb)
-	nextTilePixelByteMsb := p.PpuRead(0x1000*uint16(p.GetBackgroundPatternTableAddr()) + nextTilePixelByteOffsetMsb)
-
-	nextTilePixelBitLsb := (nextTilePixelByteLsb >> (7 - nextTilePixelOffsetX)) & 1
-	nextTilePixelBitMsb := (nextTilePixelByteMsb >> (7 - nextTilePixelOffsetX)) & 1
-
-	nextTilePixelBits := nextTilePixelBitMsb<<1 | nextTilePixelBitLsb
-
-	// attribute table info
-	nextAttrTileX := nextTileX / 4
-	nextAttrTileY := nextTileY / 4
-
-	//mustAssertInt(nextAttrTileX, 0, 1, 2, 3, 4, 5, 6, 7)
-	//mustAssertInt(nextAttrTileY, 0, 1, 2, 3, 4, 5, 6, 7)
-
-	nextAttrTileByteOffset := nextAttrTileX + nextAttrTileY*8
+	return 0x3F00 + uint16(palette)<<2 + uint16(pixel)
+}
 
-	if nextAttrTileByteOffset > 63 {
-		panic("invalid nextAttrTileByteOffset")
+// getFrameIndex looks up the colour index stored at the given palette RAM address, applying the greyscale bit of PPUMASK.
+// The emphasis bits of PPUMASK are stored alongside the colour index in bits 6-8.
+func (p *PPU) getFrameIndex(paletteAddr uint16) uint16 {
+	colorIndex := p.PpuRead(paletteAddr) & 0x3F
+	if p.GetGreyscale() == 1 {
+		colorIndex &= 0x30
 	}
-
-	nextAttrTileByte := p.PpuRead(0x23C0 + uint16(nextAttrTileByteOffset))
-	nextAttrTileInnerOffsetX := (nextTileX % 4) / 2
-	nextAttrTileInnerOffsetY := (nextTileY % 4) / 2
-
-	var nextAttrTileInfo uint8
-	if nextAttrTileInnerOffsetX == 0 && nextAttrTileInnerOffsetY == 0 {
-		nextAttrTileInfo = (nextAttrTileByte >> 0) & 0x3
-	} else if nextAttrTileInnerOffsetX == 1 && nextAttrTileInnerOffsetY == 0 {
-		nextAttrTileInfo = (nextAttrTileByte >> 2) & 0x3
-	} else if nextAttrTileInnerOffsetX == 0 && nextAttrTileInnerOffsetY == 1 {
-		nextAttrTileInfo = (nextAttrTileByte >> 4) & 0x3
-	} else if nextAttrTileInnerOffsetX == 1 && nextAttrTileInnerOffsetY == 1 {
-		nextAttrTileInfo = (nextAttrTileByte >> 6) & 0x3
+	emphasis := p.GetEmphasis()
+	if p.timing.swapEmphasis {
+		// PAL and Dendy PPUs have the red and green emphasis bits the other way around
+		emphasis = emphasis&0x04 | (emphasis&0x01)<<1 | (emphasis&0x02)>>1
 	}
+	return uint16(colorIndex) | uint16(emphasis)<<6
+}
 
-	mustAssert(nextAttrTileInfo, 0, 1, 2, 3)
-
-	nextAttrTileInfoLsb := (nextAttrTileInfo >> 0) & 0x1
-	nextAttrTileInfoMsb := (nextAttrTileInfo >> 1) & 0x1
-
-	nextAttrTileInfo = nextAttrTileInfoMsb<<1 | nextAttrTileInfoLsb
-
-	paletteByteOffset := uint16(nextAttrTileInfo)<<2 | uint16(nextTilePixelBits)
-
-	paletteByteAddr := 0x3F00 + paletteByteOffset
+func (p *PPU) fetchNametableByte() {
+	p.nextTileId = p.PpuRead(0x2000 | (p.vramAddr & 0x0FFF))
+}
 
-	colorIndex := p.PpuRead(paletteByteAddr)
+func (p *PPU) fetchAttributeByte() {
+	v := p.vramAddr
+	attrib := p.PpuRead(0x23C0 | (v & 0x0C00) | ((v >> 4) & 0x38) | ((v >> 2) & 0x07))
 
-	/** sprites **/
+	// each attribute byte covers 4x4 tiles, pick the 2x2 quadrant the current tile belongs to
+	if (v>>5)&0x02 > 0 {
+		attrib >>= 4
+	}
+	if v&0x02 > 0 {
+		attrib >>= 2
+	}
+	p.nextTileAttrib = attrib & 0x03
+}
 
-	if x == 0 {
+// fetchPatternByte reads one bitplane of the current tile row, the offset selects the lo (0) or hi (8) plane.
+func (p *PPU) fetchPatternByte(offset uint16) uint8 {
+	fineY := (p.vramAddr >> 12) & 0x07
+	return p.PpuRead(0x1000*uint16(p.GetBackgroundPatternTableAddr()) + uint16(p.nextTileId)<<4 + fineY + offset)
+}
 
-		p.spriteActivated = [8]bool{false, false, false, false, false, false, false, false}
-		p.spriteShiftLo = [8]uint8{0, 0, 0, 0, 0, 0, 0, 0}
-		p.spriteShiftHi = [8]uint8{0, 0, 0, 0, 0, 0, 0, 0}
-		p.spriteCounters = [8]uint8{255, 255, 255, 255, 255, 255, 255, 255}
-		p.spriteRenderCount = [8]uint8{0, 0, 0, 0, 0, 0, 0, 0}
+func (p *PPU) loadBackgroundShifters() {
+	p.patternShiftLo = p.patternShiftLo&0xFF00 | uint16(p.nextTileLsb)
+	p.patternShiftHi = p.patternShiftHi&0xFF00 | uint16(p.nextTileMsb)
 
-		spriteIndex := 0
+	// the palette is the same for the whole tile, so it is inflated to 8 bits
+	p.paletteShiftLo &= 0xFF00
+	if p.nextTileAttrib&0x01 > 0 {
+		p.paletteShiftLo |= 0x00FF
+	}
+	p.paletteShiftHi &= 0xFF00
+	if p.nextTileAttrib&0x02 > 0 {
+		p.paletteShiftHi |= 0x00FF
+	}
+}
 
-		for i := 0; i < 256; i += 4 {
-			spritePositionY := p.ppuOam[i] - 1
-			spriteTileIndexNumber := p.ppuOam[i+1]
-			spriteTileAttributes := p.ppuOam[i+2]
-			spritePositionX := p.ppuOam[i+3]
+// updateShifters shifts the background and sprite shift registers, which run whenever rendering is enabled,
+// even if the background or the sprites are hidden, see: renderPixel
+func (p *PPU) updateShifters() {
+	if !p.isRenderingEnabled() {
+		return
+	}
+	p.patternShiftLo <<= 1
+	p.patternShiftHi <<= 1
+	p.paletteShiftLo <<= 1
+	p.paletteShiftHi <<= 1
+
+	if p.cycle >= 2 && p.cycle <= 257 {
+		for i := 0; i < p.spriteCount; i++ {
+			if p.spriteScanline[i].x > 0 {
+				p.spriteScanline[i].x--
+			} else {
+				p.spriteShiftLo[i] <<= 1
+				p.spriteShiftHi[i] <<= 1
+			}
+		}
+	}
+}
 
-			//fmt.Printf("row %d: sprite pos (%d, %d), sprite index (%d), sprite attr (%d)\n", y, spritePositionX, spritePositionY, spriteTileIndexNumber, spriteTileAttributes)
+// incrementScrollX moves the VRAM address to the next tile horizontally, wrapping into the neighbouring nametable.
+func (p *PPU) incrementScrollX() {
+	if !p.isRenderingEnabled() {
+		return
+	}
+	if p.vramAddr&0x001F == 31 {
+		p.vramAddr &= ^uint16(0x001F)
+		p.vramAddr ^= 0x0400
+	} else {
+		p.vramAddr++
+	}
+}
 
-			if uint8(y) >= spritePositionY && uint8(y) <= spritePositionY+7 {
-				spritePixelRowOffsetFromTop := uint8(y) - spritePositionY
+// incrementScrollY moves the VRAM address to the next pixel row, wrapping into the neighbouring nametable.
+func (p *PPU) incrementScrollY() {
+	if !p.isRenderingEnabled() {
+		return
+	}
+	if p.vramAddr&0x7000 != 0x7000 {
+		// increment fine Y
+		p.vramAddr += 0x1000
+		return
+	}
 
-				spritePatternTableByteOffset := uint16(spriteTileIndexNumber) * 16
+	p.vramAddr &= ^uint16(0x7000)
+	coarseY := (p.vramAddr & 0x03E0) >> 5
+	if coarseY == 29 {
+		// the last row of tiles, the rest of the nametable is attribute memory
+		coarseY = 0
+		p.vramAddr ^= 0x0800
+	} else if coarseY == 31 {
+		// out of bounds, wraps without switching nametable
+		coarseY = 0
+	} else {
+		coarseY++
+	}
+	p.vramAddr = p.vramAddr&^0x03E0 | coarseY<<5
+}
 
-				if spritePatternTableByteOffset > 4080 {
-					panic("invalid spritePatternTableByteOffset")
-				}
+// transferAddressX copies the horizontal scroll bits from t to v.
+func (p *PPU) transferAddressX() {
+	if !p.isRenderingEnabled() {
+		return
+	}
+	// v: ....A.. ...BCDEF <- t: ....A.. ...BCDEF
+	p.vramAddr = p.vramAddr&0xFBE0 | p.tramAddr&0x041F
+}
 
-				spritePixelByteOffsetLsb := spritePatternTableByteOffset + uint16(spritePixelRowOffsetFromTop)
-				spritePixelByteOffsetMsb := spritePatternTableByteOffset + uint16(spritePixelRowOffsetFromTop) + 8
+// transferAddressY copies the vertical scroll bits from t to v.
+func (p *PPU) transferAddressY() {
+	if !p.isRenderingEnabled() {
+		return
+	}
+	// v: GHIA.BC DEF..... <- t: GHIA.BC DEF.....
+	p.vramAddr = p.vramAddr&0x841F | p.tramAddr&0x7BE0
+}
 
-				if spritePixelByteOffsetLsb >= 0x1000 || spritePixelByteOffsetMsb >= 0x1000 {
-					panic("invalid next sprite pixel byte offset")
-				}
+// evaluateSprites finds the (up to 8) sprites that are visible on the next scanline.
+func (p *PPU) evaluateSprites() {
+	p.spriteScanline = [8]spriteEntry{}
+	p.spriteCount = 0
+	p.spriteZeroHitPossible = false
 
-				p.spriteShiftLo[spriteIndex] = p.PpuRead(0x1000*uint16(p.GetSpritePatternTableAddr()) + spritePixelByteOffsetLsb)
-				p.spriteShiftHi[spriteIndex] = p.PpuRead(0x1000*uint16(p.GetSpritePatternTableAddr()) + spritePixelByteOffsetMsb)
-				p.spriteAttrInfo[spriteIndex] = spriteTileAttributes
-				p.spriteCounters[spriteIndex] = spritePositionX + 1
+	if !p.isRenderingEnabled() {
+		return
+	}
 
-				spriteIndex++
-			}
+	height := 8
+	if p.GetSpriteSize() == 1 {
+		height = 16
+	}
 
-			if spriteIndex > 7 {
-				break
-			}
+	for i := 0; i < 64; i++ {
+		entry := spriteEntry{
+			y:         p.ppuOam[i*4],
+			id:        p.ppuOam[i*4+1],
+			attribute: p.ppuOam[i*4+2],
+			x:         p.ppuOam[i*4+3],
 		}
-	}
 
-	done := false
-	for i := 0; i < 8; i++ {
-		if p.spriteCounters[i] == 255 {
+		diff := p.scanline - int(entry.y)
+		if diff < 0 || diff >= height {
 			continue
 		}
 
-		if p.spriteCounters[i] != 0 {
-			p.spriteCounters[i]--
+		if p.spriteCount == 8 {
+			p.SetSpriteOverflow(1)
+			break
 		}
 
-		if p.spriteCounters[i] <= 0 {
-			p.spriteActivated[i] = true
+		if i == 0 {
+			p.spriteZeroHitPossible = true
 		}
+		p.spriteScanline[p.spriteCount] = entry
+		p.spriteCount++
+	}
+}
 
-		if !done && p.spriteActivated[i] && p.spriteRenderCount[i] < 8 {
-			spritePixelBitLsb := (p.spriteShiftLo[i] & 0x80) >> 7
-			spritePixelBitMsb := (p.spriteShiftHi[i] & 0x80) >> 7
-			p.spriteShiftLo[i] <<= 1
-			p.spriteShiftHi[i] <<= 1
-
-			spritePixelBits := spritePixelBitMsb<<1 | spritePixelBitLsb
-
-			spritePaletteByteOffset := uint16(p.spriteAttrInfo[i])<<2 | uint16(spritePixelBits)
-			spritePaletteByteAddr := 0x3F10 + spritePaletteByteOffset
-			colorIndex = p.PpuRead(spritePaletteByteAddr)
+// fetchSprites loads the pattern data of the sprites found by evaluateSprites into the sprite shifters.
+func (p *PPU) fetchSprites() {
+	for i := 0; i < p.spriteCount; i++ {
+		sprite := p.spriteScanline[i]
+		row := uint16(p.scanline - int(sprite.y))
+		flipVertical := sprite.attribute&0x80 > 0
+		flipHorizontal := sprite.attribute&0x40 > 0
+
+		var addr uint16
+		if p.GetSpriteSize() == 0 {
+			// 8x8 sprites, the pattern table is selected by PPUCTRL
+			if flipVertical {
+				row = 7 - row
+			}
+			addr = 0x1000*uint16(p.GetSpritePatternTableAddr()) + uint16(sprite.id)<<4 + row
+		} else {
+			// 8x16 sprites, the pattern table is selected by bit 0 of the tile index
+			if flipVertical {
+				row = 15 - row
+			}
+			tile := uint16(sprite.id & 0xFE)
+			if row >= 8 {
+				tile++
+				row -= 8
+			}
+			addr = 0x1000*uint16(sprite.id&0x01) + tile<<4 + row
+		}
 
-			done = true
+		lo := p.PpuRead(addr)
+		hi := p.PpuRead(addr + 8)
 
-			p.spriteRenderCount[i]++
+		if flipHorizontal {
+			lo = flipByte(lo)
+			hi = flipByte(hi)
 		}
-	}
 
-	// for each 8 sprite
-	// each cycle, decrement sprite's x position
-	// if sprite x == 0, render the earliest pixel in the 8 pixels shift register, then shift that register
+		p.spriteShiftLo[i] = lo
+		p.spriteShiftHi[i] = hi
+	}
+}
 
-	p.screen[nextX][nextY] = p.colorPalette[colorIndex]
+// flipByte reverses the order of the bits in the given byte.
+func flipByte(b uint8) uint8 {
+	b = (b&0xF0)>>4 | (b&0x0F)<<4
+	b = (b&0xCC)>>2 | (b&0x33)<<2
+	b = (b&0xAA)>>1 | (b&0x55)<<1
+	return b
 }
 
 func (p *PPU) GetPatternTableDisplay(tableIndex, paletteId int) [128][128]color.Color {
@@ -582,7 +920,7 @@ func (p *PPU) GetPatternTableDisplay(tableIndex, paletteId int) [128][128]color.
 
 			colorIndex := p.PpuRead(paletteByteOffset)
 
-			display[i][j] = p.colorPalette[colorIndex]
+			display[i][j] = p.colorPalette[colorIndex&0x3F]
 		}
 	}
 	return display
@@ -596,7 +934,7 @@ func (p *PPU) GetPaletteDisplay() [32]color.Color {
 			paletteByteOffset := 0x3F00 + (uint16(paletteId)<<2+uint16(pixel))&0x3F
 			colorIndex := p.PpuRead(paletteByteOffset)
 
-			display[paletteId*4+pixel] = p.colorPalette[colorIndex]
+			display[paletteId*4+pixel] = p.colorPalette[colorIndex&0x3F]
 		}
 	}
 
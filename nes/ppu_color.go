@@ -2,9 +2,35 @@ package nes
 
 import "image/color"
 
-var nesColorPalette = [0x40]color.Color{
+var nesColorPalette = [0x40]color.RGBA{
 	color.RGBA{R: 84, G: 84, B: 84, A: 255}, color.RGBA{R: 0, G: 30, B: 116, A: 255}, color.RGBA{R: 8, G: 16, B: 144, A: 255}, color.RGBA{R: 48, G: 0, B: 136, A: 255}, color.RGBA{R: 68, G: 0, B: 100, A: 255}, color.RGBA{R: 92, G: 0, B: 48, A: 255}, color.RGBA{R: 84, G: 4, B: 0, A: 255}, color.RGBA{R: 60, G: 24, B: 0, A: 255}, color.RGBA{R: 32, G: 42, B: 0, A: 255}, color.RGBA{R: 8, G: 58, B: 0, A: 255}, color.RGBA{R: 0, G: 64, B: 0, A: 255}, color.RGBA{R: 0, G: 60, B: 0, A: 255}, color.RGBA{R: 0, G: 50, B: 60, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255},
 	color.RGBA{R: 152, G: 150, B: 152, A: 255}, color.RGBA{R: 8, G: 76, B: 196, A: 255}, color.RGBA{R: 48, G: 50, B: 236, A: 255}, color.RGBA{R: 92, G: 30, B: 228, A: 255}, color.RGBA{R: 136, G: 20, B: 176, A: 255}, color.RGBA{R: 160, G: 20, B: 100, A: 255}, color.RGBA{R: 152, G: 34, B: 32, A: 255}, color.RGBA{R: 120, G: 60, B: 0, A: 255}, color.RGBA{R: 84, G: 90, B: 0, A: 255}, color.RGBA{R: 40, G: 114, B: 0, A: 255}, color.RGBA{R: 8, G: 124, B: 0, A: 255}, color.RGBA{R: 0, G: 118, B: 40, A: 255}, color.RGBA{R: 0, G: 102, B: 120, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255},
 	color.RGBA{R: 236, G: 238, B: 236, A: 255}, color.RGBA{R: 76, G: 154, B: 236, A: 255}, color.RGBA{R: 120, G: 124, B: 236, A: 255}, color.RGBA{R: 176, G: 98, B: 236, A: 255}, color.RGBA{R: 228, G: 84, B: 236, A: 255}, color.RGBA{R: 236, G: 88, B: 180, A: 255}, color.RGBA{R: 236, G: 106, B: 100, A: 255}, color.RGBA{R: 212, G: 136, B: 32, A: 255}, color.RGBA{R: 160, G: 170, B: 0, A: 255}, color.RGBA{R: 116, G: 196, B: 0, A: 255}, color.RGBA{R: 76, G: 208, B: 32, A: 255}, color.RGBA{R: 56, G: 204, B: 108, A: 255}, color.RGBA{R: 56, G: 180, B: 204, A: 255}, color.RGBA{R: 60, G: 60, B: 60, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255},
 	color.RGBA{R: 236, G: 238, B: 236, A: 255}, color.RGBA{R: 168, G: 204, B: 236, A: 255}, color.RGBA{R: 188, G: 188, B: 236, A: 255}, color.RGBA{R: 212, G: 178, B: 236, A: 255}, color.RGBA{R: 236, G: 174, B: 236, A: 255}, color.RGBA{R: 236, G: 174, B: 212, A: 255}, color.RGBA{R: 236, G: 180, B: 176, A: 255}, color.RGBA{R: 228, G: 196, B: 144, A: 255}, color.RGBA{R: 204, G: 210, B: 120, A: 255}, color.RGBA{R: 180, G: 222, B: 120, A: 255}, color.RGBA{R: 168, G: 226, B: 144, A: 255}, color.RGBA{R: 152, G: 226, B: 180, A: 255}, color.RGBA{R: 160, G: 214, B: 228, A: 255}, color.RGBA{R: 160, G: 162, B: 160, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255}, color.RGBA{R: 0, G: 0, B: 0, A: 255},
 }
+
+// emphasisAttenuation is how much the channels that are not being emphasised are darkened by.
+// Reference: https://www.nesdev.org/wiki/NTSC_video#Color_Tint_Bits
+const emphasisAttenuation = 0.816328
+
+// emphasise applies the 3 PPUMASK emphasis bits (BGR) to the given colour.
+// Emphasising a channel is achieved by attenuating the other channels.
+func emphasise(c color.RGBA, emphasis uint8) color.RGBA {
+	if emphasis == 0 {
+		return c
+	}
+	r, g, b := float64(c.R), float64(c.G), float64(c.B)
+	if emphasis&0x01 > 0 {
+		g *= emphasisAttenuation
+		b *= emphasisAttenuation
+	}
+	if emphasis&0x02 > 0 {
+		r *= emphasisAttenuation
+		b *= emphasisAttenuation
+	}
+	if emphasis&0x04 > 0 {
+		r *= emphasisAttenuation
+		g *= emphasisAttenuation
+	}
+	return color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: c.A}
+}
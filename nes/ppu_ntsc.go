@@ -0,0 +1,223 @@
+// NTSC video filter, in the spirit of blargg's nes_ntsc: http://slack.net/~ant/libs/ntsc.html
+// Signal Reference: https://www.nesdev.org/wiki/NTSC_video
+
+package nes
+
+import "math"
+
+const (
+	// NTSCOutputWidth is the width of the images produced by the NTSC filter, the height is unchanged.
+	// The image is roughly 2.35x wider than the PPU output, each pixel being made of 8 signal samples.
+	NTSCOutputWidth = 602
+
+	ntscSamplesPerPixel = 8 // the PPU outputs 8 of the 12 colour phases per pixel
+	ntscLineSamples     = ScreenWidth * ntscSamplesPerPixel
+
+	// the edge pixels of a scanline are repeated on both sides, so that the chroma filters have samples to read
+	ntscPadPixels     = 3
+	ntscPaddedSamples = (ScreenWidth + 2*ntscPadPixels) * ntscSamplesPerPixel
+)
+
+// NTSCSetup describes how the composite signal is decoded by the NTSC filter.
+type NTSCSetup struct {
+	PaletteParams
+
+	Sharpness  float64 // Edge enhancement, -1 (blurry) to 1 (sharp)
+	Resolution float64 // Luma bandwidth, -1 (blurry) to 1 (sharp)
+	Artifacts  float64 // How much of the colour subcarrier leaks into the luma, 0 (none) to 1 (full)
+	Fringing   float64 // How much of the luma detail leaks into the colour, 0 (none) to 1 (full)
+	Bleed      float64 // Colour bandwidth, -1 (sharp) to 1 (blurry)
+}
+
+func ntscPreset(params PaletteParams, sharpness, resolution, artifacts, fringing, bleed float64) NTSCSetup {
+	return NTSCSetup{
+		PaletteParams: params,
+		Sharpness:     sharpness,
+		Resolution:    resolution,
+		Artifacts:     artifacts,
+		Fringing:      fringing,
+		Bleed:         bleed,
+	}
+}
+
+// NTSC filter presets, matching the common connections between a NES and a television.
+var (
+	NTSCComposite  = ntscPreset(DefaultPaletteParams(), 0, 0, 1, 1, 0)
+	NTSCSVideo     = ntscPreset(DefaultPaletteParams(), 0.2, 0.2, 0, 0, 0)
+	NTSCRGB        = ntscPreset(DefaultPaletteParams(), 0.2, 0.7, 0, 0, -1)
+	NTSCMonochrome = ntscPreset(PaletteParams{Saturation: 0, Contrast: 1, Gamma: 1.8}, 0.2, 0.2, 1, 0, 0)
+)
+
+// NTSCFilter turns frame indices into an image that looks like a television picture,
+// by generating the composite signal of the PPU and decoding it again:
+// the luma is low-passed from the signal, and I and Q are demodulated from it with the colour carrier.
+// It runs entirely on the CPU.
+type NTSCFilter struct {
+	setup NTSCSetup
+
+	lumaWidth   int // width of the luma low-pass filter, in samples
+	chromaWidth int // width of the chroma low-pass filter, in samples
+
+	// composite signal of each frame index at each colour phase
+	signal [0x200][12]float64
+
+	// luma of each frame index, the average of its signal over a colour cycle
+	indexY [0x200]float64
+
+	// carrier of each colour phase
+	cos [12]float64
+	sin [12]float64
+
+	// gammaLookup maps a linear level in [0, 2) to a gamma-corrected 8-bit value
+	gammaLookup [2048]uint8
+
+	// prefix sums of the per-sample luma and products with the carrier of the current scanline,
+	// and of the products averaged over a colour cycle
+	sumY      [ntscPaddedSamples + 1]float64
+	sumI      [ntscPaddedSamples + 1]float64
+	sumQ      [ntscPaddedSamples + 1]float64
+	sumCycleI [ntscPaddedSamples + 1]float64
+	sumCycleQ [ntscPaddedSamples + 1]float64
+	rowY      [NTSCOutputWidth]float64
+	rowI      [NTSCOutputWidth]float64
+	rowQ      [NTSCOutputWidth]float64
+}
+
+func NewNTSCFilter(setup NTSCSetup) *NTSCFilter {
+	f := &NTSCFilter{setup: setup}
+
+	f.lumaWidth = int(math.Round(8 - 7*clamp(setup.Resolution, -1, 1)))
+	f.chromaWidth = int(math.Round(12 + 11*clamp(setup.Bleed, -1, 1)))
+
+	hue := setup.Hue / 30
+	for phase := 0; phase < 12; phase++ {
+		angle := math.Pi / 6 * (float64(phase) + ntscHuePhase + hue)
+		f.cos[phase] = math.Cos(angle)
+		f.sin[phase] = math.Sin(angle)
+	}
+
+	for index := range f.indexY {
+		for phase := 0; phase < 12; phase++ {
+			f.signal[index][phase] = ntscSignal(uint16(index), phase)
+			f.indexY[index] += f.signal[index][phase] / 12
+		}
+	}
+
+	for i := range f.gammaLookup {
+		level := float64(i) / 1024
+		f.gammaLookup[i] = uint8(math.Min(math.Pow(level, 2.2/setup.Gamma)*255+0.5, 255))
+	}
+
+	return f
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// Filter decodes the given ScreenWidth x ScreenHeight frame indices into dst,
+// which must be at least NTSCOutputWidth * ScreenHeight * 4 bytes of row-major RGBA.
+// burstPhase (0-2) is the colour burst phase of the frame, see: VM.FrameBurstPhase
+func (f *NTSCFilter) Filter(indices []uint16, burstPhase int, dst []byte) {
+	_ = dst[NTSCOutputWidth*ScreenHeight*4-1] // bounds check hint
+	for y := 0; y < ScreenHeight; y++ {
+		// each scanline is 341 dots of 8 samples, shifting the colour phase by 4 samples every line
+		linePhase := (burstPhase*4 + y*4) % 12
+		f.filterLine(indices[y*ScreenWidth:(y+1)*ScreenWidth], linePhase, dst[y*NTSCOutputWidth*4:(y+1)*NTSCOutputWidth*4])
+	}
+}
+
+func (f *NTSCFilter) filterLine(line []uint16, linePhase int, dst []byte) {
+	artifacts := clamp(f.setup.Artifacts, 0, 1)
+	fringing := clamp(f.setup.Fringing, 0, 1)
+
+	// build the signals and their prefix sums, so that the box filters below are O(1) per output pixel
+	for x := -ntscPadPixels; x < ScreenWidth+ntscPadPixels; x++ {
+		index := line[clampPixel(x)] & 0x1FF
+		luma := f.indexY[index]
+
+		phase := ((linePhase+x*ntscSamplesPerPixel)%12 + 12) % 12
+		start := (x + ntscPadPixels) * ntscSamplesPerPixel
+		for k := start; k < start+ntscSamplesPerPixel; k++ {
+			signal := f.signal[index][phase]
+			// the part of the signal that is not the pixel's luma is the colour subcarrier
+			f.sumY[k+1] = f.sumY[k] + luma + artifacts*(signal-luma)
+
+			// luma that is flat over a colour cycle cancels out of the products with the carrier,
+			// the luma detail between pixels does not, and is decoded as colour fringes
+			chroma := signal - (1-fringing)*luma
+			f.sumI[k+1] = f.sumI[k] + chroma*f.cos[phase]
+			f.sumQ[k+1] = f.sumQ[k] + chroma*f.sin[phase]
+
+			phase++
+			if phase == 12 {
+				phase = 0
+			}
+		}
+	}
+
+	// averaging the products over a colour cycle demodulates I and Q
+	for k := 0; k < ntscPaddedSamples; k++ {
+		f.sumCycleI[k+1] = f.sumCycleI[k] + boxFilter(f.sumI[:], float64(k), 12)
+		f.sumCycleQ[k+1] = f.sumCycleQ[k] + boxFilter(f.sumQ[:], float64(k), 12)
+	}
+
+	for x := 0; x < NTSCOutputWidth; x++ {
+		center := ntscPadPixels*ntscSamplesPerPixel + (float64(x)+0.5)*ntscLineSamples/NTSCOutputWidth
+		f.rowY[x] = boxFilter(f.sumY[:], center, f.lumaWidth)
+		f.rowI[x] = boxFilter(f.sumCycleI[:], center, f.chromaWidth)
+		f.rowQ[x] = boxFilter(f.sumCycleQ[:], center, f.chromaWidth)
+	}
+
+	params := f.setup.PaletteParams
+	sharpness := clamp(f.setup.Sharpness, -1, 1)
+	for x := 0; x < NTSCOutputWidth; x++ {
+		y := f.rowY[x]
+		if x > 0 && x < NTSCOutputWidth-1 {
+			y += sharpness * (y - (f.rowY[x-1]+f.rowY[x+1])/2)
+		}
+		y = y*params.Contrast + params.Brightness
+		i := f.rowI[x] * params.Saturation
+		q := f.rowQ[x] * params.Saturation
+
+		dst[x*4+0] = f.gammaFix(y + 0.946882*i + 0.623557*q)
+		dst[x*4+1] = f.gammaFix(y - 0.274788*i - 0.635691*q)
+		dst[x*4+2] = f.gammaFix(y - 1.108545*i + 1.709007*q)
+		dst[x*4+3] = 255
+	}
+}
+
+// clampPixel returns the pixel of the scanline nearest to x, repeating the edge pixels.
+func clampPixel(x int) int {
+	if x < 0 {
+		return 0
+	}
+	if x >= ScreenWidth {
+		return ScreenWidth - 1
+	}
+	return x
+}
+
+func (f *NTSCFilter) gammaFix(level float64) uint8 {
+	if level <= 0 {
+		return 0
+	}
+	i := int(level * 1024)
+	if i >= len(f.gammaLookup) {
+		return 255
+	}
+	return f.gammaLookup[i]
+}
+
+// boxFilter returns the average of the samples within width/2 of center, using the prefix sums of the samples.
+func boxFilter(sums []float64, center float64, width int) float64 {
+	lo := int(center) - width/2
+	hi := lo + width
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > len(sums)-1 {
+		hi = len(sums) - 1
+	}
+	return (sums[hi] - sums[lo]) / float64(hi-lo)
+}
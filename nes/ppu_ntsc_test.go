@@ -0,0 +1,82 @@
+package nes
+
+import "testing"
+
+// filterFrame runs the given frame indices through a new NTSC filter with the given setup.
+func filterFrame(setup NTSCSetup, indices []uint16, burstPhase int) []byte {
+	dst := make([]byte, NTSCOutputWidth*ScreenHeight*4)
+	NewNTSCFilter(setup).Filter(indices, burstPhase, dst)
+	return dst
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestNTSCFilterFlatField(t *testing.T) {
+	palette := GeneratePalette(NTSCRGB.PaletteParams)
+	indices := make([]uint16, ScreenWidth*ScreenHeight)
+	// the RGB preset has no artifacts, so a flat field decodes to the colour of the generated palette
+	for _, index := range []uint16{0x00, 0x0F, 0x16, 0x1A, 0x21, 0x30, 0x2C, 0xD6} {
+		for i := range indices {
+			indices[i] = index
+		}
+		for burstPhase := 0; burstPhase < 3; burstPhase++ {
+			dst := filterFrame(NTSCRGB, indices, burstPhase)
+			expected := palette[index]
+			for p := 0; p < len(dst); p += 4 {
+				// the gamma lookup of the filter is slightly coarser than that of the palette
+				if absDiff(dst[p], expected.R) > 1 || absDiff(dst[p+1], expected.G) > 1 || absDiff(dst[p+2], expected.B) > 1 || dst[p+3] != 0xFF {
+					t.Errorf("colour $%03X, phase %d: got %v at pixel %d, expected %v", index, burstPhase, dst[p:p+4], p/4, expected)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestNTSCFilterMonochrome(t *testing.T) {
+	// vertical stripes of changing colours, which produce artifacts in the luma
+	indices := make([]uint16, ScreenWidth*ScreenHeight)
+	for i := range indices {
+		x, y := i%ScreenWidth, i/ScreenWidth
+		indices[i] = uint16(x/2+y) & 0x1FF
+	}
+	for burstPhase := 0; burstPhase < 3; burstPhase++ {
+		dst := filterFrame(NTSCMonochrome, indices, burstPhase)
+		for p := 0; p < len(dst); p += 4 {
+			if dst[p] != dst[p+1] || dst[p+1] != dst[p+2] {
+				t.Fatalf("phase %d: got %v at pixel %d, expected a grey", burstPhase, dst[p:p+4], p/4)
+			}
+		}
+	}
+}
+
+func TestNTSCFilterFringing(t *testing.T) {
+	// vertical stripes of black and white, which have no colour of their own
+	indices := make([]uint16, ScreenWidth*ScreenHeight)
+	for i := range indices {
+		indices[i] = 0x0F
+		if i%ScreenWidth/2%2 == 0 {
+			indices[i] = 0x30
+		}
+	}
+	isGrey := func(dst []byte) bool {
+		for p := 0; p < len(dst); p += 4 {
+			if dst[p] != dst[p+1] || dst[p+1] != dst[p+2] {
+				return false
+			}
+		}
+		return true
+	}
+	// the luma edges are demodulated as colour with fringing, and cancel out without it
+	if isGrey(filterFrame(NTSCComposite, indices, 0)) {
+		t.Error("composite: expected colour fringes on the edges")
+	}
+	if !isGrey(filterFrame(NTSCSVideo, indices, 0)) {
+		t.Error("S-Video: expected only greys")
+	}
+}
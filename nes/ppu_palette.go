@@ -0,0 +1,176 @@
+// Palette Reference: https://www.nesdev.org/wiki/PPU_palettes
+// NTSC Signal Reference: https://www.nesdev.org/wiki/NTSC_video
+
+package nes
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+)
+
+// Palette maps every frame index to a colour.
+// The low 6 bits of a frame index are the NES colour index, and bits 6-8 are the PPUMASK emphasis bits.
+type Palette [0x200]color.RGBA
+
+// DefaultPalette returns the built-in palette, with the emphasis variants derived from it.
+func DefaultPalette() *Palette {
+	return expandPalette(nesColorPalette)
+}
+
+// expandPalette derives the emphasis variants of the given 64 colours.
+func expandPalette(base [0x40]color.RGBA) *Palette {
+	palette := &Palette{}
+	for i := range palette {
+		palette[i] = emphasise(base[i&0x3F], uint8(i>>6))
+	}
+	return palette
+}
+
+// LoadPalette reads a .pal file, see: ParsePalette
+func LoadPalette(filePath string) (*Palette, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading palette file: %v", err)
+	}
+	return ParsePalette(data)
+}
+
+// ParsePalette parses the contents of a .pal file, which is a list of RGB triplets.
+// A 64 entry file (192 bytes) only holds the base colours and has its emphasis variants derived,
+// while a 512 entry file (1536 bytes) also holds the colours for each of the 7 emphasis combinations.
+func ParsePalette(data []byte) (*Palette, error) {
+	switch len(data) {
+	case 0x40 * 3:
+		base := [0x40]color.RGBA{}
+		for i := range base {
+			base[i] = color.RGBA{R: data[i*3], G: data[i*3+1], B: data[i*3+2], A: 255}
+		}
+		return expandPalette(base), nil
+	case 0x200 * 3:
+		palette := &Palette{}
+		for i := range palette {
+			palette[i] = color.RGBA{R: data[i*3], G: data[i*3+1], B: data[i*3+2], A: 255}
+		}
+		return palette, nil
+	}
+	return nil, fmt.Errorf("unexpected palette size (%v bytes), expected 192 or 1536 bytes", len(data))
+}
+
+// PaletteParams are the knobs of the NTSC palette generator.
+type PaletteParams struct {
+	Hue        float64 `json:"hue"`        // Hue rotation in degrees, 0 is neutral
+	Saturation float64 `json:"saturation"` // Multiplier of the chroma, 1 is neutral
+	Contrast   float64 `json:"contrast"`   // Multiplier of the luma, 1 is neutral
+	Brightness float64 `json:"brightness"` // Offset added to the luma, 0 is neutral
+	Gamma      float64 `json:"gamma"`      // Gamma of the source signal, the output is corrected for a 2.2 display
+}
+
+func DefaultPaletteParams() PaletteParams {
+	return PaletteParams{
+		Hue:        0,
+		Saturation: 1,
+		Contrast:   1,
+		Brightness: 0,
+		Gamma:      1.8,
+	}
+}
+
+// Composite video signal levels of the 2C02, normalised so that the colour burst is 0.286V.
+// The first 4 are the low levels of each luma row, the last 4 are the high levels.
+var ntscSignalLevels = [8]float64{0.350, 0.518, 0.962, 1.550, 1.094, 1.506, 1.962, 1.962}
+
+const (
+	ntscBlack       = 0.518
+	ntscWhite       = 1.962
+	ntscAttenuation = 0.746 // signal multiplier of the emphasis bits
+
+	// ntscHuePhase aligns the demodulator with the colour burst (hue 8), in units of 30 degrees.
+	ntscHuePhase = 4
+)
+
+// ntscInColorPhase returns true if the square wave of the given hue is high at the given phase (0-11).
+func ntscInColorPhase(hue, phase int) bool {
+	return (hue+phase)%12 < 6
+}
+
+// ntscSignal returns the normalised composite signal level of the given frame index at the given phase (0-11).
+// 0 is black and 1 is white.
+func ntscSignal(index uint16, phase int) float64 {
+	hue := int(index & 0x0F)
+	level := int(index>>4) & 0x03
+	emphasis := index >> 6
+
+	// colours $xE and $xF are forced to black
+	if hue > 13 {
+		level = 1
+	}
+
+	lo := ntscSignalLevels[level]
+	if hue == 0x0 {
+		lo = ntscSignalLevels[level+4]
+	}
+	hi := ntscSignalLevels[level]
+	if hue < 0xD {
+		hi = ntscSignalLevels[level+4]
+	}
+
+	signal := lo
+	if ntscInColorPhase(hue, phase) {
+		signal = hi
+	}
+
+	// each emphasis bit attenuates the signal during a third of the colour cycle
+	if (emphasis&0x01 > 0 && ntscInColorPhase(0, phase)) ||
+		(emphasis&0x02 > 0 && ntscInColorPhase(4, phase)) ||
+		(emphasis&0x04 > 0 && ntscInColorPhase(8, phase)) {
+		signal *= ntscAttenuation
+	}
+
+	return (signal - ntscBlack) / (ntscWhite - ntscBlack)
+}
+
+// yiqToRGBA converts a decoded YIQ colour to RGBA, adjusting it with the given params.
+func yiqToRGBA(y, i, q float64, params PaletteParams) color.RGBA {
+	i *= params.Saturation
+	q *= params.Saturation
+	y = y*params.Contrast + params.Brightness
+
+	gammaFix := func(f float64) uint8 {
+		if f <= 0 {
+			return 0
+		}
+		f = math.Pow(f, 2.2/params.Gamma) * 255
+		if f > 255 {
+			return 255
+		}
+		return uint8(f + 0.5)
+	}
+
+	return color.RGBA{
+		R: gammaFix(y + 0.946882*i + 0.623557*q),
+		G: gammaFix(y - 0.274788*i - 0.635691*q),
+		B: gammaFix(y - 1.108545*i + 1.709007*q),
+		A: 255,
+	}
+}
+
+// GeneratePalette generates a palette by emulating the composite video signal of the PPU
+// and decoding it the way an ideal NTSC television would.
+func GeneratePalette(params PaletteParams) *Palette {
+	palette := &Palette{}
+	hue := params.Hue / 30
+	for index := range palette {
+		var y, i, q float64
+		for phase := 0; phase < 12; phase++ {
+			signal := ntscSignal(uint16(index), phase) / 12
+			angle := math.Pi / 6 * (float64(phase) + ntscHuePhase + hue)
+			y += signal
+			i += signal * math.Cos(angle)
+			q += signal * math.Sin(angle)
+		}
+		palette[index] = yiqToRGBA(y, i, q, params)
+	}
+	return palette
+}
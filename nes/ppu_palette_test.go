@@ -0,0 +1,120 @@
+package nes
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// paletteData returns a .pal file of the given number of entries, with a distinct colour in each one.
+func paletteData(entries int) []byte {
+	data := make([]byte, entries*3)
+	for i := 0; i < entries; i++ {
+		data[i*3], data[i*3+1], data[i*3+2] = uint8(i), uint8(i>>8), uint8(0xFF-i)
+	}
+	return data
+}
+
+func TestParsePalette64(t *testing.T) {
+	palette, err := ParsePalette(paletteData(0x40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 0x40; i++ {
+		expected := color.RGBA{uint8(i), 0, uint8(0xFF - i), 0xFF}
+		if palette[i] != expected {
+			t.Fatalf("colour $%02X: got %v, expected %v", i, palette[i], expected)
+		}
+		// the emphasis variants are derived from the base colour
+		for emphasis := uint8(1); emphasis < 8; emphasis++ {
+			index := int(emphasis)<<6 | i
+			if palette[index] != emphasise(expected, emphasis) {
+				t.Fatalf("colour $%03X: got %v, expected %v", index, palette[index], emphasise(expected, emphasis))
+			}
+		}
+	}
+}
+
+func TestParsePalette512(t *testing.T) {
+	palette, err := ParsePalette(paletteData(0x200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every entry is read as is, including the emphasis variants
+	for i := range palette {
+		expected := color.RGBA{uint8(i), uint8(i >> 8), uint8(0xFF - i), 0xFF}
+		if palette[i] != expected {
+			t.Fatalf("colour $%03X: got %v, expected %v", i, palette[i], expected)
+		}
+	}
+}
+
+func TestParsePaletteSize(t *testing.T) {
+	for _, size := range []int{0, 3, 191, 193, 0x40 * 4, 1535, 1537, 0x400 * 3} {
+		if _, err := ParsePalette(make([]byte, size)); err == nil {
+			t.Errorf("%d bytes: expected an error", size)
+		}
+	}
+}
+
+func TestLoadPalette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.pal")
+	if err := os.WriteFile(path, paletteData(0x40), 0644); err != nil {
+		t.Fatal(err)
+	}
+	palette, err := LoadPalette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (color.RGBA{0x16, 0, 0xE9, 0xFF}); palette[0x16] != expected {
+		t.Errorf("got %v, expected %v", palette[0x16], expected)
+	}
+
+	if _, err := LoadPalette(filepath.Join(t.TempDir(), "missing.pal")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestGeneratePalette(t *testing.T) {
+	palette := GeneratePalette(DefaultPaletteParams())
+	tests := []struct {
+		index    uint16
+		expected color.RGBA
+	}{
+		{0x00, color.RGBA{83, 83, 83, 0xFF}},
+		{0x10, color.RGBA{160, 160, 160, 0xFF}},
+		{0x20, color.RGBA{255, 255, 255, 0xFF}},
+		{0x30, color.RGBA{255, 255, 255, 0xFF}},
+		{0x2D, color.RGBA{60, 60, 60, 0xFF}},
+		{0x0D, color.RGBA{0, 0, 0, 0xFF}},
+		{0x0F, color.RGBA{0, 0, 0, 0xFF}},
+		{0x3F, color.RGBA{0, 0, 0, 0xFF}},
+		{0x16, color.RGBA{131, 46, 36, 0xFF}},
+		{0x1A, color.RGBA{26, 107, 5, 0xFF}},
+		{0x12, color.RGBA{57, 55, 189, 0xFF}},
+		// white with all the emphasis bits is attenuated to a grey
+		{0x1E0, color.RGBA{152, 152, 152, 0xFF}},
+	}
+	for _, tt := range tests {
+		if got := palette[tt.index]; got != tt.expected {
+			t.Errorf("colour $%03X: got %v, expected %v", tt.index, got, tt.expected)
+		}
+	}
+
+	// without saturation every colour is a grey
+	params := DefaultPaletteParams()
+	params.Saturation = 0
+	for i, c := range GeneratePalette(params) {
+		if c.R != c.G || c.G != c.B {
+			t.Fatalf("colour $%03X: got %v without saturation, expected a grey", i, c)
+		}
+	}
+
+	// a full turn of the hue is neutral
+	params = DefaultPaletteParams()
+	params.Hue = 360
+	if rotated := GeneratePalette(params); *rotated != *palette {
+		t.Error("a hue rotation of 360 degrees changed the palette")
+	}
+}
@@ -64,6 +64,63 @@ func (p *PPU) GetNmiIndicator() uint8 {
 	return result
 }
 
+// PpuMask controls the rendering of sprites and backgrounds, as well as colour effects.
+//
+// 7  bit  0
+// ---- ----
+// BGRs bMmG
+// |||| ||||
+// |||| |||+- Greyscale (0: normal color, 1: produce a greyscale display)
+// |||| ||+-- 1: Show background in leftmost 8 pixels of screen, 0: Hide
+// |||| |+--- 1: Show sprites in leftmost 8 pixels of screen, 0: Hide
+// |||| +---- 1: Show background
+// |||+------ 1: Show sprites
+// ||+------- Emphasize red (green on PAL/Dendy)
+// |+-------- Emphasize green (red on PAL/Dendy)
+// +--------- Emphasize blue
+type PpuMask uint8
+
+func (p *PPU) GetGreyscale() uint8 {
+	result := uint8(p.ppuMask) & 0x01
+	mustAssert(result, 0, 1)
+	return result
+}
+
+func (p *PPU) GetShowBackgroundLeft() uint8 {
+	result := (uint8(p.ppuMask) & 0x02) >> 1
+	mustAssert(result, 0, 1)
+	return result
+}
+
+func (p *PPU) GetShowSpritesLeft() uint8 {
+	result := (uint8(p.ppuMask) & 0x04) >> 2
+	mustAssert(result, 0, 1)
+	return result
+}
+
+func (p *PPU) GetShowBackground() uint8 {
+	result := (uint8(p.ppuMask) & 0x08) >> 3
+	mustAssert(result, 0, 1)
+	return result
+}
+
+func (p *PPU) GetShowSprites() uint8 {
+	result := (uint8(p.ppuMask) & 0x10) >> 4
+	mustAssert(result, 0, 1)
+	return result
+}
+
+// GetEmphasis returns the 3 colour emphasis bits (BGR) of the mask register.
+func (p *PPU) GetEmphasis() uint8 {
+	return uint8(p.ppuMask) >> 5
+}
+
+// isRenderingEnabled returns true if either the background or the sprites are being rendered.
+// When rendering is disabled, the PPU stops touching the VRAM address entirely.
+func (p *PPU) isRenderingEnabled() bool {
+	return p.GetShowBackground() == 1 || p.GetShowSprites() == 1
+}
+
 type PpuStatus uint8
 
 func (p *PPU) GetSpriteOverflow() uint8 {
@@ -78,6 +135,24 @@ func (p *PPU) GetSpriteZeroHit() uint8 {
 	return result
 }
 
+func (p *PPU) SetSpriteOverflow(value uint8) {
+	mustAssert(value, 0, 1)
+	if value == 0 {
+		p.ppuStatus = PpuStatus(uint8(p.ppuStatus) & 0xDF)
+	} else if value == 1 {
+		p.ppuStatus = PpuStatus(uint8(p.ppuStatus) | 0x20)
+	}
+}
+
+func (p *PPU) SetSpriteZeroHit(value uint8) {
+	mustAssert(value, 0, 1)
+	if value == 0 {
+		p.ppuStatus = PpuStatus(uint8(p.ppuStatus) & 0xBF)
+	} else if value == 1 {
+		p.ppuStatus = PpuStatus(uint8(p.ppuStatus) | 0x40)
+	}
+}
+
 func (p *PPU) GetVerticalBlank() uint8 {
 	result := (uint8(p.ppuStatus) & 0x80) >> 7
 	mustAssert(result, 0, 1)
@@ -0,0 +1,220 @@
+package nes
+
+import "testing"
+
+// newTestPPU returns a PPU with a cartridge whose tile 0 is filled with colour 1, so the background shows colour 1
+// everywhere, and so do the sprites of the zero-filled OAM, in the top left corner.
+func newTestPPU() *PPU {
+	cartridge := &Cartridge{
+		prgRomData:  make([]byte, 0x4000),
+		chrRomData:  make([]byte, 0x2000),
+		prgRomBanks: 1,
+		chrRomBanks: 1,
+		mapper:      NewMapper0(1, 1),
+	}
+	for i := 0; i < 8; i++ {
+		cartridge.chrRomData[i] = 0xFF
+	}
+	p := NewPPU()
+	p.ConnectCartridge(cartridge)
+	return p
+}
+
+// writePalette writes the given colours to palette RAM, starting at the given address.
+func writePalette(p *PPU, addr uint16, colors ...uint8) {
+	p.CpuWrite(0x0006, uint8(addr>>8))
+	p.CpuWrite(0x0006, uint8(addr))
+	for _, c := range colors {
+		p.CpuWrite(0x0007, c)
+	}
+}
+
+// stepFrame clocks the PPU until the current frame is complete.
+func stepFrame(p *PPU) {
+	for !p.frameComplete {
+		p.Clock()
+	}
+	p.frameComplete = false
+}
+
+func TestPPUMask(t *testing.T) {
+	tests := []struct {
+		name   string
+		region Region
+		mask   uint8
+		// frame indices of a sprite pixel and a background pixel in the left column, and of a background pixel
+		sprite, left, background uint16
+	}{
+		{"everything shown", NTSC, 0x1E, 0x2A, 0x16, 0x16},
+		{"left column clipped", NTSC, 0x18, 0x0F, 0x0F, 0x16},
+		{"sprites clipped", NTSC, 0x1A, 0x16, 0x16, 0x16},
+		{"background clipped", NTSC, 0x1C, 0x2A, 0x0F, 0x16},
+		{"sprites only", NTSC, 0x14, 0x2A, 0x0F, 0x0F},
+		{"background only", NTSC, 0x0A, 0x16, 0x16, 0x16},
+		{"greyscale", NTSC, 0x1F, 0x20, 0x10, 0x10},
+		{"red emphasis", NTSC, 0x3E, 0x2A | 1<<6, 0x16 | 1<<6, 0x16 | 1<<6},
+		{"red and blue emphasis", NTSC, 0xBE, 0x2A | 5<<6, 0x16 | 5<<6, 0x16 | 5<<6},
+		// PAL PPUs have the red and green bits swapped
+		{"PAL red bit", PAL, 0x3E, 0x2A | 2<<6, 0x16 | 2<<6, 0x16 | 2<<6},
+		{"PAL green bit and blue", PAL, 0xDE, 0x2A | 5<<6, 0x16 | 5<<6, 0x16 | 5<<6},
+	}
+	for _, tt := range tests {
+		p := newTestPPU()
+		p.SetRegion(tt.region)
+		// the backdrop is $0F, the background colour 1 is $16 and the sprite colour 1 is $2A
+		writePalette(p, 0x3F00, 0x0F, 0x16)
+		writePalette(p, 0x3F11, 0x2A)
+		p.CpuWrite(0x0005, 0)
+		p.CpuWrite(0x0005, 0)
+		p.CpuWrite(0x0000, 0)
+		p.CpuWrite(0x0001, tt.mask)
+		for i := 0; i < 2; i++ {
+			stepFrame(p)
+		}
+		frame := p.FrameIndices()
+		if got := frame[4*ScreenWidth+4]; got != tt.sprite {
+			t.Errorf("%s: got $%03X for a sprite pixel, expected $%03X", tt.name, got, tt.sprite)
+		}
+		if got := frame[100*ScreenWidth+4]; got != tt.left {
+			t.Errorf("%s: got $%03X for a background pixel in the left column, expected $%03X", tt.name, got, tt.left)
+		}
+		if got := frame[100*ScreenWidth+100]; got != tt.background {
+			t.Errorf("%s: got $%03X for a background pixel, expected $%03X", tt.name, got, tt.background)
+		}
+	}
+}
+
+func TestBackgroundShiftersWithSpritesOnly(t *testing.T) {
+	// the shift registers keep running while only the sprites are shown,
+	// so that the background is aligned when it is shown again in the middle of a scanline
+	var shifters [2][4]uint16
+	for i, mask := range []uint8{0x18, 0x10} {
+		p := newTestPPU()
+		p.CpuWrite(0x0001, mask)
+		clockTo(p, 0, 0)
+		clockTo(p, 1, 100)
+		shifters[i] = [4]uint16{p.patternShiftLo, p.patternShiftHi, p.paletteShiftLo, p.paletteShiftHi}
+	}
+	if shifters[0] != shifters[1] {
+		t.Errorf("got shift registers %04X with the sprites only, expected %04X as with the background shown", shifters[1], shifters[0])
+	}
+}
+
+// clockTo clocks the PPU until the given dot is the next one to be run.
+func clockTo(p *PPU, scanline, cycle int) {
+	for p.scanline != scanline || p.cycle != cycle {
+		p.Clock()
+	}
+}
+
+func TestVBLReadRace(t *testing.T) {
+	tests := []struct {
+		name   string
+		cycle  int   // dot of the vertical blank scanline the read happens before
+		status uint8 // vertical blank bit read
+		vbl    bool  // whether the flag is set after dot 1
+		nmi    bool  // whether the NMI line is high after dot 1
+	}{
+		// reading early reads the flag as clear, it is set and the NMI happens as usual
+		{"dot 0", 0, 0x00, true, true},
+		// reading one dot before the flag is set reads it as clear, and the flag and the NMI never happen
+		{"dot 1", 1, 0x00, false, false},
+		// reading on the dot after it was set reads it as set, and clears it before the NMI is seen
+		{"dot 2", 2, 0x80, false, false},
+	}
+	for _, tt := range tests {
+		p := newTestPPU()
+		p.CpuWrite(0x0000, 0x80)
+		clockTo(p, p.timing.vblankScanline, tt.cycle)
+		if status := p.CpuRead(0x0002) & 0x80; status != tt.status {
+			t.Errorf("%s: got the vertical blank bit %02X, expected %02X", tt.name, status, tt.status)
+		}
+		clockTo(p, p.timing.vblankScanline, 3)
+		if vbl := p.GetVerticalBlank() == 1; vbl != tt.vbl {
+			t.Errorf("%s: got the vertical blank flag %v, expected %v", tt.name, vbl, tt.vbl)
+		}
+		if p.nmi != tt.nmi {
+			t.Errorf("%s: got the NMI line %v, expected %v", tt.name, p.nmi, tt.nmi)
+		}
+	}
+}
+
+func TestNMIEnabledDuringVBlank(t *testing.T) {
+	p := newTestPPU()
+	clockTo(p, p.timing.vblankScanline+1, 0)
+	if p.nmi {
+		t.Fatal("got an NMI with NMIs disabled")
+	}
+	p.CpuWrite(0x0000, 0x80)
+	if !p.nmi {
+		t.Error("expected enabling NMIs in vertical blank to raise the NMI line")
+	}
+	p.CpuWrite(0x0000, 0x00)
+	if p.nmi {
+		t.Error("expected disabling NMIs to drop the NMI line")
+	}
+
+	// once the flag is read, enabling NMIs has no effect until the next vertical blank
+	p.CpuRead(0x0002)
+	p.CpuWrite(0x0000, 0x80)
+	if p.nmi {
+		t.Error("expected no NMI after the vertical blank flag was cleared")
+	}
+}
+
+func TestOddFrameDotSkip(t *testing.T) {
+	tests := []struct {
+		name   string
+		region Region
+		mask   uint8
+		frames []int // number of dots of consecutive frames
+	}{
+		{"NTSC rendering", NTSC, 0x08, []int{89342, 89341, 89342, 89341}},
+		{"NTSC not rendering", NTSC, 0x00, []int{89342, 89342, 89342, 89342}},
+		{"PAL rendering", PAL, 0x08, []int{106392, 106392, 106392, 106392}},
+	}
+	for _, tt := range tests {
+		p := newTestPPU()
+		p.SetRegion(tt.region)
+		p.CpuWrite(0x0001, tt.mask)
+		// start from an even frame
+		clockTo(p, 0, 0)
+		if p.oddFrame {
+			clockTo(p, 1, 0)
+			clockTo(p, 0, 0)
+		}
+		for n, expected := range tt.frames {
+			dots := 0
+			for frame := p.frameCount; p.frameCount == frame; dots++ {
+				p.Clock()
+			}
+			if dots != expected {
+				t.Errorf("%s: frame %d took %d dots, expected %d", tt.name, n, dots, expected)
+			}
+		}
+	}
+}
+
+func TestOpenBusDecay(t *testing.T) {
+	p := newTestPPU()
+	p.CpuWrite(0x0000, 0xFF)
+	clockTo(p, 0, 0)
+
+	for frame := 1; frame <= openBusDecayFrames; frame++ {
+		if frame == 20 {
+			// reading PPUSTATUS in vertical blank refreshes the top 3 bits only, with %100
+			clockTo(p, p.timing.vblankScanline, 2)
+			p.CpuRead(0x0002)
+		}
+		clockTo(p, p.prerenderScanline(), 0)
+		clockTo(p, 0, 0)
+		if frame == openBusDecayFrames-1 {
+			if data := p.CpuRead(0x0000); data != 0x9F {
+				t.Errorf("got the open bus $%02X after %d frames, expected $9F", data, frame)
+			}
+		}
+	}
+	if data := p.CpuRead(0x0000); data != 0x80 {
+		t.Errorf("got the open bus $%02X after %d frames, expected the bits that were not refreshed to decay", data, openBusDecayFrames)
+	}
+}
@@ -0,0 +1,261 @@
+// GIF Reference: https://www.w3.org/Graphics/GIF/spec-gif89a.txt
+// YUV4MPEG2 Reference: https://wiki.multimedia.cx/index.php/YUV4MPEG2
+
+package nes
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VideoRecorder receives the frames of a recording, see: VM.StartRecording
+type VideoRecorder interface {
+	WriteFrame(frame *image.RGBA) error
+	// Close finishes the recording, and closes the file it was created with, if any.
+	Close() error
+}
+
+// recording is a video recording in progress.
+type recording struct {
+	recorder VideoRecorder
+	options  ScreenshotOptions
+	err      error // first error returned by the recorder
+}
+
+// StartRecording passes every frame completed by StepFrame to the recorder, scaled as set in the options,
+// until StopRecording. A recording in progress is stopped first.
+// There is no APU yet, so the recordings have no audio, see: AudioSink
+func (v *VM) StartRecording(recorder VideoRecorder, options ScreenshotOptions) error {
+	err := v.StopRecording()
+	v.recording = &recording{recorder: recorder, options: options}
+	return err
+}
+
+// StopRecording finishes the recording in progress, returning the first error of the recorder.
+func (v *VM) StopRecording() error {
+	r := v.recording
+	if r == nil {
+		return nil
+	}
+	v.recording = nil
+	if err := r.recorder.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// Recording returns whether a recording is in progress.
+func (v *VM) Recording() bool {
+	return v.recording != nil
+}
+
+// recordFrame passes the frame that was just completed to the recorder.
+func (v *VM) recordFrame() {
+	r := v.recording
+	if r == nil || r.err != nil {
+		return
+	}
+	r.err = r.recorder.WriteFrame(v.Screenshot(r.options))
+}
+
+// CreateRecording creates a recorder writing to the given file, chosen by its extension: .gif for short clips,
+// see: GIFRecorder, or .y4m for longer ones, see: Y4MRecorder
+func CreateRecording(filePath string, frameRate float64, options ScreenshotOptions) (VideoRecorder, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext != ".gif" && ext != ".y4m" {
+		return nil, fmt.Errorf("unsupported recording format %q, expected .gif or .y4m", ext)
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating recording: %v", err)
+	}
+	if ext == ".gif" {
+		return &fileRecorder{NewGIFRecorder(f, frameRate), f}, nil
+	}
+	return &fileRecorder{NewY4MRecorder(f, frameRate, !options.PixelAspect), f}, nil
+}
+
+// fileRecorder closes the file of a recorder once it is finished.
+type fileRecorder struct {
+	VideoRecorder
+	file *os.File
+}
+
+func (r *fileRecorder) Close() error {
+	err := r.VideoRecorder.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// minGIFDelay is the shortest frame delay in 1/100 s, most viewers show frames with a shorter delay for 1/10 s.
+const minGIFDelay = 2
+
+// GIFRecorder records an animated GIF. The frames are kept in memory until Close, so it is meant for short clips.
+type GIFRecorder struct {
+	w         io.Writer
+	frameRate float64
+	anim      gif.GIF
+	elapsed   float64 // time of the frames so far in 1/100 s, the unit of the GIF frame delays
+	lastFrame *image.Paletted
+}
+
+func NewGIFRecorder(w io.Writer, frameRate float64) *GIFRecorder {
+	return &GIFRecorder{w: w, frameRate: frameRate}
+}
+
+// WriteFrame adds a frame to the GIF. Consecutive identical frames are merged into one longer frame,
+// and frames following one shorter than minGIFDelay are dropped into it, e.g. every other frame at 60 fps.
+func (r *GIFRecorder) WriteFrame(frame *image.RGBA) error {
+	// GIF delays are whole hundredths of a second, the rounding error is carried over to the next frame
+	start := math.Round(r.elapsed)
+	r.elapsed += 100 / r.frameRate
+	delay := int(math.Round(r.elapsed) - start)
+
+	if n := len(r.anim.Delay); n > 0 && r.anim.Delay[n-1] < minGIFDelay {
+		r.anim.Delay[n-1] += delay
+		return nil
+	}
+	paletted := palettedFrame(frame)
+	if r.lastFrame != nil && samePixels(r.lastFrame, paletted) {
+		r.anim.Delay[len(r.anim.Delay)-1] += delay
+		return nil
+	}
+	r.lastFrame = paletted
+	r.anim.Image = append(r.anim.Image, paletted)
+	r.anim.Delay = append(r.anim.Delay, delay)
+	return nil
+}
+
+func (r *GIFRecorder) Close() error {
+	if len(r.anim.Image) == 0 {
+		return fmt.Errorf("no frames were recorded")
+	}
+	// a last frame that is too short is merged into the previous one
+	if n := len(r.anim.Delay); r.anim.Delay[n-1] < minGIFDelay {
+		if n > 1 {
+			r.anim.Delay[n-2] += r.anim.Delay[n-1]
+			r.anim.Image, r.anim.Delay = r.anim.Image[:n-1], r.anim.Delay[:n-1]
+		} else {
+			r.anim.Delay[0] = minGIFDelay
+		}
+	}
+	return gif.EncodeAll(r.w, &r.anim)
+}
+
+// palettedFrame converts a frame to a paletted image. A frame uses at most 64 colours, unless the emphasis bits change
+// during the frame, in which case frames with more than 256 colours are dithered to a fixed palette.
+func palettedFrame(frame *image.RGBA) *image.Paletted {
+	bounds := frame.Bounds()
+	indices := make(map[color.RGBA]uint8)
+	var colors color.Palette
+	img := image.NewPaletted(bounds, nil)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := frame.RGBAAt(x, y)
+			index, ok := indices[c]
+			if !ok {
+				if len(colors) == 256 {
+					img = image.NewPaletted(bounds, palette.Plan9)
+					draw.FloydSteinberg.Draw(img, bounds, frame, bounds.Min)
+					return img
+				}
+				index = uint8(len(colors))
+				indices[c] = index
+				colors = append(colors, c)
+			}
+			img.Pix[img.PixOffset(x, y)] = index
+		}
+	}
+	img.Palette = colors
+	return img
+}
+
+func samePixels(a, b *image.Paletted) bool {
+	if len(a.Palette) != len(b.Palette) || len(a.Pix) != len(b.Pix) {
+		return false
+	}
+	for i := range a.Palette {
+		if a.Palette[i] != b.Palette[i] {
+			return false
+		}
+	}
+	for i := range a.Pix {
+		if a.Pix[i] != b.Pix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Y4MRecorder streams the frames as uncompressed YUV4MPEG2 video with 4:4:4 chroma, which most video tools read,
+// e.g. ffmpeg -i clip.y4m clip.mp4
+type Y4MRecorder struct {
+	w           *bufio.Writer
+	frameRate   float64
+	pixelAspect bool // the frames are marked to be shown with the 8:7 pixel aspect ratio
+	started     bool
+	planes      []byte
+}
+
+// NewY4MRecorder creates a recorder writing to w. If pixelAspect is set, players are told to show the pixels
+// with the 8:7 aspect ratio of a TV, for frames that were not stretched already.
+func NewY4MRecorder(w io.Writer, frameRate float64, pixelAspect bool) *Y4MRecorder {
+	return &Y4MRecorder{w: bufio.NewWriter(w), frameRate: frameRate, pixelAspect: pixelAspect}
+}
+
+func (r *Y4MRecorder) WriteFrame(frame *image.RGBA) error {
+	bounds := frame.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if !r.started {
+		r.started = true
+		aspect := "1:1"
+		if r.pixelAspect {
+			aspect = "8:7"
+		}
+		// the frame rate is a ratio, e.g. 60.0988 is 60099:1000
+		fmt.Fprintf(r.w, "YUV4MPEG2 W%d H%d F%d:1000 Ip A%s C444\n", width, height, int(math.Round(r.frameRate*1000)), aspect)
+		r.planes = make([]byte, width*height*3)
+	}
+	if len(r.planes) != width*height*3 {
+		return fmt.Errorf("frame size changed to %dx%d during the recording", width, height)
+	}
+
+	size := width * height
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := frame.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			yy, cb, cr := studioYCbCr(c)
+			i := y*width + x
+			r.planes[i], r.planes[size+i], r.planes[2*size+i] = yy, cb, cr
+		}
+	}
+	r.w.WriteString("FRAME\n")
+	_, err := r.w.Write(r.planes)
+	return err
+}
+
+// studioYCbCr converts a colour to BT.601 YCbCr with the studio range, 16-235 for Y and 16-240 for Cb and Cr,
+// which is what players assume for YUV4MPEG2.
+func studioYCbCr(c color.RGBA) (uint8, uint8, uint8) {
+	r, g, b := float64(c.R), float64(c.G), float64(c.B)
+	y := 16 + (65.738*r+129.057*g+25.064*b)/256
+	cb := 128 + (-37.945*r-74.494*g+112.439*b)/256
+	cr := 128 + (112.439*r-94.154*g-18.285*b)/256
+	return uint8(math.Round(y)), uint8(math.Round(cb)), uint8(math.Round(cr))
+}
+
+func (r *Y4MRecorder) Close() error {
+	return r.w.Flush()
+}
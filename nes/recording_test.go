@@ -0,0 +1,112 @@
+package nes
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+	"testing"
+)
+
+// recordFrames records the given number of frames of the movie test ROM, with changing input.
+func recordFrames(t *testing.T, recorder VideoRecorder, options ScreenshotOptions, frames int) {
+	t.Helper()
+	vm := NewVM()
+	vm.LoadROM(writeMovieROM(t))
+	vm.PowerCycle()
+	if err := vm.StartRecording(recorder, options); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < frames; i++ {
+		vm.SetControllerState(0, uint8(i/3))
+		vm.StepFrame()
+	}
+	if err := vm.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	if vm.Recording() {
+		t.Error("still recording after StopRecording")
+	}
+}
+
+func TestGIFRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recordFrames(t, NewGIFRecorder(&buf, 60), ScreenshotOptions{CropOverscan: true}, 30)
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for i, delay := range anim.Delay {
+		if delay < minGIFDelay {
+			t.Errorf("frame %d: got a delay of %d/100 s, expected at least %d", i, delay, minGIFDelay)
+		}
+		total += delay
+	}
+	if total != 50 {
+		t.Errorf("got a total delay of %d/100 s, expected 50 for 30 frames at 60 fps", total)
+	}
+	if len(anim.Image) < 2 || len(anim.Image) > 15 {
+		t.Errorf("got %d images, expected at most 15 for 30 frames at 60 fps, with identical frames merged", len(anim.Image))
+	}
+	if size := anim.Image[0].Bounds().Size(); size.X != 240 || size.Y != 224 {
+		t.Errorf("got frames of %v, expected 240x224", size)
+	}
+}
+
+func TestGIFRecorderDelays(t *testing.T) {
+	tests := []struct {
+		frameRate float64
+		frames    int
+		delays    []int
+	}{
+		{60, 1, []int{2}},
+		{60, 2, []int{3}},
+		{60, 5, []int{2, 3, 3}},
+		{60, 6, []int{2, 3, 2, 3}},
+		{50, 4, []int{2, 2, 2, 2}},
+		{30, 3, []int{3, 4, 3}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		recorder := NewGIFRecorder(&buf, test.frameRate)
+		for i := 0; i < test.frames; i++ {
+			// every frame is different, so that none is merged for being identical
+			frame := image.NewRGBA(image.Rect(0, 0, 4, 4))
+			frame.SetRGBA(0, 0, color.RGBA{uint8(i), 0, 0, 0xFF})
+			if err := recorder.WriteFrame(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatal(err)
+		}
+		anim, err := gif.DecodeAll(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(anim.Delay) != fmt.Sprint(test.delays) {
+			t.Errorf("%d frames at %v fps: got delays %v, expected %v", test.frames, test.frameRate, anim.Delay, test.delays)
+		}
+	}
+}
+
+func TestY4MRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recordFrames(t, NewY4MRecorder(&buf, NTSC.FrameRate(), true), ScreenshotOptions{}, 5)
+
+	header := fmt.Sprintf("YUV4MPEG2 W256 H240 F%d:1000 Ip A8:7 C444\n", int(NTSC.FrameRate()*1000+0.5))
+	if !strings.HasPrefix(buf.String(), header) {
+		t.Errorf("got header %q, expected %q", strings.SplitN(buf.String(), "\n", 2)[0], header)
+	}
+	frameSize := len("FRAME\n") + 256*240*3
+	if buf.Len() != len(header)+5*frameSize {
+		t.Errorf("got %d bytes, expected %d for 5 frames", buf.Len(), len(header)+5*frameSize)
+	}
+	if frame := buf.Bytes()[len(header)+frameSize:]; !bytes.HasPrefix(frame, []byte("FRAME\n")) {
+		t.Error("second frame does not start with FRAME")
+	}
+}
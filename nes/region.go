@@ -0,0 +1,101 @@
+// Region Reference: https://www.nesdev.org/wiki/Cycle_reference_chart
+
+package nes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Region is the TV system a console was built for, which determines its timing.
+type Region uint8
+
+const (
+	NTSC  Region = iota // North America and Japan
+	PAL                 // Europe and Australia
+	Dendy               // Famiclones with PAL video and near-NTSC CPU timing
+)
+
+func (r Region) ToString() string {
+	switch r {
+	case NTSC:
+		return "NTSC"
+	case PAL:
+		return "PAL"
+	case Dendy:
+		return "Dendy"
+	}
+	return ""
+}
+
+// ParseRegion is the inverse of Region.ToString, ignoring case.
+func ParseRegion(s string) (Region, error) {
+	for _, r := range []Region{NTSC, PAL, Dendy} {
+		if strings.EqualFold(s, r.ToString()) {
+			return r, nil
+		}
+	}
+	return NTSC, fmt.Errorf("unknown region %q", s)
+}
+
+// regionTiming holds everything that differs between the regions.
+// The CPU and the PPU are both driven by dividing the master clock.
+type regionTiming struct {
+	masterClock    int     // master clock speed in Hz
+	cpuDivider     int     // master clocks per CPU cycle
+	ppuDivider     int     // master clocks per PPU dot
+	scanlines      int     // scanlines per frame, including the pre-render scanline
+	vblankScanline int     // scanline on which the vertical blank flag is set
+	frameRate      float64 // frames per second
+	oddFrameSkip   bool    // whether odd frames skip a dot when rendering is enabled
+	swapEmphasis   bool    // whether the red and green emphasis bits are swapped
+}
+
+var regionTimings = map[Region]regionTiming{
+	NTSC: {
+		masterClock:    21477272,
+		cpuDivider:     12,
+		ppuDivider:     4,
+		scanlines:      262,
+		vblankScanline: 241,
+		frameRate:      60.0988,
+		oddFrameSkip:   true,
+	},
+	PAL: {
+		masterClock:    26601712,
+		cpuDivider:     16,
+		ppuDivider:     5,
+		scanlines:      312,
+		vblankScanline: 241,
+		frameRate:      50.0070,
+		swapEmphasis:   true,
+	},
+	Dendy: {
+		masterClock:    26601712,
+		cpuDivider:     15,
+		ppuDivider:     5,
+		scanlines:      312,
+		vblankScanline: 291,
+		frameRate:      50.0070,
+		swapEmphasis:   true,
+	},
+}
+
+func (r Region) timing() regionTiming {
+	timing, ok := regionTimings[r]
+	if !ok {
+		return regionTimings[NTSC]
+	}
+	return timing
+}
+
+// CPUClockSpeed returns the number of CPU cycles per second.
+func (r Region) CPUClockSpeed() int {
+	timing := r.timing()
+	return timing.masterClock / timing.cpuDivider
+}
+
+// FrameRate returns the number of frames per second.
+func (r Region) FrameRate() float64 {
+	return r.timing().frameRate
+}
@@ -0,0 +1,106 @@
+package nes
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectRegion(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		header   map[int]uint8 // header bytes changed from an iNES header with all flags clear
+		region   Region
+	}{
+		{"iNES", "game.nes", nil, NTSC},
+		{"iNES TV system flag", "game.nes", map[int]uint8{9: 0x01}, PAL},
+		{"No-Intro tag", "Game (Europe).nes", nil, PAL},
+		{"GoodNES tag", "Game (E) [!].nes", nil, PAL},
+		{"Dendy tag", "Game (Russia).nes", nil, Dendy},
+		{"tag before TV system flag", "Game (USA).nes", map[int]uint8{9: 0x01}, NTSC},
+		{"NES 2.0 NTSC", "game.nes", map[int]uint8{7: 0x08, 12: 0x00}, NTSC},
+		{"NES 2.0 PAL", "game.nes", map[int]uint8{7: 0x08, 12: 0x01}, PAL},
+		{"NES 2.0 multi-region", "game.nes", map[int]uint8{7: 0x08, 12: 0x02}, NTSC},
+		{"NES 2.0 Dendy", "game.nes", map[int]uint8{7: 0x08, 12: 0x03}, Dendy},
+		{"NES 2.0 before tag", "Game (Europe).nes", map[int]uint8{7: 0x08, 12: 0x00}, NTSC},
+	}
+	for _, tt := range tests {
+		rom, err := os.ReadFile(writeTestROM(t, []uint8{0x4C, 0x00, 0xC0}, 0xC000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, b := range tt.header {
+			rom[i] = b
+		}
+		path := filepath.Join(t.TempDir(), tt.fileName)
+		if err := os.WriteFile(path, rom, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		vm := NewVM()
+		vm.LoadROM(path)
+		if region := vm.Region(); region != tt.region {
+			t.Errorf("%s: got %s, expected %s", tt.name, region.ToString(), tt.region.ToString())
+		}
+	}
+}
+
+func TestRegionFrameLength(t *testing.T) {
+	tests := []struct {
+		region Region
+		cycles float64 // CPU cycles per frame, with rendering disabled
+	}{
+		{NTSC, 341 * 262 / 3.0},
+		{PAL, 341 * 312 / 3.2},
+		{Dendy, 341 * 312 / 3.0},
+	}
+	for _, tt := range tests {
+		vm := NewVM()
+		vm.LoadROM(writeTestROM(t, []uint8{0x4C, 0x00, 0xC0}, 0xC000)) // JMP $C000
+		vm.SetRegion(tt.region)
+		vm.Reset()
+		vm.StepFrame()
+
+		const frames = 10
+		start := vm.PeekCPU().Cycle
+		for i := 0; i < frames; i++ {
+			vm.StepFrame()
+		}
+		// frames end in the middle of an instruction, which takes 3 cycles
+		cycles := float64(vm.PeekCPU().Cycle - start)
+		if math.Abs(cycles-frames*tt.cycles) > 3 {
+			t.Errorf("%s: %d frames took %v CPU cycles, expected %.1f", tt.region.ToString(), frames, cycles, frames*tt.cycles)
+		}
+
+		frameRate := float64(tt.region.CPUClockSpeed()) / tt.cycles
+		if math.Abs(frameRate-tt.region.FrameRate()) > 0.001 {
+			t.Errorf("%s: the frame length gives %.4f frames per second, expected %.4f", tt.region.ToString(), frameRate, tt.region.FrameRate())
+		}
+	}
+}
+
+func TestRegionVBlankScanline(t *testing.T) {
+	tests := []struct {
+		region   Region
+		scanline int
+	}{
+		{NTSC, 241},
+		{PAL, 241},
+		{Dendy, 291},
+	}
+	for _, tt := range tests {
+		p := NewPPU()
+		p.SetRegion(tt.region)
+		clockTo(p, 0, 0)
+		clockTo(p, tt.scanline, 1)
+		if p.GetVerticalBlank() != 0 {
+			t.Errorf("%s: vertical blank set before scanline %d", tt.region.ToString(), tt.scanline)
+		}
+		clockTo(p, tt.scanline, 2)
+		if p.GetVerticalBlank() != 1 {
+			t.Errorf("%s: vertical blank not set on scanline %d", tt.region.ToString(), tt.scanline)
+		}
+	}
+}
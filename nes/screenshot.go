@@ -0,0 +1,66 @@
+package nes
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+)
+
+// overscan is the number of pixels on each edge of the picture that TVs usually hide.
+const overscan = 8
+
+// ScreenshotOptions sets the size of a screenshot, see: VM.Screenshot
+type ScreenshotOptions struct {
+	Scale        int  `json:"scale"`        // nearest-neighbour scale of the pixels, 0 and 1 keep the native size
+	PixelAspect  bool `json:"pixelAspect"`  // stretch the pixels to the 8:7 aspect ratio of a TV
+	CropOverscan bool `json:"cropOverscan"` // crop the 8 pixels on each edge that TVs hide
+}
+
+// Screenshot returns the most recently rendered frame as an image, scaled as set in the options.
+func (v *VM) Screenshot(options ScreenshotOptions) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	v.FrameRGBA(frame.Pix)
+
+	source := frame.Bounds()
+	if options.CropOverscan {
+		source = source.Inset(overscan)
+	}
+	scale := options.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	width, height := source.Dx()*scale, source.Dy()*scale
+	if options.PixelAspect {
+		width = (width*8 + 3) / 7
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := source.Min.Y + y*source.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := source.Min.X + x*source.Dx()/width
+			copy(img.Pix[img.PixOffset(x, y):img.PixOffset(x, y)+4], frame.Pix[frame.PixOffset(sx, sy):])
+		}
+	}
+	return img
+}
+
+// WriteScreenshot writes the most recently rendered frame as a PNG image, see: Screenshot
+func (v *VM) WriteScreenshot(w io.Writer, options ScreenshotOptions) error {
+	return png.Encode(w, v.Screenshot(options))
+}
+
+// SaveScreenshot writes the most recently rendered frame to a PNG file, see: Screenshot
+func (v *VM) SaveScreenshot(filePath string, options ScreenshotOptions) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error while writing screenshot: %v", err)
+	}
+	if err := v.WriteScreenshot(f, options); err != nil {
+		f.Close()
+		return fmt.Errorf("error while writing screenshot: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,70 @@
+package nes
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestScreenshot(t *testing.T) {
+	vm := NewVM()
+	ppu := vm.bus.PPU
+	for i := range ppu.frame {
+		ppu.frame[i] = 0x0F
+	}
+	ppu.frame[0] = 0x30               // top left, in the overscan
+	ppu.frame[8*ScreenWidth+8] = 0x16 // top left after cropping
+	white, red, black := ppu.colorLookup[0x30], ppu.colorLookup[0x16], ppu.colorLookup[0x0F]
+
+	pixel := func(img *image.RGBA, x, y int) [4]uint8 {
+		var c [4]uint8
+		copy(c[:], img.Pix[img.PixOffset(x, y):])
+		return c
+	}
+
+	tests := []struct {
+		name          string
+		options       ScreenshotOptions
+		width, height int
+		topLeft       [4]uint8
+		scaled        [4]uint8 // pixel at (scale, scale), outside the top left pixel unless the aspect ratio stretches it
+	}{
+		{"native", ScreenshotOptions{}, 256, 240, white, black},
+		{"scaled", ScreenshotOptions{Scale: 3}, 768, 720, white, black},
+		{"cropped", ScreenshotOptions{CropOverscan: true}, 240, 224, red, black},
+		{"aspect", ScreenshotOptions{Scale: 2, PixelAspect: true}, 585, 480, white, black},
+	}
+	for _, tt := range tests {
+		img := vm.Screenshot(tt.options)
+		if img.Bounds().Dx() != tt.width || img.Bounds().Dy() != tt.height {
+			t.Errorf("%s: got %v, expected %dx%d", tt.name, img.Bounds().Size(), tt.width, tt.height)
+			continue
+		}
+		if got := pixel(img, 0, 0); got != tt.topLeft {
+			t.Errorf("%s: got top left %v, expected %v", tt.name, got, tt.topLeft)
+		}
+		scale := tt.options.Scale
+		if scale < 1 {
+			scale = 1
+		}
+		if got := pixel(img, scale-1, scale-1); got != tt.topLeft {
+			t.Errorf("%s: got %v at (%d, %d), expected the top left pixel to be scaled", tt.name, got, scale-1, scale-1)
+		}
+		if got := pixel(img, scale+1, scale); got != tt.scaled {
+			t.Errorf("%s: got %v at (%d, %d), expected %v", tt.name, got, scale+1, scale, tt.scaled)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := vm.WriteScreenshot(&buf, ScreenshotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := decoded.At(0, 0).RGBA(); uint8(r>>8) != white[0] || uint8(g>>8) != white[1] || uint8(b>>8) != white[2] {
+		t.Errorf("got top left %v in the PNG, expected %v", decoded.At(0, 0), white)
+	}
+}
@@ -0,0 +1,389 @@
+package nes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a label and/or a comment for an address, or for a range of addresses such as an array.
+type Symbol struct {
+	Name    string
+	Comment string
+	Size    int // number of bytes labeled, at least 1
+
+	// Symbols in the PRG ROM are located by their offset into the ROM, as their CPU address depends on the mapped bank.
+	// Other symbols, e.g. for RAM and registers, are located by their CPU address.
+	InPRGROM bool
+	Offset   int
+	Addr     uint16
+}
+
+// symbolRef is a symbol covering an address, along with the offset of the address within the symbol.
+type symbolRef struct {
+	symbol *Symbol
+	offset int
+}
+
+// Symbols is a table of the symbols loaded from label files, see: Symbols.LoadFile
+type Symbols struct {
+	byAddr   map[uint16]symbolRef
+	byOffset map[int]symbolRef
+	byName   map[string][]*Symbol
+}
+
+func NewSymbols() *Symbols {
+	return &Symbols{
+		byAddr:   map[uint16]symbolRef{},
+		byOffset: map[int]symbolRef{},
+		byName:   map[string][]*Symbol{},
+	}
+}
+
+// Len returns the number of symbols in the table.
+func (s *Symbols) Len() int {
+	n := 0
+	for _, symbols := range s.byName {
+		n += len(symbols)
+	}
+	return n
+}
+
+// Add adds a symbol to the table. A symbol at the same location as an existing one only fills in its missing name or comment.
+func (s *Symbols) Add(symbol Symbol) {
+	if symbol.Size < 1 {
+		symbol.Size = 1
+	}
+	var existing symbolRef
+	if symbol.InPRGROM {
+		existing = s.byOffset[symbol.Offset]
+	} else {
+		existing = s.byAddr[symbol.Addr]
+	}
+	if existing.symbol != nil && existing.offset == 0 {
+		if existing.symbol.Name == "" && symbol.Name != "" {
+			existing.symbol.Name = symbol.Name
+			s.byName[symbol.Name] = append(s.byName[symbol.Name], existing.symbol)
+		}
+		if existing.symbol.Comment == "" {
+			existing.symbol.Comment = symbol.Comment
+		}
+		return
+	}
+
+	added := &symbol
+	for n := 0; n < symbol.Size; n++ {
+		if symbol.InPRGROM {
+			s.byOffset[symbol.Offset+n] = symbolRef{added, n}
+		} else {
+			s.byAddr[symbol.Addr+uint16(n)] = symbolRef{added, n}
+		}
+	}
+	s.byName[symbol.Name] = append(s.byName[symbol.Name], added)
+}
+
+// Lookup returns the symbols with the given name. Names are not unique, e.g. for local labels in different scopes.
+func (s *Symbols) Lookup(name string) []Symbol {
+	var symbols []Symbol
+	if name == "" {
+		return nil
+	}
+	for _, symbol := range s.byName[name] {
+		symbols = append(symbols, *symbol)
+	}
+	return symbols
+}
+
+// resolve returns the symbol covering the given CPU address, in the PRG ROM bank that is currently mapped there.
+// PRG ROM symbols take precedence over those located by their CPU address.
+func (s *Symbols) resolve(b *Bus, addr uint16) (symbolRef, bool) {
+	if b.Cartridge != nil {
+		if offset, ok := b.Cartridge.prgRomOffset(addr); ok {
+			if ref, ok := s.byOffset[offset]; ok {
+				return ref, true
+			}
+		}
+	}
+	ref, ok := s.byAddr[addr]
+	return ref, ok
+}
+
+// label returns the name of the given CPU address, e.g. "Buffer+2" within an array, or "" if it has none.
+func (s *Symbols) label(b *Bus, addr uint16) string {
+	ref, ok := s.resolve(b, addr)
+	if !ok || ref.symbol.Name == "" {
+		return ""
+	}
+	if ref.offset > 0 {
+		return fmt.Sprintf("%s+%d", ref.symbol.Name, ref.offset)
+	}
+	return ref.symbol.Name
+}
+
+// annotation returns the label and comment of the instruction at the given CPU address, on a single line.
+func (s *Symbols) annotation(b *Bus, addr uint16) string {
+	var parts []string
+	if ref, ok := s.resolve(b, addr); ok && ref.offset == 0 {
+		if ref.symbol.Name != "" {
+			parts = append(parts, ref.symbol.Name+":")
+		}
+		if ref.symbol.Comment != "" {
+			parts = append(parts, strings.ReplaceAll(ref.symbol.Comment, "\n", " "))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// LoadFile loads the symbols of a label file, by its extension:
+//   - .dbg: the debug information written by ld65 (ca65)
+//   - .mlb: Mesen labels
+//   - .nl: FCEUX labels, named <rom>.ram.nl for RAM, and <rom>.<bank>.nl for a 16 KiB PRG ROM bank in hexadecimal
+func (s *Symbols) LoadFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".dbg":
+		err = s.LoadCA65(f)
+	case ".mlb":
+		err = s.LoadMesen(f)
+	case ".nl":
+		err = s.LoadFCEUX(f, fceuxBank(filePath))
+	default:
+		return fmt.Errorf("unknown label file format: %s", filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+	return nil
+}
+
+// fceuxBank returns the PRG ROM bank of an FCEUX label file, from its name, or -1 for RAM labels.
+func fceuxBank(filePath string) int {
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	bank, err := strconv.ParseUint(strings.TrimPrefix(filepath.Ext(name), "."), 16, 8)
+	if err != nil {
+		return -1
+	}
+	return int(bank)
+}
+
+// LoadFCEUX loads FCEUX labels, with lines such as "$C000#Reset#comment", or "$0200/10#Buffer#" for a 16 byte array.
+// The addresses of a PRG ROM bank are CPU addresses, located in the given 16 KiB bank. Other labels use a bank of -1.
+func (s *Symbols) LoadFCEUX(r io.Reader, bank int) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || !strings.HasPrefix(text, "$") {
+			continue
+		}
+		fields := strings.SplitN(text[1:], "#", 3)
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: expected $address#label#comment", line)
+		}
+
+		size := uint64(1)
+		addrField := fields[0]
+		if i := strings.IndexByte(addrField, '/'); i >= 0 {
+			var err error
+			if size, err = strconv.ParseUint(addrField[i+1:], 16, 16); err != nil {
+				return fmt.Errorf("line %d: invalid array size %q", line, addrField[i+1:])
+			}
+			addrField = addrField[:i]
+		}
+		addr, err := strconv.ParseUint(addrField, 16, 16)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid address %q", line, addrField)
+		}
+
+		symbol := Symbol{Name: fields[1], Size: int(size), Addr: uint16(addr)}
+		if len(fields) > 2 {
+			symbol.Comment = fields[2]
+		}
+		if bank >= 0 && addr >= 0x8000 {
+			symbol.InPRGROM = true
+			symbol.Offset = bank*0x4000 + int(addr&0x3FFF)
+		}
+		s.Add(symbol)
+	}
+	return scanner.Err()
+}
+
+// LoadMesen loads Mesen labels, with lines such as "P:1A2B:Reset:comment" or "R:0200-020F:Buffer".
+// Both the memory types of Mesen (P, R, W, S, G) and those of Mesen 2 (NesPrgRom, NesInternalRam, ...) are understood,
+// labels for other memory, such as the CHR ROM, are skipped.
+func (s *Symbols) LoadMesen(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.SplitN(text, ":", 4)
+		if len(fields) < 3 {
+			return fmt.Errorf("line %d: expected type:address:label:comment", line)
+		}
+
+		start, end := fields[1], fields[1]
+		if i := strings.IndexByte(fields[1], '-'); i >= 0 {
+			start, end = fields[1][:i], fields[1][i+1:]
+		}
+		first, err := strconv.ParseUint(start, 16, 32)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid address %q", line, start)
+		}
+		last, err := strconv.ParseUint(end, 16, 32)
+		if err != nil || last < first {
+			return fmt.Errorf("line %d: invalid address %q", line, end)
+		}
+
+		symbol := Symbol{Name: fields[2], Size: int(last-first) + 1}
+		if len(fields) > 3 {
+			symbol.Comment = strings.ReplaceAll(fields[3], `\n`, "\n")
+		}
+		switch fields[0] {
+		case "P", "NesPrgRom":
+			symbol.InPRGROM = true
+			symbol.Offset = int(first)
+		case "R", "NesInternalRam":
+			symbol.Addr = uint16(first)
+		case "W", "S", "NesWorkRam", "NesSaveRam":
+			symbol.Addr = 0x6000 + uint16(first)
+		case "G", "NesMemory":
+			symbol.Addr = uint16(first)
+		default:
+			continue
+		}
+		s.Add(symbol)
+	}
+	return scanner.Err()
+}
+
+// LoadCA65 loads the labels from the debug information written by ld65 with the --dbgfile option.
+// Labels in segments written to the ROM are located in the PRG ROM, the others (e.g. BSS) by their CPU address.
+func (s *Symbols) LoadCA65(r io.Reader) error {
+	type segment struct {
+		start    int
+		inROM    bool
+		romStart int
+	}
+	segments := map[string]segment{}
+	var labels []map[string]string
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		kind, attributes, err := parseCA65DebugLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		switch kind {
+		case "seg":
+			var seg segment
+			if seg.start, err = parseCA65Number(attributes["start"]); err != nil {
+				return fmt.Errorf("line %d: invalid segment start", line)
+			}
+			if offset, ok := attributes["ooffs"]; ok {
+				seg.inROM = true
+				if seg.romStart, err = parseCA65Number(offset); err != nil {
+					return fmt.Errorf("line %d: invalid segment output offset", line)
+				}
+				if strings.HasSuffix(strings.ToLower(attributes["oname"]), ".nes") {
+					seg.romStart -= 16 // the iNES header
+				}
+			}
+			segments[attributes["id"]] = seg
+		case "sym":
+			if attributes["type"] == "lab" {
+				labels = append(labels, attributes)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// symbols may come before the segments they are in
+	for _, attributes := range labels {
+		value, err := parseCA65Number(attributes["val"])
+		if err != nil {
+			return fmt.Errorf("invalid value of symbol %q", attributes["name"])
+		}
+		symbol := Symbol{Name: attributes["name"], Addr: uint16(value)}
+		if size, err := parseCA65Number(attributes["size"]); err == nil {
+			symbol.Size = size
+		}
+		if seg, ok := segments[attributes["seg"]]; ok && seg.inROM && seg.romStart >= 0 {
+			symbol.InPRGROM = true
+			symbol.Offset = seg.romStart + value - seg.start
+		}
+		s.Add(symbol)
+	}
+	return nil
+}
+
+// parseCA65DebugLine splits a line such as `sym id=0,name="reset",val=0x8000` into its kind and attributes.
+func parseCA65DebugLine(line string) (string, map[string]string, error) {
+	kind, rest, _ := strings.Cut(strings.TrimSpace(line), "\t")
+	attributes := map[string]string{}
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			return "", nil, fmt.Errorf("expected key=value in %q", rest)
+		}
+		if strings.HasPrefix(value, `"`) {
+			end := strings.IndexByte(value[1:], '"')
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated string in %q", rest)
+			}
+			attributes[key] = value[1 : end+1]
+			rest = strings.TrimPrefix(value[end+2:], ",")
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			attributes[key] = value
+		}
+	}
+	return kind, attributes, nil
+}
+
+func parseCA65Number(value string) (int, error) {
+	n, err := strconv.ParseInt(value, 0, 32)
+	return int(n), err
+}
+
+// Symbols returns the symbols used by the debugger, the disassembly and the trace log.
+func (v *VM) Symbols() *Symbols {
+	return v.debugger.symbols
+}
+
+// LoadSymbols loads the symbols of a label file, see: Symbols.LoadFile
+func (v *VM) LoadSymbols(filePath string) error {
+	return v.debugger.symbols.LoadFile(filePath)
+}
+
+// LoadSymbolsForROM loads the label files found next to the ROM at the given path, if any:
+// <name>.dbg, <name>.mlb, <name>.nes.ram.nl and <name>.nes.<bank>.nl
+func (v *VM) LoadSymbolsForROM(romPath string) error {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	paths := []string{base + ".dbg", base + ".mlb", romPath + ".ram.nl"}
+	if c := v.bus.Cartridge; c != nil {
+		for bank := 0; bank < int(c.prgRomBanks); bank++ {
+			paths = append(paths, fmt.Sprintf("%s.%X.nl", romPath, bank))
+		}
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := v.LoadSymbols(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package nes
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// newSymbolsVM loads the disassembly program, with Mesen labels next to the ROM.
+func newSymbolsVM(t *testing.T) *VM {
+	path := writeTestROM(t, disassemblyProgram, 0xC016)
+	labels := "P:0010:Sub:helper routine\nR:0012:counter\nP:0016:NMI\nC:0000:tiles\n"
+	if err := os.WriteFile(strings.TrimSuffix(path, ".nes")+".mlb", []byte(labels), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vm := NewVM()
+	vm.LoadROM(path)
+	if err := vm.LoadSymbolsForROM(path); err != nil {
+		t.Fatal(err)
+	}
+	vm.PowerCycle()
+	return vm
+}
+
+func TestSymbolsDisassembly(t *testing.T) {
+	vm := newSymbolsVM(t)
+	if n := vm.Symbols().Len(); n != 3 {
+		t.Errorf("got %d symbols, expected 3", n)
+	}
+
+	disassembly := vm.PeekDisassembly()
+	expected := map[uint16]string{
+		0xC004: "LDA counter",
+		0xC009: "JSR Sub",
+		0xC010: "LDA $C020,X ; Sub: helper routine",
+		0xC016: "RTI ; NMI:",
+	}
+	for addr, line := range expected {
+		if disassembly[addr] != line {
+			t.Errorf("0x%04X: got %q, expected %q", addr, disassembly[addr], line)
+		}
+	}
+}
+
+func TestSymbolsTrace(t *testing.T) {
+	vm := newSymbolsVM(t)
+	var log strings.Builder
+	tracer := NewTraceLogger(&log)
+	tracer.SetLabels(true)
+	vm.SetTraceLogger(tracer)
+	for i := 0; i < 7; i++ {
+		vm.Step()
+	}
+
+	lines := strings.Split(log.String(), "\n")
+	for n, expected := range map[int]string{
+		3: "C004  AD 12 00  LDA counter = 00",
+		5: "C009  20 10 C0  JSR Sub ",
+		6: "C010  BD 20 C0  LDA $C020,X @ C11F = 00",
+	} {
+		if !strings.HasPrefix(lines[n], expected) {
+			t.Errorf("line %d: got %q, expected it to start with %q", n, lines[n], expected)
+		}
+	}
+	if !strings.HasSuffix(lines[6], " ; Sub: helper routine") {
+		t.Errorf("got %q, expected the label and comment", lines[6])
+	}
+}
+
+func TestSymbolBreakpoint(t *testing.T) {
+	vm := newSymbolsVM(t)
+	if err := vm.Debugger().AddSymbolBreakpoint("missing"); err == nil {
+		t.Errorf("expected an error for an unknown symbol")
+	}
+	if err := vm.Debugger().AddSymbolBreakpoint("Sub"); err != nil {
+		t.Fatal(err)
+	}
+	// the single PRG ROM bank is mirrored at $8000 and $C000
+	if !vm.Debugger().HasBreakpoint(0xC010) || !vm.Debugger().HasBreakpoint(0x8010) {
+		t.Errorf("expected a breakpoint wherever the bank is mapped")
+	}
+
+	for i := 0; i < 10; i++ {
+		vm.Step()
+		if hit, ok := vm.Debugger().Hit(); ok {
+			if hit.Reason != BreakBreakpoint || hit.PC != 0xC010 {
+				t.Errorf("got a break on %s at 0x%04X", hit.ToString(), hit.PC)
+			}
+			break
+		}
+	}
+	if _, ok := vm.Debugger().Hit(); !ok {
+		t.Errorf("expected a break on the symbol")
+	}
+
+	vm.Debugger().RemoveSymbolBreakpoint("Sub")
+	if vm.Debugger().HasBreakpoint(0xC010) {
+		t.Errorf("expected the breakpoint to be removed")
+	}
+}
+
+func TestLoadCA65(t *testing.T) {
+	dbg := `version	major=2,minor=0
+seg	id=0,name="CODE",start=0x00C000,size=0x0030,addrsize=absolute,type=ro,oname="game.nes",ooffs=16
+seg	id=1,name="BSS",start=0x000300,size=0x0010,addrsize=absolute,type=rw
+sym	id=0,name="reset",addrsize=absolute,scope=0,def=1,ref=4,val=0xC000,seg=0,type=lab
+sym	id=1,name="buffer",addrsize=absolute,size=16,scope=0,def=2,val=0x300,seg=1,type=lab
+sym	id=2,name="BUTTON_A",addrsize=zeropage,scope=0,def=3,val=0x80,type=equ
+`
+	symbols := NewSymbols()
+	if err := symbols.LoadCA65(strings.NewReader(dbg)); err != nil {
+		t.Fatal(err)
+	}
+	if n := symbols.Len(); n != 2 {
+		t.Errorf("got %d symbols, expected 2", n)
+	}
+	if got := symbols.Lookup("reset"); len(got) != 1 || !got[0].InPRGROM || got[0].Offset != 0 {
+		t.Errorf("got %+v, expected reset at PRG ROM offset 0", got)
+	}
+	if got := symbols.Lookup("buffer"); len(got) != 1 || got[0].InPRGROM || got[0].Addr != 0x0300 || got[0].Size != 16 {
+		t.Errorf("got %+v, expected buffer at $0300", got)
+	}
+}
+
+func TestLoadFCEUX(t *testing.T) {
+	symbols := NewSymbols()
+	if err := symbols.LoadFCEUX(strings.NewReader("$0200/10#Buffer#\n$0012#counter#frame counter\n"), -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := symbols.LoadFCEUX(strings.NewReader("$C016#NMI#vblank\n"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := symbols.LoadFCEUX(strings.NewReader("$C016\n"), 0); err == nil {
+		t.Errorf("expected an error for a line without a label")
+	}
+
+	bus := NewBus()
+	if label := symbols.label(bus, 0x0203); label != "Buffer+3" {
+		t.Errorf("got %q, expected Buffer+3", label)
+	}
+	if got := symbols.Lookup("NMI"); len(got) != 1 || !got[0].InPRGROM || got[0].Offset != 0x0016 || got[0].Comment != "vblank" {
+		t.Errorf("got %+v, expected NMI at PRG ROM offset $0016", got)
+	}
+
+	for path, bank := range map[string]int{"game.nes.ram.nl": -1, "game.nes.0.nl": 0, "game.nes.1A.nl": 0x1A} {
+		if got := fceuxBank(path); got != bank {
+			t.Errorf("%s: got bank %d, expected %d", path, got, bank)
+		}
+	}
+}
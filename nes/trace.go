@@ -0,0 +1,195 @@
+package nes
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go-nes/mos6502"
+)
+
+// TraceLogger writes every executed instruction to a writer, in the format of the nestest.log produced by Nintendulator:
+//
+//	C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7
+//
+// Each line shows the state before the instruction is executed, unofficial opcodes are marked with a *.
+// With labels turned on, the loaded symbols replace the addresses of the operands, and the label and comment
+// of the instruction are appended to the line.
+type TraceLogger struct {
+	w       io.Writer
+	enabled bool
+	labels  bool
+
+	// Only instructions within the range are logged, when filtered
+	filtered   bool
+	start, end uint16
+
+	err error
+}
+
+// NewTraceLogger creates an enabled trace logger writing to w.
+func NewTraceLogger(w io.Writer) *TraceLogger {
+	return &TraceLogger{w: w, enabled: true}
+}
+
+// SetEnabled turns logging on or off, without detaching the logger from the VM.
+func (t *TraceLogger) SetEnabled(enabled bool) {
+	t.enabled = enabled
+}
+
+func (t *TraceLogger) Enabled() bool {
+	return t.enabled
+}
+
+// SetLabels turns the symbols in the log on or off, they are off by default to match the nestest.log.
+func (t *TraceLogger) SetLabels(labels bool) {
+	t.labels = labels
+}
+
+// SetAddressFilter only logs the instructions between the start and end addresses (inclusive).
+func (t *TraceLogger) SetAddressFilter(start, end uint16) {
+	t.filtered = true
+	t.start = start
+	t.end = end
+}
+
+func (t *TraceLogger) ClearAddressFilter() {
+	t.filtered = false
+}
+
+// Err returns the first error returned by the writer, after which nothing more is written.
+func (t *TraceLogger) Err() error {
+	return t.err
+}
+
+// log writes the instruction the CPU is about to execute.
+func (t *TraceLogger) log(b *Bus, symbols *Symbols) {
+	if !t.enabled || t.err != nil || b.CPU.Jammed() {
+		return
+	}
+	if pc := b.CPU.Registers().PC; t.filtered && (pc < t.start || pc > t.end) {
+		return
+	}
+	if !t.labels {
+		symbols = nil
+	}
+	_, t.err = io.WriteString(t.w, b.traceLine(symbols)+"\n")
+}
+
+// nestestMnemonics are the unofficial mnemonics that nestest.log spells differently from ca65.
+// The trace follows nestest.log, so that it can be compared with it, see: cmd/tracediff
+var nestestMnemonics = map[string]string{"ISC": "ISB"}
+
+// traceLine formats the instruction the CPU is about to execute as a line of the trace log, with the given symbols if not nil.
+func (b *Bus) traceLine(symbols *Symbols) string {
+	r := b.CPU.Registers()
+	opcode := b.Peek(r.PC)
+	info := b.CPU.Opcode(opcode)
+
+	operands := make([]uint8, info.Size)
+	bytes := make([]string, info.Size)
+	for i := range operands {
+		operands[i] = b.Peek(r.PC + uint16(i))
+		bytes[i] = fmt.Sprintf("%02X", operands[i])
+	}
+
+	marker := ' '
+	if info.Unofficial {
+		marker = '*'
+	}
+	disassembly := b.CPU.Mnemonic(opcode)
+	if mnemonic, ok := nestestMnemonics[disassembly]; ok {
+		disassembly = mnemonic
+	}
+	// formats an address of the operand, with the given number of hex digits, or its label
+	address := func(addr uint16, digits int) string {
+		if symbols != nil {
+			if label := symbols.label(b, addr); label != "" {
+				return label
+			}
+		}
+		return fmt.Sprintf("$%0*X", digits, addr)
+	}
+	if operand := b.traceOperand(r, info, operands, address); operand != "" {
+		disassembly += " " + operand
+	}
+
+	line := fmt.Sprintf("%04X  %-8s %c%-32sA:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:%3d,%3d CYC:%d",
+		r.PC, strings.Join(bytes, " "), marker, disassembly,
+		r.A, r.X, r.Y, r.P, r.SP, b.PPU.scanline, b.PPU.cycle, b.CPU.Cycles())
+	if symbols != nil {
+		if annotation := symbols.annotation(b, r.PC); annotation != "" {
+			line += " ; " + annotation
+		}
+	}
+	return line
+}
+
+// traceOperand formats the operand of an instruction, along with the addresses it resolves to and the value found there.
+func (b *Bus) traceOperand(r mos6502.Registers, info mos6502.Opcode, operands []uint8, address func(addr uint16, digits int) string) string {
+	var lo, hi uint8
+	if len(operands) > 1 {
+		lo = operands[1]
+	}
+	if len(operands) > 2 {
+		hi = operands[2]
+	}
+	abs := uint16(hi)<<8 | uint16(lo)
+	// reads a pointer from the zero page, wrapping around within it
+	zpPointer := func(addr uint8) uint16 {
+		return uint16(b.Peek(uint16(addr+1)))<<8 | uint16(b.Peek(uint16(addr)))
+	}
+
+	switch info.Mode {
+	case mos6502.ModeAccu:
+		return "A"
+	case mos6502.ModeImmd:
+		return fmt.Sprintf("#$%02X", lo)
+	case mos6502.ModeZpag:
+		return fmt.Sprintf("%s = %02X", address(uint16(lo), 2), b.Peek(uint16(lo)))
+	case mos6502.ModeZpgX:
+		addr := uint16(lo + r.X)
+		return fmt.Sprintf("%s,X @ %02X = %02X", address(uint16(lo), 2), addr, b.Peek(addr))
+	case mos6502.ModeZpgY:
+		addr := uint16(lo + r.Y)
+		return fmt.Sprintf("%s,Y @ %02X = %02X", address(uint16(lo), 2), addr, b.Peek(addr))
+	case mos6502.ModeAbso:
+		if info.Instruction == mos6502.JMP || info.Instruction == mos6502.JSR {
+			return address(abs, 4)
+		}
+		return fmt.Sprintf("%s = %02X", address(abs, 4), b.Peek(abs))
+	case mos6502.ModeAbsX:
+		addr := abs + uint16(r.X)
+		return fmt.Sprintf("%s,X @ %04X = %02X", address(abs, 4), addr, b.Peek(addr))
+	case mos6502.ModeAbsY:
+		addr := abs + uint16(r.Y)
+		return fmt.Sprintf("%s,Y @ %04X = %02X", address(abs, 4), addr, b.Peek(addr))
+	case mos6502.ModeIndi:
+		// the hi-byte of the pointer is read from the same page, as on the 6502
+		target := uint16(b.Peek(abs&0xFF00|(abs+1)&0x00FF))<<8 | uint16(b.Peek(abs))
+		if b.CPU.Variant() == mos6502.CMOS65C02 {
+			target = uint16(b.Peek(abs+1))<<8 | uint16(b.Peek(abs))
+		}
+		return fmt.Sprintf("(%s) = %04X", address(abs, 4), target)
+	case mos6502.ModeXInd:
+		pointer := lo + r.X
+		addr := zpPointer(pointer)
+		return fmt.Sprintf("(%s,X) @ %02X = %04X = %02X", address(uint16(lo), 2), pointer, addr, b.Peek(addr))
+	case mos6502.ModeIndY:
+		base := zpPointer(lo)
+		addr := base + uint16(r.Y)
+		return fmt.Sprintf("(%s),Y = %04X @ %04X = %02X", address(uint16(lo), 2), base, addr, b.Peek(addr))
+	case mos6502.ModeRela:
+		return address(r.PC+2+uint16(int8(lo)), 4)
+	case mos6502.ModeZInd:
+		addr := zpPointer(lo)
+		return fmt.Sprintf("(%s) = %04X = %02X", address(uint16(lo), 2), addr, b.Peek(addr))
+	case mos6502.ModeAInX:
+		pointer := abs + uint16(r.X)
+		target := uint16(b.Peek(pointer+1))<<8 | uint16(b.Peek(pointer))
+		return fmt.Sprintf("(%s,X) @ %04X = %04X", address(abs, 4), pointer, target)
+	case mos6502.ModeZRel:
+		return fmt.Sprintf("%s = %02X,%s", address(uint16(lo), 2), b.Peek(uint16(lo)), address(r.PC+3+uint16(int8(hi)), 4))
+	}
+	return ""
+}
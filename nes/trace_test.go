@@ -0,0 +1,75 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTraceLogger(t *testing.T) {
+	vm := newDebugVM(debugProgram)
+	var log strings.Builder
+	tracer := NewTraceLogger(&log)
+	vm.SetTraceLogger(tracer)
+
+	vm.Step()
+	vm.Step()
+	tracer.SetAddressFilter(0x0300, 0x030F)
+	for i := 0; i < 4; i++ {
+		vm.Step() // filtered out
+	}
+	vm.Step()
+	tracer.SetEnabled(false)
+	vm.Step()
+
+	expected := []string{
+		"0300  A2 05     LDX #$05                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7",
+		"0302  20 10 03  JSR $0310                       A:00 X:05 Y:00 P:24 SP:FD PPU:  0, 27 CYC:9",
+		"0305  85 20     STA $20 = 00                    A:42 X:05 Y:00 P:24 SP:FD PPU:  0,105 CYC:35",
+	}
+	if got := strings.Split(strings.TrimSuffix(log.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestTraceUnofficialOpcode(t *testing.T) {
+	// ISC $10, LAX $10
+	vm := newDebugVM([]uint8{0xE7, 0x10, 0xA7, 0x10})
+	var log strings.Builder
+	vm.SetTraceLogger(NewTraceLogger(&log))
+	vm.Step()
+	vm.Step()
+
+	lines := strings.Split(log.String(), "\n")
+	for n, prefix := range []string{
+		"0300  E7 10    *ISB $10 = 00                    A:00",
+		"0302  A7 10    *LAX $10 = 01                    A:FE",
+	} {
+		if !strings.HasPrefix(lines[n], prefix) {
+			t.Errorf("got %q, expected it to start with %q", lines[n], prefix)
+		}
+	}
+}
+
+func TestTraceCycleStepping(t *testing.T) {
+	vm := newDebugVM(debugProgram)
+	var log strings.Builder
+	vm.SetTraceLogger(NewTraceLogger(&log))
+
+	// an instruction started by StepCycle is traced once, when it starts
+	vm.StepCycle()
+	vm.Step()
+	vm.StepCycle()
+	vm.StepCycle()
+	vm.Step()
+
+	expected := []string{
+		"0300  A2 05     LDX #$05                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7",
+		"0302  20 10 03  JSR $0310                       A:00 X:05 Y:00 P:24 SP:FD PPU:  0, 27 CYC:9",
+	}
+	if got := strings.TrimSuffix(log.String(), "\n"); got != strings.Join(expected, "\n") {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, strings.Join(expected, "\n"))
+	}
+	if pc := vm.PeekCPU().PC; pc != 0x0310 {
+		t.Errorf("got PC=%04X, expected 0310", pc)
+	}
+}
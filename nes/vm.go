@@ -1,17 +1,32 @@
 package nes
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
+	"hash/fnv"
 	"image/color"
+
+	"go-nes/assembler"
 )
 
 type VM struct {
-	bus *Bus
+	bus      *Bus
+	debugger *Debugger
+	tracer   *TraceLogger
+
+	// Video recording in progress, see: StartRecording
+	recording *recording
+
+	// Set while an instruction is partially executed by StepCycle
+	cycleStepping bool
 }
 
 func NewVM() *VM {
 	vm := &VM{}
 	vm.bus = NewBus()
+	vm.debugger = NewDebugger(vm.bus)
+	vm.bus.debugger = vm.debugger
 	return vm
 }
 
@@ -20,8 +35,25 @@ func (v *VM) ForceSetResetVector(resetVector uint16) {
 	v.bus.CpuWrite(0xFFFD, uint8(resetVector>>8))
 }
 
+// LoadROM inserts the cartridge at the given path, switching to the region it was made for.
 func (v *VM) LoadROM(filePath string) {
-	v.bus.InsertCartridge(NewCartridge(filePath))
+	cartridge := NewCartridge(filePath)
+	v.bus.InsertCartridge(cartridge)
+	v.SetRegion(cartridge.region)
+}
+
+// SetRegion switches the CPU/PPU timing to that of the given region.
+func (v *VM) SetRegion(region Region) {
+	v.bus.SetRegion(region)
+}
+
+func (v *VM) Region() Region {
+	return v.bus.region
+}
+
+// Jammed returns true when the CPU has executed a KIL instruction, and stopped until the next reset.
+func (v *VM) Jammed() bool {
+	return v.bus.CPU.Jammed()
 }
 
 // LoadProgramAsString will load the given string as if it were a string of bytes.
@@ -39,28 +71,150 @@ func (v *VM) LoadProgramAsString(program string, resetVector uint16) {
 	v.bus.CpuWrite(0xFFFC, uint8(resetVector))
 	v.bus.CpuWrite(0xFFFD, uint8(resetVector>>8))
 
-	v.bus.Reset()
+	// the CPU starts from power-on, with the memory the program was loaded into kept
+	v.bus.CPU.PowerOn()
 }
 
+// LoadProgramSource assembles the given 6502 source, see: assembler.Assembler, and loads it into RAM.
+// The program starts at its first address. Bytes outside of RAM ($0000-$1FFF) are an error, as there is no memory
+// to hold them without a cartridge, and the cartridge memory is read-only.
+func (v *VM) LoadProgramSource(source string) error {
+	program, err := assembler.New(v.bus.CPU.Variant()).Assemble("", source)
+	if err != nil {
+		return err
+	}
+	for _, segment := range program.Segments {
+		if end := int(segment.Addr) + len(segment.Data); len(segment.Data) > 0 && end > 0x2000 {
+			return fmt.Errorf("$%04X-$%04X is outside of RAM", segment.Addr, end-1)
+		}
+	}
+	for _, segment := range program.Segments {
+		for n, b := range segment.Data {
+			v.bus.CpuWrite(segment.Addr+uint16(n), b)
+		}
+	}
+
+	entry := program.Entry()
+	v.bus.CPU.PowerOn()
+	// without a cartridge there is no memory behind the reset vector
+	registers := v.bus.CPU.Registers()
+	registers.PC = entry
+	v.bus.CPU.SetRegisters(registers)
+	return nil
+}
+
+// Reset presses the reset button. While a movie is recorded, it is recorded in the current frame, see: RecordMovie
 func (v *VM) Reset() {
+	if v.MovieRecording() {
+		frames := v.bus.movie.movie.Frames
+		frames[len(frames)-1].Commands |= MovieSoftReset
+	}
 	v.bus.Reset()
 }
 
-// Step will clock the bus once.
+// Step will execute one CPU instruction, running the PPU alongside it.
+// An interrupt that becomes pending during the instruction is entered as part of the same step.
 func (v *VM) Step() {
+	v.debugger.resume()
+	v.startInstruction()
 	v.bus.Clock()
+	v.debugger.afterInstruction()
+}
+
+// startInstruction carries out the movie commands and traces the next instruction,
+// unless they were already done by StepCycle for the instruction it started.
+func (v *VM) startInstruction() {
+	if v.cycleStepping {
+		v.cycleStepping = false
+		return
+	}
+	v.movieCommands()
+	v.trace()
+}
+
+// StepCycle will execute a single CPU cycle, returning true once the current instruction is complete.
+// While an instruction is in progress, PeekCPU shows the registers from before it, with the cycle count advanced.
+func (v *VM) StepCycle() bool {
+	if !v.cycleStepping {
+		v.cycleStepping = true
+		v.debugger.resume()
+		v.movieCommands()
+		v.trace()
+	}
+	completed := v.bus.CPU.StepCycle()
+	if completed {
+		v.cycleStepping = false
+		v.debugger.afterInstruction()
+	}
+	return completed
 }
 
-// StepFrame will clock the bus until 1 frame is complete
+// StepFrame will clock the bus until 1 frame is complete, or the debugger breaks, see: Debugger.Hit
 func (v *VM) StepFrame() {
-	for !v.bus.PPU.frameComplete {
+	v.debugger.resume()
+	for !v.bus.PPU.frameComplete || v.cycleStepping {
+		v.startInstruction()
 		v.bus.Clock()
+		if v.debugger.afterInstruction() {
+			return
+		}
 	}
 	v.bus.PPU.frameComplete = false
+	v.recordFrame()
 }
 
-func (v *VM) GetScreen() [256][240]color.Color {
-	return v.bus.PPU.GetScreen()
+// SetTraceLogger logs every instruction executed from now on to the given logger, or stops logging when it is nil.
+func (v *VM) SetTraceLogger(t *TraceLogger) {
+	v.tracer = t
+}
+
+func (v *VM) TraceLogger() *TraceLogger {
+	return v.tracer
+}
+
+func (v *VM) trace() {
+	if v.tracer != nil {
+		v.tracer.log(v.bus, v.debugger.symbols)
+	}
+}
+
+// Debugger returns the debugger of the VM, to set breakpoints and watchpoints.
+func (v *VM) Debugger() *Debugger {
+	return v.debugger
+}
+
+// FrameIndices returns the most recently rendered frame as ScreenWidth x ScreenHeight row-major frame indices.
+// The low 6 bits of each index hold the NES colour index, and bits 6-8 hold the PPUMASK emphasis bits.
+// The returned slice is owned by the PPU and is overwritten as emulation continues.
+func (v *VM) FrameIndices() []uint16 {
+	return v.bus.PPU.FrameIndices()
+}
+
+// FrameHash returns a hash of the most recently rendered frame, to compare frames across runs.
+func (v *VM) FrameHash() uint64 {
+	hash := fnv.New64a()
+	var b [2]byte
+	for _, index := range v.bus.PPU.FrameIndices() {
+		binary.LittleEndian.PutUint16(b[:], index)
+		hash.Write(b[:])
+	}
+	return hash.Sum64()
+}
+
+// FrameRGBA fills dst with the most recently rendered frame as row-major RGBA bytes.
+// dst must be at least ScreenWidth * ScreenHeight * 4 bytes long.
+func (v *VM) FrameRGBA(dst []byte) {
+	v.bus.PPU.FrameRGBA(dst)
+}
+
+// FrameBurstPhase returns the colour burst phase (0-2) of the most recently rendered frame, as needed by NTSCFilter.
+func (v *VM) FrameBurstPhase() int {
+	return v.bus.PPU.burstPhase
+}
+
+// SetPalette changes the palette used by FrameRGBA and the debugging displays.
+func (v *VM) SetPalette(palette *Palette) {
+	v.bus.PPU.SetPalette(palette)
 }
 
 func (v *VM) GetPPUNametable(index int) [1024]uint8 {
@@ -79,10 +233,6 @@ func (v *VM) GetPaletteDisplay() [32]color.Color {
 	return v.bus.PPU.GetPaletteDisplay()
 }
 
-func (v *VM) SetControllerState(input uint8) {
-	v.bus.Controller = input
-}
-
 /** For debugging purposes only **/
 
 // PeekCPUResult is a struct containing all registers of the CPU.
@@ -98,14 +248,15 @@ type PeekCPUResult struct {
 
 // PeekCPU returns a snapshot of the CPU registers as a PeekCPUResult.
 func (v *VM) PeekCPU() PeekCPUResult {
+	registers := v.bus.CPU.Registers()
 	return PeekCPUResult{
-		A:        v.bus.CPU.a,
-		X:        v.bus.CPU.x,
-		Y:        v.bus.CPU.y,
-		StackPtr: v.bus.CPU.sp,
-		PC:       v.bus.CPU.pc,
-		P:        v.bus.CPU.GetStatus(),
-		Cycle:    v.bus.CPU.cycle,
+		A:        registers.A,
+		X:        registers.X,
+		Y:        registers.Y,
+		StackPtr: registers.SP,
+		PC:       registers.PC,
+		P:        registers.P,
+		Cycle:    v.bus.CPU.Cycles(),
 	}
 }
 
@@ -121,10 +272,7 @@ func (v *VM) PeekRAM(start, end uint16) []byte {
 	return result
 }
 
+// PeekCPUSnapshot returns the instruction about to be executed, in the format of the trace log, see: TraceLogger
 func (v *VM) PeekCPUSnapshot() string {
-	return v.bus.CPU.PeekCurrentSnapshot()
-}
-
-func (v *VM) PeekDisassembly() map[uint16]string {
-	return v.bus.CPU.PeekDisassembly()
+	return v.bus.traceLine(nil)
 }
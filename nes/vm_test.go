@@ -0,0 +1,42 @@
+package nes
+
+import "testing"
+
+func TestLoadProgramSource(t *testing.T) {
+	vm := NewVM()
+	err := vm.LoadProgramSource(`
+		.org $0300
+		LDX #3
+		LDA #0
+@loop:	CLC
+		ADC #10
+		DEX
+		BNE @loop
+		STA result
+done:	JMP done
+result = $20
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc := vm.PeekCPU().PC; pc != 0x0300 {
+		t.Fatalf("got PC 0x%04X, expected the program to start at 0x0300", pc)
+	}
+	for i := 0; i < 20; i++ {
+		vm.Step()
+	}
+	if result := vm.PeekRAM(0x0020, 0x0020)[0]; result != 30 {
+		t.Errorf("got %d, expected 30", result)
+	}
+
+	if err := vm.LoadProgramSource("LDA missing"); err == nil {
+		t.Errorf("expected an error for an undefined symbol")
+	}
+
+	// the bytes that would not land in RAM are rejected
+	for _, source := range []string{".org $8000\nNOP", ".org $1FFF\nNOP\nNOP", ".org $0300\nNOP\n.org $FFFA\n.word 0"} {
+		if err := vm.LoadProgramSource(source); err == nil {
+			t.Errorf("%q: expected an error for bytes outside of RAM", source)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// WAV Reference: http://soundfile.sapp.org/doc/WaveFormat/
+
+package nes
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// AudioSink receives the mono samples of the audio output, between -1 and 1, as they are produced.
+// There is no APU yet to produce them, so nothing feeds a sink during emulation, see: WAVWriter
+type AudioSink interface {
+	WriteSamples(samples []float32) error
+	// Close finishes the audio, and closes the file it was created with, if any.
+	Close() error
+}
+
+// wavHeaderSize is the size of the RIFF header, and of the format and data chunk headers, of a PCM WAV file.
+const wavHeaderSize = 44
+
+// WAVWriter streams the samples as 16-bit mono PCM. The sizes in the header are only known at the end,
+// so they are written on Close, which seeks back to them.
+type WAVWriter struct {
+	w          io.WriteSeeker
+	out        *bufio.Writer
+	sampleRate int
+	started    bool
+	dataSize   uint32
+}
+
+func NewWAVWriter(w io.WriteSeeker, sampleRate int) *WAVWriter {
+	return &WAVWriter{w: w, out: bufio.NewWriter(w), sampleRate: sampleRate}
+}
+
+// CreateWAV creates a WAVWriter writing to the given file, see: AudioSink
+func CreateWAV(filePath string, sampleRate int) (AudioSink, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating audio file: %v", err)
+	}
+	return &wavFile{NewWAVWriter(f, sampleRate), f}, nil
+}
+
+// wavFile closes the file of a WAVWriter once it is finished.
+type wavFile struct {
+	*WAVWriter
+	file *os.File
+}
+
+func (f *wavFile) Close() error {
+	err := f.WAVWriter.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeHeader writes the header for the given size of the sample data.
+func (w *WAVWriter) writeHeader(dataSize uint32) {
+	const channels, bitsPerSample = 1, 16
+	blockAlign := channels * bitsPerSample / 8
+	w.out.WriteString("RIFF")
+	binary.Write(w.out, binary.LittleEndian, uint32(wavHeaderSize-8)+dataSize)
+	w.out.WriteString("WAVEfmt ")
+	binary.Write(w.out, binary.LittleEndian, []uint32{16})
+	binary.Write(w.out, binary.LittleEndian, []uint16{1, channels}) // PCM
+	binary.Write(w.out, binary.LittleEndian, []uint32{uint32(w.sampleRate), uint32(w.sampleRate * blockAlign)})
+	binary.Write(w.out, binary.LittleEndian, []uint16{uint16(blockAlign), bitsPerSample})
+	w.out.WriteString("data")
+	binary.Write(w.out, binary.LittleEndian, dataSize)
+}
+
+// WriteSamples appends the samples, clipped to the range -1 to 1.
+func (w *WAVWriter) WriteSamples(samples []float32) error {
+	if !w.started {
+		w.started = true
+		w.writeHeader(0)
+	}
+	var pcm [2]byte
+	for _, sample := range samples {
+		sample = float32(math.Max(-1, math.Min(1, float64(sample))))
+		binary.LittleEndian.PutUint16(pcm[:], uint16(int16(math.Round(float64(sample)*math.MaxInt16))))
+		if _, err := w.out.Write(pcm[:]); err != nil {
+			return err
+		}
+		w.dataSize += 2
+	}
+	return nil
+}
+
+func (w *WAVWriter) Close() error {
+	if err := w.WriteSamples(nil); err != nil {
+		return err
+	}
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.out.Reset(w.w)
+	w.writeHeader(w.dataSize)
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	_, err := w.w.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,57 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	sink, err := CreateWAV(path, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WriteSamples([]float32{0, 1, -1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WriteSamples([]float32{0.5, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != wavHeaderSize+5*2 {
+		t.Fatalf("got %d bytes, expected %d for 5 samples", len(data), wavHeaderSize+5*2)
+	}
+	if !bytes.HasPrefix(data, []byte("RIFF")) || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Errorf("got header %q", data[:wavHeaderSize])
+	}
+	for _, field := range []struct {
+		name     string
+		offset   int
+		expected uint32
+	}{
+		{"RIFF size", 4, 36 + 10},
+		{"sample rate", 24, 44100},
+		{"byte rate", 28, 88200},
+		{"data size", 40, 10},
+	} {
+		if got := binary.LittleEndian.Uint32(data[field.offset:]); got != field.expected {
+			t.Errorf("%s: got %d, expected %d", field.name, got, field.expected)
+		}
+	}
+
+	var samples [5]int16
+	binary.Read(bytes.NewReader(data[wavHeaderSize:]), binary.LittleEndian, &samples)
+	if samples != [5]int16{0, 32767, -32767, 16384, 32767} {
+		t.Errorf("got samples %v", samples)
+	}
+}